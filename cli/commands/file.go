@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"d7024e/kademlia"
+	"d7024e/kademlia/files"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func PutFileInStore(context kademlia.IKademlia, args string) (string, error) {
+	var ttl time.Duration
+	var dataShards, totalShards int
+
+	// Options may be given in any order
+	for strings.HasPrefix(args, "-") {
+		var err error
+		if strings.HasPrefix(args, "-ttl ") {
+			ttl, args, err = SplitTTLOption(args)
+		} else if strings.HasPrefix(args, "-erasure ") {
+			dataShards, totalShards, args, err = SplitErasureOption(args)
+		} else if rest, found := SplitEndOfOptions(args); found {
+			args = rest
+			break
+		} else {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if args == "" {
+		return "", errors.New("expected 1 argument, but got 0")
+	}
+
+	path := RemoveDoubleQuotes(args)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if totalShards > 0 {
+		return files.PutErasureCoded(context, filepath.Base(path), data, ttl, dataShards, totalShards)
+	}
+	return files.Put(context, filepath.Base(path), data, ttl)
+}
+
+func GetFileByHash(context kademlia.IKademlia, args string) (string, error) {
+	hashAndPath := strings.SplitN(args, " ", 2)
+	if hashAndPath[0] == "" {
+		return "", errors.New("expected 1 or 2 arguments, but got 0")
+	}
+
+	file, err := files.Get(context, RemoveDoubleQuotes(hashAndPath[0]))
+	if err != nil {
+		return "", err
+	}
+
+	// Default to the name of the file in the current directory
+	path := filepath.Base(file.Name)
+	if len(hashAndPath) == 2 {
+		path = RemoveDoubleQuotes(hashAndPath[1])
+	}
+	if path == "." || path == string(filepath.Separator) {
+		return "", errors.New("the file has no name, give a path to write it to")
+	}
+	if err := os.WriteFile(path, file.Data, 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Wrote %d bytes to %s", len(file.Data), path), nil
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/files"
+	"d7024e/util"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A node that keeps stored values in memory, and may be stored to
+// concurrently, unlike the mock
+type fakeKademlia struct {
+	mocks.KademliaMockObject
+	lock   sync.Mutex
+	values map[string][]byte
+	ttl    map[time.Duration]bool
+}
+
+func newFakeKademlia() *fakeKademlia {
+	return &fakeKademlia{values: make(map[string][]byte), ttl: make(map[time.Duration]bool)}
+}
+
+func (k *fakeKademlia) Store(data []byte, ttl time.Duration) (string, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	hash := util.Hash(data)
+	k.values[hash] = data
+	k.ttl[ttl] = true
+	return hash, nil
+}
+
+// Get the TTLs values were stored with
+func (k *fakeKademlia) ttls() []time.Duration {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	ttls := []time.Duration{}
+	for ttl := range k.ttl {
+		ttls = append(ttls, ttl)
+	}
+	return ttls
+}
+
+func TestPutFileInStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	content := []byte("my notes")
+	os.WriteFile(path, content, 0o644)
+	manifest := files.EncodeManifest(&files.Manifest{
+		Format: files.MANIFEST_FORMAT,
+		Name:   "notes.txt",
+		Size:   int64(len(content)),
+		Chunks: []string{util.Hash(content)},
+	})
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Store", content, time.Minute).Return(util.Hash(content), nil)
+	kademliaMock.On("Store", manifest, time.Minute).Return(util.Hash(manifest), nil)
+
+	actual, err := PutFileInStore(kademliaMock, "-ttl 1m "+path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, util.Hash(manifest), actual)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPutFileInStore_WithMissingFile_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, err := PutFileInStore(kademliaMock, filepath.Join(t.TempDir(), "missing.txt"))
+
+	assert.NotNil(t, err)
+}
+
+func TestGetFileByHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "copy.txt")
+	content := []byte("my notes")
+	manifest := files.EncodeManifest(&files.Manifest{
+		Format: files.MANIFEST_FORMAT,
+		Name:   "notes.txt",
+		Size:   int64(len(content)),
+		Chunks: []string{util.Hash(content)},
+	})
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupData", util.Hash(manifest)).Return(manifest, nil, time.Minute)
+	kademliaMock.On("LookupData", util.Hash(content)).Return(content, nil, time.Minute)
+
+	str, err := GetFileByHash(kademliaMock, util.Hash(manifest)+" "+path)
+	written, _ := os.ReadFile(path)
+
+	assert.Nil(t, err)
+	assert.Contains(t, str, path)
+	assert.Equal(t, content, written)
+}
+
+func TestGetObjectByHash_WithManifest_ShouldReassembleFile(t *testing.T) {
+	content := []byte("my notes")
+	manifest := files.EncodeManifest(&files.Manifest{
+		Format: files.MANIFEST_FORMAT,
+		Size:   int64(len(content)),
+		Chunks: []string{util.Hash(content)},
+	})
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupData", util.Hash(manifest)).Return(manifest, nil, time.Minute)
+	kademliaMock.On("LookupData", util.Hash(content)).Return(content, nil, time.Minute)
+
+	value, ttl, err := FetchObjectByHash(kademliaMock, util.Hash(manifest), true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, content, value)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func TestGetObjectByHash_WithManifest_WhenNotAsFile_ShouldReturnManifest(t *testing.T) {
+	manifest := files.EncodeManifest(&files.Manifest{
+		Format: files.MANIFEST_FORMAT,
+		Size:   8,
+		Chunks: []string{util.Hash([]byte("my notes"))},
+	})
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupData", util.Hash(manifest)).Return(manifest, nil, time.Minute)
+
+	value, _, err := FetchObjectByHash(kademliaMock, util.Hash(manifest), false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, manifest, value)
+}
+
+func TestPutFileInStore_WithErasureCode_ShouldStoreShards(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(path, []byte("my notes"), 0o644)
+
+	context := newFakeKademlia()
+
+	hash, err := PutFileInStore(context, "-erasure 2/3 -ttl 1m "+path)
+
+	assert.Nil(t, err)
+	// Three shards and the manifest
+	assert.Len(t, context.values, 4)
+	assert.Contains(t, context.values, hash)
+	assert.Equal(t, []time.Duration{time.Minute}, context.ttls())
+}
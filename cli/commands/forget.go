@@ -3,20 +3,52 @@ package commands
 import (
 	"d7024e/kademlia"
 	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 func ForgetObjectInStore(context kademlia.IKademlia, args string) (string, error) {
+	responses, err := ForgetObjectByHash(context, args)
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	fmt.Fprintf(&output, "Forgot dataobject with hash %s on %d of %d nodes", RemoveDoubleQuotes(args), CountRemoved(responses), len(responses))
+	for _, response := range responses {
+		if response.Err != nil {
+			fmt.Fprintf(&output, "\n%s: %v", response.Contact.String(), response.Err)
+		}
+	}
+	return output.String(), nil
+}
+
+// Make the closest nodes of a hash forget the dataobject stored under it.
+//
+// Returns the outcome on each node, or an error if the hash is invalid.
+func ForgetObjectByHash(context kademlia.IKademlia, args string) ([]rpc.ForgetResponse, error) {
 	if args == "" {
-		return "", errors.New("expected 1 argument, but got 0")
+		return nil, errors.New("expected 1 argument, but got 0")
 	}
 
 	cleanHash := RemoveDoubleQuotes(args)
-	closestContacts := context.LookupContact(routing.NewKademliaID(cleanHash))
-	err := context.ForgetData(cleanHash, closestContacts)
-	if err != nil {
-		return "", err
+	id := routing.NewKademliaID(cleanHash)
+	if id == nil {
+		return nil, fmt.Errorf("invalid hash %q, expected %d hex digits", cleanHash, routing.IDLength*2)
+	}
+	closestContacts := context.LookupContact(id)
+	return context.ForgetData(cleanHash, closestContacts)
+}
+
+// Count the nodes that held and removed a forgotten dataobject
+func CountRemoved(responses []rpc.ForgetResponse) int {
+	removed := 0
+	for _, response := range responses {
+		if response.Removed {
+			removed++
+		}
 	}
-	return fmt.Sprintf("Successfully forgot dataobject with hash %s", cleanHash), nil
+	return removed
 }
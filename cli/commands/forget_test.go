@@ -2,6 +2,8 @@ package commands
 
 import (
 	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,18 +11,47 @@ import (
 )
 
 func TestForgetObjectInStore(t *testing.T) {
-	expectedHash := "myhash"
+	expectedHash := "4a1e8f3f8b6a2e6cba3a9d1c0c0e23a7d2b1f9e5"
 	kademliaMock := new(mocks.KademliaMockObject)
 	kademliaMock.On("LookupContact", mock.Anything).Return(nil)
-	kademliaMock.On("ForgetData", mock.Anything).Return(nil)
+	kademliaMock.On("ForgetData", mock.Anything).Return(nil, nil)
 
 	_, err := ForgetObjectInStore(kademliaMock, expectedHash)
 	assert.Nil(t, err)
 }
 
+func TestForgetObjectInStore_ShouldShowHowManyNodesRemovedIt(t *testing.T) {
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000A"), "nodeA")
+	nodeB := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000B"), "nodeB")
+	nodeC := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000C"), "nodeC")
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupContact", mock.Anything).Return(nil)
+	kademliaMock.On("ForgetData", "4a1e8f3f8b6a2e6cba3a9d1c0c0e23a7d2b1f9e5").Return([]rpc.ForgetResponse{
+		{Contact: nodeA, Removed: true},
+		{Contact: nodeB, Removed: false},
+		{Contact: nodeC, Err: rpc.ErrTimeout},
+	}, nil)
+
+	output, err := ForgetObjectInStore(kademliaMock, "4a1e8f3f8b6a2e6cba3a9d1c0c0e23a7d2b1f9e5")
+
+	assert.Nil(t, err)
+	assert.Contains(t, output, "on 1 of 3 nodes")
+	assert.Contains(t, output, nodeC.String()+": "+rpc.ErrTimeout.Error())
+	assert.NotContains(t, output, nodeB.String())
+}
+
 func TestForgetObjectInStore_WithNoArgs_ShouldReturnError(t *testing.T) {
 	kademliaMock := new(mocks.KademliaMockObject)
 
 	_, err := ForgetObjectInStore(kademliaMock, "")
 	assert.NotNil(t, err)
 }
+
+func TestForgetObjectInStore_WithInvalidHash_ShouldReturnErrorWithoutLookup(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, err := ForgetObjectInStore(kademliaMock, "myhash")
+
+	assert.ErrorContains(t, err, "invalid hash")
+	kademliaMock.AssertNotCalled(t, "LookupContact", mock.Anything)
+}
@@ -2,19 +2,70 @@ package commands
 
 import (
 	"d7024e/kademlia"
+	"d7024e/kademlia/encryption"
+	"d7024e/kademlia/files"
 	"errors"
+	"fmt"
+	"time"
 )
 
 func GetObjectByHash(context kademlia.IKademlia, args string) (string, error) {
+	args, asFile := SplitFlag(args, "-file")
+	value, ttl, err := FetchObjectByHash(context, args, asFile)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n\nExpires in %v", value, ttl.Round(time.Second)), nil
+}
+
+// Find a dataobject in the network by its hash.
+//
+// If `asFile` is set and the dataobject is the manifest of a file stored with
+// putfile, the file is reassembled and returned instead. Otherwise, the value
+// is returned as it is stored, even if it looks like a manifest.
+//
+// Returns the value of the dataobject and the time left until it expires.
+func FetchObjectByHash(context kademlia.IKademlia, args string, asFile bool) ([]byte, time.Duration, error) {
 	if args == "" {
-		return "", errors.New("expected 1 argument, but got 0")
+		return nil, 0, errors.New("expected 1 argument, but got 0")
 	}
 
 	cleanHash := RemoveDoubleQuotes(args)
-	value, _ := context.LookupData(cleanHash)
-	if value != nil {
-		return string(value), nil
-	} else {
-		return "", errors.New("data not found")
+	if encryption.IsCapability(cleanHash) {
+		return fetchEncryptedObject(context, cleanHash)
+	}
+
+	value, _, ttl := context.LookupData(cleanHash)
+	if value == nil {
+		return nil, 0, errors.New("data not found")
+	}
+
+	// A manifest stands for a file stored in chunks
+	if manifest, ok := files.DecodeManifest(value); ok && asFile {
+		data, err := files.Assemble(context, manifest)
+		if err != nil {
+			return nil, 0, err
+		}
+		return data, ttl, nil
+	}
+	return value, ttl, nil
+}
+
+// Find an encrypted dataobject in the network by its capability, and decrypt
+// it with the key of the capability.
+func fetchEncryptedObject(context kademlia.IKademlia, str string) ([]byte, time.Duration, error) {
+	capability, err := encryption.ParseCapability(str)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ciphertext, _, ttl := context.LookupData(capability.Hash)
+	if ciphertext == nil {
+		return nil, 0, errors.New("data not found")
+	}
+	value, err := encryption.Decrypt(capability.Key, ciphertext)
+	if err != nil {
+		return nil, 0, err
 	}
+	return value, ttl, nil
 }
@@ -2,7 +2,9 @@ package commands
 
 import (
 	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/encryption"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -10,16 +12,41 @@ import (
 
 func TestGetObjectByHash(t *testing.T) {
 	testObj := new(mocks.KademliaMockObject)
-	testObj.On("LookupData", mock.Anything).Return([]byte("test"), nil)
+	testObj.On("LookupData", mock.Anything).Return([]byte("test"), nil, time.Minute)
 
 	str, _ := GetObjectByHash(testObj, "test")
-	assert.Equal(t, "test", str)
+	assert.Contains(t, str, "test")
+	assert.Contains(t, str, "Expires in 1m0s")
 }
 
 func TestGetObjectByHash_DataNoteFound(t *testing.T) {
 	testObj := new(mocks.KademliaMockObject)
-	testObj.On("LookupData", mock.Anything).Return(nil, nil)
+	testObj.On("LookupData", mock.Anything).Return(nil, nil, time.Duration(0))
 
 	_, err := GetObjectByHash(testObj, "test")
 	assert.NotNil(t, err)
 }
+
+func TestGetObjectByHash_WithCapability_ShouldDecrypt(t *testing.T) {
+	key, ciphertext, _ := encryption.EncryptConvergent([]byte("my message"))
+	capability := encryption.Capability{Hash: "myhash", Key: key}
+	testObj := new(mocks.KademliaMockObject)
+	testObj.On("LookupData", "myhash").Return(ciphertext, nil, time.Minute)
+
+	value, ttl, err := FetchObjectByHash(testObj, capability.String(), false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("my message"), value)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func TestGetObjectByHash_WithWrongKey_ShouldReturnError(t *testing.T) {
+	_, ciphertext, _ := encryption.EncryptConvergent([]byte("my message"))
+	capability := encryption.Capability{Hash: "myhash", Key: encryption.DeriveKey([]byte("other"))}
+	testObj := new(mocks.KademliaMockObject)
+	testObj.On("LookupData", "myhash").Return(ciphertext, nil, time.Minute)
+
+	_, _, err := FetchObjectByHash(testObj, capability.String(), false)
+
+	assert.ErrorIs(t, err, encryption.ErrDecryptionFailed)
+}
@@ -1,6 +1,12 @@
 package commands
 
-import "d7024e/kademlia"
+import (
+	"d7024e/kademlia"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Command struct {
 	Name        string
@@ -11,16 +17,29 @@ type Command struct {
 
 func AllCommands() []Command {
 	return []Command{
+		{"export", "[path]", "Writes a snapshot of the datastore of this node to the path, for backups or for moving the node.", ExportStore},
 		{"forget", "[hash]", "Takes a hash and forgets any dataobject associated with it", ForgetObjectInStore},
-		{"get", "[hash]", "Takes a hash and downloads the file from the network.", GetObjectByHash},
+		{"get", "[-file] [hash | capability]", "Takes a hash, or the capability of an encrypted file, and downloads the file from the network. With -file, the manifest of a file stored with putfile is reassembled into the file.", GetObjectByHash},
+		{"getfile", "[hash] [path]", "Takes the hash of a file stored with putfile, downloads it and writes it to the path, or to its original name.", GetFileByHash},
 		{"help", "", "Help on ", GetAvaliableCommands},
-		{"put", "[text]", "Uploads a file to the network and returns the hash if succesful.", PutObjectInStore},
-		{"stat", "", "Displays the status of the network.", GetStatus},
+		{"import", "[path]", "Adds the dataobjects of a snapshot written by export to the datastore of this node, and stores them again at the closest nodes.", ImportStore},
+		{"keygen", "[keyfile]", "Generates a key pair for publishing records and writes the private key to the keyfile.", GenerateRecordKey},
+		{"lookup", "[id]", "Looks up the closest nodes of the ID, or of this node if none is given, and shows every RPC the lookup sent as a tree.", TraceLookupOfID},
+		{"provide", "[-ttl duration] [--] [key]", "Announces to the network that this node can serve the content of the key. The announcement expires after the TTL, e.g. 30m.", AnnounceProviderOfKey},
+		{"providers", "[key]", "Takes a key and lists the nodes that can serve its content.", GetProvidersByKey},
+		{"publish", "[topic] [text]", "Publishes the text to the subscribers of the topic.", PublishToTopic},
+		{"put", "[-ttl duration] [-encrypt | -key hexkey] [--] [text]", "Uploads a file to the network and returns the hash if succesful. The file expires after the TTL, e.g. 30m, unless it is fetched. Encrypted files return a capability for get instead.", PutObjectInStore},
+		{"putfile", "[-ttl duration] [-erasure m/n] [--] [path]", "Uploads a local file in chunks and returns the hash of its manifest if succesful. With -erasure, the file is stored as n shards per m chunks, any m of which can rebuild them.", PutFileInStore},
+		{"record-get", "[key]", "Takes a record key and downloads the newest version of the record.", GetRecordByKey},
+		{"record-put", "[-ttl duration] [--] [keyfile] [text]", "Publishes a new version of the record of the key in the keyfile.", PutRecordInStore},
+		{"stat", "", "Displays the status of the node and its traffic.", GetStatus},
+		{"store", "[-limit count] [after key]", "Lists the dataobjects this node holds, in key order, with their size, expiry and whether they are cached or a replica. The list continues after the given key.", ListStore},
+		{"subscribe", "[topic]", "Subscribes to the topic. Publications to it are printed as they arrive.", SubscribeToTopic},
+		{"unsubscribe", "[topic]", "Unsubscribes from the topic.", UnsubscribeFromTopic},
 		{"exit", "", "Exit the CLI.", ExitApplication},
 		{"ping", "[address]", "DEBUG: Send a ping RPC to the target client", Debug_sendPing},
 		{"whoami", "", "DEBUG: Lookup myself", Debug_lookupMe},
 		{"routes", "", "DEBUG: Print routingtable", Debug_routingTable},
-		{"lookup", "", "DEBUG: Lookup", Debug_lookupMe},
 	}
 }
 
@@ -30,3 +49,92 @@ func RemoveDoubleQuotes(str string) string {
 	}
 	return str
 }
+
+// Split a leading "-ttl [duration]" option from the arguments of a command.
+//
+// Returns the TTL, or zero if the option is not given, and the remaining
+// arguments.
+func SplitTTLOption(args string) (ttl time.Duration, rest string, err error) {
+	value, rest, found := SplitOption(args, "-ttl")
+	if !found {
+		return 0, args, nil
+	}
+
+	ttl, err = time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, "", fmt.Errorf("invalid TTL %q", value)
+	}
+	return ttl, rest, nil
+}
+
+// Split a leading "-limit [count]" option from the arguments of a command.
+//
+// Returns the limit, or `defaultLimit` if the option is not given, and the
+// remaining arguments.
+func SplitLimitOption(args string, defaultLimit int) (limit int, rest string, err error) {
+	value, rest, found := SplitOption(args, "-limit")
+	if !found {
+		return defaultLimit, args, nil
+	}
+
+	limit, err = strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, "", fmt.Errorf("invalid limit %q", value)
+	}
+	return limit, rest, nil
+}
+
+// Split a leading "-erasure [data shards]/[total shards]" option from the
+// arguments of a command.
+//
+// Returns the number of data and total shards, or zero if the option is not
+// given, and the remaining arguments.
+func SplitErasureOption(args string) (dataShards int, totalShards int, rest string, err error) {
+	value, rest, found := SplitOption(args, "-erasure")
+	if !found {
+		return 0, 0, args, nil
+	}
+
+	_, err = fmt.Sscanf(value, "%d/%d", &dataShards, &totalShards)
+	if err != nil || fmt.Sprintf("%d/%d", dataShards, totalShards) != value || dataShards < 1 || totalShards <= dataShards {
+		return 0, 0, "", fmt.Errorf("invalid erasure code %q, expected e.g. 4/6", value)
+	}
+	return dataShards, totalShards, rest, nil
+}
+
+// Split a leading "--" from the arguments of a command. It ends the options
+// of the command, so that an argument that starts with "-" is taken as is.
+//
+// Returns the remaining arguments, and whether the separator was given.
+func SplitEndOfOptions(args string) (rest string, found bool) {
+	return SplitFlag(args, "--")
+}
+
+// Split a leading "[name]" flag from the arguments of a command.
+//
+// Returns the remaining arguments, and whether the flag was given.
+func SplitFlag(args string, name string) (rest string, found bool) {
+	if args == name {
+		return "", true
+	}
+	if !strings.HasPrefix(args, name+" ") {
+		return args, false
+	}
+	return strings.TrimPrefix(args, name+" "), true
+}
+
+// Split a leading "[name] [value]" option from the arguments of a command.
+//
+// Returns the value of the option and the remaining arguments, and whether
+// the option was given.
+func SplitOption(args string, name string) (value string, rest string, found bool) {
+	if !strings.HasPrefix(args, name+" ") {
+		return "", args, false
+	}
+
+	option := strings.SplitN(strings.TrimPrefix(args, name+" "), " ", 2)
+	if len(option) == 2 {
+		rest = option[1]
+	}
+	return option[0], rest, true
+}
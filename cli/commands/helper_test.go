@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestRemoveDoubleQuotes(t *testing.T) {
@@ -36,3 +37,75 @@ func TestGetAllCommands(t *testing.T) {
 		}
 	})
 }
+
+func TestSplitTTLOption(t *testing.T) {
+	var tests = []struct {
+		input        string
+		expectedTTL  time.Duration
+		expectedRest string
+		isError      bool
+	}{
+		{"hello world", 0, "hello world", false},
+		{"-ttl 10m hello world", 10 * time.Minute, "hello world", false},
+		{"-ttl 1h", time.Hour, "", false},
+		{"-ttl ten hello", 0, "", true},
+		{"-ttl -5m hello", 0, "", true},
+	}
+	for _, test := range tests {
+		testname := fmt.Sprintf("Split TTL from '%s'", test.input)
+		t.Run(testname, func(t *testing.T) {
+			actualTTL, actualRest, err := SplitTTLOption(test.input)
+			if actualTTL != test.expectedTTL || actualRest != test.expectedRest || (err != nil) != test.isError {
+				t.Errorf("Expected (%v, %s, %v), got (%v, %s, %v)", test.expectedTTL, test.expectedRest, test.isError, actualTTL, actualRest, err)
+			}
+		})
+	}
+}
+
+func TestSplitEndOfOptions(t *testing.T) {
+	var tests = []struct {
+		input         string
+		expectedRest  string
+		expectedFound bool
+	}{
+		{"-- -encrypt", "-encrypt", true},
+		{"--", "", true},
+		{"-encrypt", "-encrypt", false},
+		{"--encrypt", "--encrypt", false},
+	}
+	for _, test := range tests {
+		testname := fmt.Sprintf("Split end of options from '%s'", test.input)
+		t.Run(testname, func(t *testing.T) {
+			actualRest, actualFound := SplitEndOfOptions(test.input)
+			if actualRest != test.expectedRest || actualFound != test.expectedFound {
+				t.Errorf("Expected (%s, %v), got (%s, %v)", test.expectedRest, test.expectedFound, actualRest, actualFound)
+			}
+		})
+	}
+}
+
+func TestSplitErasureOption(t *testing.T) {
+	var tests = []struct {
+		input               string
+		expectedDataShards  int
+		expectedTotalShards int
+		expectedRest        string
+		isError             bool
+	}{
+		{"file.txt", 0, 0, "file.txt", false},
+		{"-erasure 4/6 file.txt", 4, 6, "file.txt", false},
+		{"-erasure 4/4 file.txt", 0, 0, "", true},
+		{"-erasure 0/2 file.txt", 0, 0, "", true},
+		{"-erasure 4/6x file.txt", 0, 0, "", true},
+		{"-erasure four file.txt", 0, 0, "", true},
+	}
+	for _, test := range tests {
+		testname := fmt.Sprintf("Split erasure code from '%s'", test.input)
+		t.Run(testname, func(t *testing.T) {
+			actualDataShards, actualTotalShards, actualRest, err := SplitErasureOption(test.input)
+			if actualDataShards != test.expectedDataShards || actualTotalShards != test.expectedTotalShards || actualRest != test.expectedRest || (err != nil) != test.isError {
+				t.Errorf("Expected (%d/%d, %s, %v), got (%d/%d, %s, %v)", test.expectedDataShards, test.expectedTotalShards, test.expectedRest, test.isError, actualDataShards, actualTotalShards, actualRest, err)
+			}
+		})
+	}
+}
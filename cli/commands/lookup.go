@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"d7024e/kademlia"
+	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func TraceLookupOfID(context kademlia.IKademlia, args string) (string, error) {
+	targetID, err := ParseTargetID(context, args)
+	if err != nil {
+		return "", err
+	}
+	return FormatLookupTrace(context.TraceLookup(targetID)), nil
+}
+
+// Parse the ID to look up, or the ID of this node if none is given
+func ParseTargetID(context kademlia.IKademlia, args string) (*routing.KademliaID, error) {
+	args = RemoveDoubleQuotes(strings.TrimSpace(args))
+	if args == "" {
+		return context.GetMe().ID, nil
+	}
+	id := routing.NewKademliaID(args)
+	if id == nil || len(args) != routing.IDLength*2 {
+		return nil, fmt.Errorf("invalid ID %q, expected %d hex digits", args, routing.IDLength*2)
+	}
+	return id, nil
+}
+
+// Format a lookup trace as a tree, where each find node message is listed
+// under the one that returned its contact
+func FormatLookupTrace(trace rpc.LookupTrace) string {
+	rounds := 0
+	children := make(map[int][]int)
+	for i, step := range trace.Steps {
+		children[step.Parent] = append(children[step.Parent], i)
+		if step.Round > rounds {
+			rounds = step.Round
+		}
+	}
+
+	lines := []string{fmt.Sprintf("Lookup of %s: %d RPCs in %d rounds, %v", trace.Target, len(trace.Steps), rounds, trace.Duration.Round(time.Millisecond))}
+	var addSteps func(parent int, depth int)
+	addSteps = func(parent int, depth int) {
+		for _, i := range children[parent] {
+			lines = append(lines, strings.Repeat("  ", depth)+"- "+formatTraceStep(trace.Steps[i]))
+			addSteps(i, depth+1)
+		}
+	}
+	addSteps(kademlia.TRACE_NO_PARENT, 0)
+
+	lines = append(lines, fmt.Sprintf("Closest %d nodes:", len(trace.Closest)))
+	for _, contact := range trace.Closest {
+		lines = append(lines, fmt.Sprintf("   %s  %s", contact.ID, contact.Address))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatTraceStep(step rpc.TraceStep) string {
+	if step.Timeout {
+		return fmt.Sprintf("%s  %s  timeout after %v", step.Contact.ID, step.Contact.Address, step.RTT.Round(time.Millisecond))
+	}
+	progress := "not closer"
+	if step.Closer {
+		progress = "closer"
+	}
+	return fmt.Sprintf("%s  %s  %v  %d contacts, %s", step.Contact.ID, step.Contact.Address, step.RTT.Round(time.Microsecond), len(step.Returned), progress)
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceLookupOfID_ShouldShowStepsAsTree(t *testing.T) {
+	targetID := routing.NewKademliaID("0000000000000000000000000000000000000000")
+	nodeA := rpc.TracedContact{ID: "000000000000000000000000000000000000001f", Address: "nodeA"}
+	nodeB := rpc.TracedContact{ID: "000000000000000000000000000000000000000f", Address: "nodeB"}
+	nodeC := rpc.TracedContact{ID: "00000000000000000000000000000000000000ff", Address: "nodeC"}
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("TraceLookup", targetID).Return(rpc.LookupTrace{
+		Target: targetID.String(),
+		Steps: []rpc.TraceStep{
+			{Parent: -1, Round: 1, Contact: nodeA, RTT: 3 * time.Millisecond, Returned: []rpc.TracedContact{nodeB}, Closer: true},
+			{Parent: -1, Round: 1, Contact: nodeC, RTT: 2 * time.Second, Timeout: true},
+			{Parent: 0, Round: 2, Contact: nodeB, RTT: time.Millisecond, Returned: []rpc.TracedContact{}},
+		},
+		Closest:  []rpc.TracedContact{nodeB, nodeA},
+		Duration: 5 * time.Millisecond,
+	})
+
+	str, err := TraceLookupOfID(kademliaMock, targetID.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, `Lookup of 0000000000000000000000000000000000000000: 3 RPCs in 2 rounds, 5ms
+- 000000000000000000000000000000000000001f  nodeA  3ms  1 contacts, closer
+  - 000000000000000000000000000000000000000f  nodeB  1ms  0 contacts, not closer
+- 00000000000000000000000000000000000000ff  nodeC  timeout after 2s
+Closest 2 nodes:
+   000000000000000000000000000000000000000f  nodeB
+   000000000000000000000000000000000000001f  nodeA`, str)
+}
+
+func TestTraceLookupOfID_WithoutID_ShouldLookupMe(t *testing.T) {
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000fff"), "me")
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetMe").Return(&me)
+	kademliaMock.On("TraceLookup", me.ID).Return(rpc.LookupTrace{Target: me.ID.String()})
+
+	str, err := TraceLookupOfID(kademliaMock, "")
+
+	assert.Nil(t, err)
+	assert.Contains(t, str, "Lookup of 0000000000000000000000000000000000000fff: 0 RPCs in 0 rounds")
+}
+
+func TestTraceLookupOfID_WithInvalidID_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, err := TraceLookupOfID(kademliaMock, "xyz")
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"d7024e/kademlia"
+	"d7024e/kademlia/network/routing"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func AnnounceProviderOfKey(context kademlia.IKademlia, args string) (string, error) {
+	ttl, args, err := SplitTTLOption(args)
+	if err != nil {
+		return "", err
+	}
+	args, _ = SplitEndOfOptions(args)
+	if args == "" {
+		return "", errors.New("expected 1 argument, but got 0")
+	}
+
+	key := RemoveDoubleQuotes(args)
+	if err := context.AnnounceProvider(key, ttl); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Announced this node as a provider of %s", key), nil
+}
+
+func GetProvidersByKey(context kademlia.IKademlia, args string) (string, error) {
+	providers, err := FetchProvidersByKey(context, args)
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, len(providers))
+	for i, provider := range providers {
+		lines[i] = provider.String()
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Find the contacts that can serve the content of a key.
+func FetchProvidersByKey(context kademlia.IKademlia, args string) ([]routing.Contact, error) {
+	if args == "" {
+		return nil, errors.New("expected 1 argument, but got 0")
+	}
+
+	providers := context.FindProviders(RemoveDoubleQuotes(args))
+	if len(providers) == 0 {
+		return nil, errors.New("no providers found")
+	}
+	return providers, nil
+}
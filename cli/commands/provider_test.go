@@ -0,0 +1,53 @@
+package commands
+
+import (
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/network/routing"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnounceProviderOfKey_ShouldAnnounceWithTTL(t *testing.T) {
+	key := "2d8a0e0b0f1d5b5e2c0ac4d7f8bfb1b1e2a8c9f0"
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("AnnounceProvider", key, 10*time.Minute).Return(nil)
+
+	str, err := AnnounceProviderOfKey(kademliaMock, "-ttl 10m "+key)
+
+	assert.Nil(t, err)
+	assert.Contains(t, str, key)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestAnnounceProviderOfKey_WithMissingKey_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, err := AnnounceProviderOfKey(kademliaMock, "")
+
+	assert.NotNil(t, err)
+}
+
+func TestGetProvidersByKey_ShouldListProviders(t *testing.T) {
+	key := "2d8a0e0b0f1d5b5e2c0ac4d7f8bfb1b1e2a8c9f0"
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
+	nodeB := routing.NewContact(routing.NewKademliaID("00000000000000000000000000000000000000F0"), "nodeB")
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("FindProviders", key).Return([]routing.Contact{nodeA, nodeB})
+
+	str, err := GetProvidersByKey(kademliaMock, key)
+
+	assert.Nil(t, err)
+	assert.Equal(t, nodeA.String()+"\n"+nodeB.String(), str)
+}
+
+func TestGetProvidersByKey_WithoutProviders_ShouldReturnError(t *testing.T) {
+	key := "2d8a0e0b0f1d5b5e2c0ac4d7f8bfb1b1e2a8c9f0"
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("FindProviders", key).Return([]routing.Contact{})
+
+	_, err := GetProvidersByKey(kademliaMock, key)
+
+	assert.NotNil(t, err)
+}
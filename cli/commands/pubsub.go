@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"d7024e/kademlia"
+	"d7024e/kademlia/network"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Publications to the topics subscribed to from the CLI are written here as
+// they arrive
+var publicationOutput io.Writer = os.Stdout
+
+// Subscriptions opened from the CLI, by topic
+var (
+	subscriptions     = make(map[string]*network.Subscription)
+	subscriptionsLock sync.Mutex
+)
+
+func SubscribeToTopic(context kademlia.IKademlia, args string) (string, error) {
+	if args == "" {
+		return "", errors.New("expected 1 argument, but got 0")
+	}
+	topic := RemoveDoubleQuotes(args)
+
+	subscriptionsLock.Lock()
+	defer subscriptionsLock.Unlock()
+	if _, exists := subscriptions[topic]; exists {
+		return "", fmt.Errorf("already subscribed to %s", topic)
+	}
+
+	sub, err := context.Subscribe(topic)
+	if err != nil {
+		return "", err
+	}
+	subscriptions[topic] = sub
+	go printPublications(sub)
+	return fmt.Sprintf("Subscribed to %s", topic), nil
+}
+
+func UnsubscribeFromTopic(context kademlia.IKademlia, args string) (string, error) {
+	if args == "" {
+		return "", errors.New("expected 1 argument, but got 0")
+	}
+	topic := RemoveDoubleQuotes(args)
+
+	subscriptionsLock.Lock()
+	defer subscriptionsLock.Unlock()
+	sub, exists := subscriptions[topic]
+	if !exists {
+		return "", fmt.Errorf("not subscribed to %s", topic)
+	}
+
+	delete(subscriptions, topic)
+	if err := context.Unsubscribe(sub); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Unsubscribed from %s", topic), nil
+}
+
+func PublishToTopic(context kademlia.IKademlia, args string) (string, error) {
+	topicAndMessage := strings.SplitN(args, " ", 2)
+	if len(topicAndMessage) < 2 {
+		return "", fmt.Errorf("expected 2 arguments, but got %d", len(strings.Fields(args)))
+	}
+
+	topic := RemoveDoubleQuotes(topicAndMessage[0])
+	message := RemoveDoubleQuotes(topicAndMessage[1])
+	if err := context.Publish(topic, []byte(message)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Published to %s", topic), nil
+}
+
+// Write the publications of a subscription until it is closed
+func printPublications(sub *network.Subscription) {
+	for pub := range sub.C {
+		fmt.Fprintf(publicationOutput, "[%s] %s\n", pub.Topic, pub.Data)
+	}
+}
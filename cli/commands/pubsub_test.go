@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"bufio"
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/network"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSubscribeToTopic_ShouldPrintPublicationsUntilUnsubscribed(t *testing.T) {
+	reader, writer := io.Pipe()
+	publicationOutput = writer
+	defer func() { publicationOutput = os.Stdout }()
+	broker := network.NewBroker()
+	sub, _ := broker.Subscribe("news")
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Subscribe", "news").Return(sub, nil)
+	kademliaMock.On("Unsubscribe", sub).Run(func(args mock.Arguments) { broker.Unsubscribe(sub) }).Return(nil)
+
+	str, err := SubscribeToTopic(kademliaMock, "news")
+	_, errAgain := SubscribeToTopic(kademliaMock, "news")
+	broker.Deliver(network.NewPublication("news", []byte("hello")), time.Now())
+	line, _ := bufio.NewReader(reader).ReadString('\n')
+	_, errUnsubscribe := UnsubscribeFromTopic(kademliaMock, "news")
+	_, errUnsubscribeAgain := UnsubscribeFromTopic(kademliaMock, "news")
+
+	assert.Nil(t, err)
+	assert.Contains(t, str, "news")
+	assert.Equal(t, "[news] hello\n", line)
+	assert.NotNil(t, errAgain)
+	assert.Nil(t, errUnsubscribe)
+	assert.NotNil(t, errUnsubscribeAgain)
+	assert.False(t, broker.IsSubscribed("news"))
+}
+
+func TestSubscribeToTopic_WhenSubscribeFails_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Subscribe", "failing").Return(nil, errors.New("no suitable contacts"))
+
+	_, err := SubscribeToTopic(kademliaMock, "failing")
+	_, errUnsubscribe := UnsubscribeFromTopic(kademliaMock, "failing")
+
+	assert.NotNil(t, err)
+	assert.NotNil(t, errUnsubscribe)
+}
+
+func TestPublishToTopic_ShouldPublishMessage(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Publish", "news", []byte("hello world")).Return(nil)
+
+	_, err := PublishToTopic(kademliaMock, "news hello world")
+
+	assert.Nil(t, err)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPublishToTopic_WithMissingMessage_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, err := PublishToTopic(kademliaMock, "news")
+
+	assert.NotNil(t, err)
+}
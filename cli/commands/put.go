@@ -2,20 +2,96 @@ package commands
 
 import (
 	"d7024e/kademlia"
+	"d7024e/kademlia/encryption"
 	"errors"
+	"strings"
+	"time"
 )
 
 func PutObjectInStore(context kademlia.IKademlia, args string) (string, error) {
+	var ttl time.Duration
+	var encrypt bool
+	var key []byte
+
+	// Options may be given in any order
+	for strings.HasPrefix(args, "-") {
+		var err error
+		if strings.HasPrefix(args, "-ttl ") {
+			ttl, args, err = SplitTTLOption(args)
+		} else if rest, found := SplitFlag(args, "-encrypt"); found {
+			encrypt, args = true, rest
+		} else if value, rest, found := SplitOption(args, "-key"); found {
+			encrypt, args = true, rest
+			key, err = encryption.ParseKey(value)
+		} else if rest, found := SplitEndOfOptions(args); found {
+			args = rest
+			break
+		} else {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
 	if args == "" {
 		return "", errors.New("expected 1 argument, but got 0")
 	}
 
 	cleanContent := RemoveDoubleQuotes(args)
-	dataToSend := []byte(cleanContent)
-	value, err := context.Store(dataToSend)
+	return PutValueInStore(context, []byte(cleanContent), ttl, encrypt, key)
+}
+
+// Store a value as is, or encrypted if `encrypt` is set.
+//
+// Parameters:
+//
+//	`data` - The value to store.
+//	`ttl` - How long the value lives without being fetched.
+//	`encrypt` - Whether to encrypt the value before it is stored.
+//	`key` - The key to encrypt with. If nil, a key is derived from the value.
+//
+// Returns:
+//
+//	The hash of the value, or the capability string of an encrypted value.
+func PutValueInStore(context kademlia.IKademlia, data []byte, ttl time.Duration, encrypt bool, key []byte) (string, error) {
+	if encrypt {
+		return PutEncryptedObjectInStore(context, data, key, ttl)
+	}
+
+	value, err := context.Store(data, ttl)
 	if err == nil {
 		return value, nil
 	} else {
 		return "", err
 	}
 }
+
+// Encrypt a value and store the ciphertext.
+//
+// Parameters:
+//
+//	`data` - The value to encrypt.
+//	`key` - The key to encrypt with. If nil, a key is derived from the value.
+//	`ttl` - How long the value lives without being fetched.
+//
+// Returns:
+//
+//	The capability string needed to fetch and decrypt the value.
+func PutEncryptedObjectInStore(context kademlia.IKademlia, data []byte, key []byte, ttl time.Duration) (string, error) {
+	var ciphertext []byte
+	var err error
+	if key == nil {
+		key, ciphertext, err = encryption.EncryptConvergent(data)
+	} else {
+		ciphertext, err = encryption.Encrypt(key, data)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := context.Store(ciphertext, ttl)
+	if err != nil {
+		return "", err
+	}
+	return encryption.Capability{Hash: hash, Key: key}.String(), nil
+}
@@ -2,8 +2,11 @@ package commands
 
 import (
 	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/encryption"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -12,7 +15,7 @@ import (
 func TestPutObjectInStore(t *testing.T) {
 	expectedHash := "myhash"
 	kademliaMock := new(mocks.KademliaMockObject)
-	kademliaMock.On("Store", mock.Anything).Return(expectedHash, nil)
+	kademliaMock.On("Store", mock.Anything, mock.Anything).Return(expectedHash, nil)
 
 	actual, err := PutObjectInStore(kademliaMock, "myhash")
 	assert.Equal(t, expectedHash, actual)
@@ -21,8 +24,63 @@ func TestPutObjectInStore(t *testing.T) {
 
 func TestPutObjectInStoreShouldReturnError(t *testing.T) {
 	kademliaMock := new(mocks.KademliaMockObject)
-	kademliaMock.On("Store", mock.Anything).Return("", errors.New("error"))
+	kademliaMock.On("Store", mock.Anything, mock.Anything).Return("", errors.New("error"))
 
 	_, err := PutObjectInStore(kademliaMock, "")
 	assert.NotNil(t, err)
 }
+
+func TestPutObjectInStore_WithTTL_ShouldStoreWithTTL(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Store", []byte("my message"), 10*time.Minute).Return("myhash", nil)
+
+	_, err := PutObjectInStore(kademliaMock, "-ttl 10m my message")
+	assert.Nil(t, err)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPutObjectInStore_WithEndOfOptions_ShouldStoreRestAsIs(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Store", []byte("-ttl 10m is not an option"), 5*time.Minute).Return("myhash", nil)
+
+	_, err := PutObjectInStore(kademliaMock, "-ttl 5m -- -ttl 10m is not an option")
+	assert.Nil(t, err)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPutObjectInStore_WithEncrypt_ShouldStoreCiphertext(t *testing.T) {
+	key, ciphertext, _ := encryption.EncryptConvergent([]byte("my message"))
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Store", ciphertext, time.Minute).Return("myhash", nil)
+
+	actual, err := PutObjectInStore(kademliaMock, "-encrypt -ttl 1m my message")
+
+	assert.Nil(t, err)
+	assert.Equal(t, encryption.Capability{Hash: "myhash", Key: key}.String(), actual)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPutObjectInStore_WithKey_ShouldEncryptWithKey(t *testing.T) {
+	key := strings.Repeat("ab", encryption.KEY_SIZE)
+	var stored []byte
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Store", mock.Anything, time.Duration(0)).Run(func(args mock.Arguments) {
+		stored = args.Get(0).([]byte)
+	}).Return("myhash", nil)
+
+	actual, err := PutObjectInStore(kademliaMock, "-key "+key+" my message")
+	capability, _ := encryption.ParseCapability(actual)
+	decrypted, _ := encryption.Decrypt(capability.Key, stored)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "myhash:"+key, actual)
+	assert.Equal(t, []byte("my message"), decrypted)
+}
+
+func TestPutObjectInStore_WithInvalidKey_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, err := PutObjectInStore(kademliaMock, "-key abcd my message")
+
+	assert.ErrorIs(t, err, encryption.ErrInvalidKey)
+}
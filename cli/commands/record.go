@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"d7024e/kademlia"
+	"d7024e/kademlia/record"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func GenerateRecordKey(context kademlia.IKademlia, args string) (string, error) {
+	if args == "" {
+		return "", errors.New("expected 1 argument, but got 0")
+	}
+
+	path := RemoveDoubleQuotes(args)
+	privateKey, err := record.GenerateKeyFile(path)
+	if err != nil {
+		return "", err
+	}
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	return fmt.Sprintf("Wrote private key to %s\nRecord key: %s", path, record.Key(publicKey)), nil
+}
+
+func PutRecordInStore(context kademlia.IKademlia, args string) (string, error) {
+	ttl, args, err := SplitTTLOption(args)
+	if err != nil {
+		return "", err
+	}
+	args, _ = SplitEndOfOptions(args)
+	keyfileAndValue := strings.SplitN(args, " ", 2)
+	if len(keyfileAndValue) < 2 {
+		return "", fmt.Errorf("expected 2 arguments, but got %d", len(strings.Fields(args)))
+	}
+
+	privateKey, err := record.ReadKeyFile(RemoveDoubleQuotes(keyfileAndValue[0]))
+	if err != nil {
+		return "", err
+	}
+	value := []byte(RemoveDoubleQuotes(keyfileAndValue[1]))
+	rec, err := PublishRecordValue(context, privateKey, value, ttl)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Published version %d of record %s", rec.Sequence, rec.Key()), nil
+}
+
+// Sign and publish a new version of the record of a private key. The new
+// version follows the newest version found in the network.
+//
+// Returns the published record.
+func PublishRecordValue(context kademlia.IKademlia, privateKey ed25519.PrivateKey, value []byte, ttl time.Duration) (*record.Record, error) {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	sequence := uint64(1)
+	if current := context.LookupRecord(record.Key(publicKey)); current != nil {
+		sequence = current.Sequence + 1
+	}
+
+	rec := record.New(privateKey, sequence, value)
+	if err := context.PublishRecord(rec, ttl); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func GetRecordByKey(context kademlia.IKademlia, args string) (string, error) {
+	rec, err := FetchRecordByKey(context, args)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n\nVersion %d", rec.Value, rec.Sequence), nil
+}
+
+// Find the newest version of a record in the network by its key.
+func FetchRecordByKey(context kademlia.IKademlia, args string) (*record.Record, error) {
+	if args == "" {
+		return nil, errors.New("expected 1 argument, but got 0")
+	}
+
+	rec := context.LookupRecord(RemoveDoubleQuotes(args))
+	if rec == nil {
+		return nil, errors.New("record not found")
+	}
+	return rec, nil
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/record"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGenerateRecordKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.key")
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	str, err := GenerateRecordKey(kademliaMock, path)
+	privateKey, errRead := record.ReadKeyFile(path)
+
+	assert.Nil(t, err)
+	assert.Nil(t, errRead)
+	assert.Contains(t, str, record.Key(privateKey.Public().(ed25519.PublicKey)))
+}
+
+func TestPutRecordInStore_ShouldPublishNextVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.key")
+	privateKey, _ := record.GenerateKeyFile(path)
+	current := record.New(privateKey, 3, []byte("old"))
+	expected := record.New(privateKey, 4, []byte("my config"))
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupRecord", current.Key()).Return(current)
+	kademliaMock.On("PublishRecord", expected, 10*time.Minute).Return(nil)
+
+	str, err := PutRecordInStore(kademliaMock, "-ttl 10m "+path+" my config")
+
+	assert.Nil(t, err)
+	assert.Contains(t, str, "version 4")
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPutRecordInStore_WithNewRecord_ShouldPublishFirstVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.key")
+	privateKey, _ := record.GenerateKeyFile(path)
+	expected := record.New(privateKey, 1, []byte("my config"))
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupRecord", expected.Key()).Return(nil)
+	kademliaMock.On("PublishRecord", expected, time.Duration(0)).Return(nil)
+
+	_, err := PutRecordInStore(kademliaMock, path+" my config")
+
+	assert.Nil(t, err)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPutRecordInStore_WithMissingArguments_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, err := PutRecordInStore(kademliaMock, "record.key")
+
+	assert.NotNil(t, err)
+}
+
+func TestGetRecordByKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.key")
+	privateKey, _ := record.GenerateKeyFile(path)
+	rec := record.New(privateKey, 2, []byte("my config"))
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupRecord", rec.Key()).Return(rec)
+	kademliaMock.On("LookupRecord", mock.Anything).Return(nil)
+
+	str, err := GetRecordByKey(kademliaMock, rec.Key())
+	_, errNotFound := GetRecordByKey(kademliaMock, "other")
+
+	assert.Nil(t, err)
+	assert.Contains(t, str, "my config")
+	assert.Contains(t, str, "Version 2")
+	assert.NotNil(t, errNotFound)
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"d7024e/kademlia"
+	"d7024e/kademlia/datastore"
+	"d7024e/util"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Outcome of importing a snapshot
+type ImportSummary struct {
+	// Dataobjects in the snapshot
+	Entries int
+	// Dataobjects added to the datastore. Keys that already existed are kept,
+	// and invalid, forgotten or expired dataobjects are left out.
+	Restored int
+	// Restored dataobjects stored again at the closest nodes of their hash
+	Republished int
+}
+
+func ExportStore(context kademlia.IKademlia, args string) (string, error) {
+	if args == "" {
+		return "", errors.New("expected 1 argument, but got 0")
+	}
+
+	path := RemoveDoubleQuotes(args)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	count, err := ExportSnapshot(context, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Exported %d dataobjects to %s", count, path), nil
+}
+
+func ImportStore(context kademlia.IKademlia, args string) (string, error) {
+	if args == "" {
+		return "", errors.New("expected 1 argument, but got 0")
+	}
+
+	path := RemoveDoubleQuotes(args)
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	summary, err := ImportSnapshot(context, file)
+	if err != nil && summary.Restored > 0 {
+		return "", fmt.Errorf("imported %d of %d dataobjects, and republished %d: %w", summary.Restored, summary.Entries, summary.Republished, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Imported %d of %d dataobjects from %s, and republished %d", summary.Restored, summary.Entries, path, summary.Republished), nil
+}
+
+// Write a snapshot of the datastore of the node.
+//
+// Returns the number of dataobjects in the snapshot.
+func ExportSnapshot(context kademlia.IKademlia, w io.Writer) (int, error) {
+	entries := context.GetDataStore().Snapshot()
+	if err := datastore.WriteSnapshot(w, entries, time.Now()); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// Add the dataobjects of a snapshot to the datastore of the node, and store
+// the restored replicas again, with the TTL they were stored with, at the
+// current closest nodes of their hash.
+//
+// Dataobjects are checked as a STORE would be: values that do not hash to
+// their key, and dataobjects this node has a tombstone of, are not restored.
+// Cached copies are only restored on this node. The dataobjects are restored
+// as they are read, so if the datastore refuses one, or the rest of the
+// snapshot is invalid, the ones before it are still restored and republished.
+func ImportSnapshot(context kademlia.IKademlia, r io.Reader) (ImportSummary, error) {
+	reader, err := datastore.NewSnapshotReader(r, datastore.SNAPSHOT_MAX_SIZE)
+	if err != nil {
+		return ImportSummary{}, err
+	}
+	defer reader.Close()
+
+	summary := ImportSummary{}
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return summary, nil
+		}
+		if err != nil {
+			return summary, err
+		}
+		summary.Entries++
+		if util.Hash(entry.Value) != entry.Key || context.GetNetwork().IsForgotten(entry.Key) {
+			continue
+		}
+
+		restored, err := context.GetDataStore().Restore([]datastore.SnapshotEntry{entry})
+		for _, entry := range restored {
+			summary.Restored++
+			if entry.Cached {
+				continue
+			}
+			stored, err := context.Republish(entry.Key, entry.Value, entry.TTL, entry.Publisher)
+			if err == nil && stored > 0 {
+				summary.Republished++
+			}
+		}
+		if err != nil {
+			return summary, err
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/datastore"
+	"d7024e/util"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExportStore_ShouldBeImportedByImportStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.gz")
+	expiration := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	replica := datastore.NewSnapshotEntry(util.Hash([]byte("value1")), []byte("value1"), time.Hour, expiration, []byte("publisher"), false)
+	cached := datastore.NewSnapshotEntry(util.Hash([]byte("value2")), []byte("value2"), time.Minute, expiration, nil, true)
+	entries := []datastore.SnapshotEntry{replica, cached}
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("Snapshot").Return(entries)
+	storeMock.On("Restore", []datastore.SnapshotEntry{replica}).Return([]datastore.SnapshotEntry{replica}, nil)
+	storeMock.On("Restore", []datastore.SnapshotEntry{cached}).Return([]datastore.SnapshotEntry{cached}, nil)
+	networkMock := new(mocks.NetworkMockObject)
+	networkMock.On("IsForgotten", mock.Anything).Return(false)
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetDataStore").Return(storeMock)
+	kademliaMock.On("GetNetwork").Return(networkMock)
+	kademliaMock.On("Republish", replica.Key, replica.Value, time.Hour, ed25519.PublicKey(replica.Publisher)).Return(3, nil).Once()
+
+	exported, errExport := ExportStore(kademliaMock, path)
+	imported, errImport := ImportStore(kademliaMock, path)
+
+	assert.Nil(t, errExport)
+	assert.Nil(t, errImport)
+	assert.Contains(t, exported, "Exported 2 dataobjects")
+	assert.Contains(t, imported, "Imported 2 of 2 dataobjects")
+	assert.Contains(t, imported, "republished 1")
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestImportSnapshot_ShouldNotRestoreInvalidOrForgottenEntries(t *testing.T) {
+	expiration := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	valid := datastore.NewSnapshotEntry(util.Hash([]byte("value1")), []byte("value1"), time.Minute, expiration, nil, true)
+	forgotten := datastore.NewSnapshotEntry(util.Hash([]byte("value2")), []byte("value2"), time.Minute, expiration, nil, true)
+	record := datastore.NewSnapshotEntry("record:key", []byte("record"), time.Minute, expiration, nil, false)
+	buf := new(bytes.Buffer)
+	datastore.WriteSnapshot(buf, []datastore.SnapshotEntry{valid, forgotten, record}, time.Now())
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("Restore", []datastore.SnapshotEntry{valid}).Return([]datastore.SnapshotEntry{valid}, nil).Once()
+	networkMock := new(mocks.NetworkMockObject)
+	networkMock.On("IsForgotten", valid.Key).Return(false)
+	networkMock.On("IsForgotten", forgotten.Key).Return(true)
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetDataStore").Return(storeMock)
+	kademliaMock.On("GetNetwork").Return(networkMock)
+
+	summary, err := ImportSnapshot(kademliaMock, buf)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ImportSummary{Entries: 3, Restored: 1}, summary)
+	storeMock.AssertExpectations(t)
+}
+
+func TestImportSnapshot_WhenRestoreFails_ShouldRepublishRestored(t *testing.T) {
+	expiration := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	replica := datastore.NewSnapshotEntry(util.Hash([]byte("value1")), []byte("value1"), time.Minute, expiration, nil, false)
+	other := datastore.NewSnapshotEntry(util.Hash([]byte("value2")), []byte("value2"), time.Minute, expiration, nil, false)
+	buf := new(bytes.Buffer)
+	datastore.WriteSnapshot(buf, []datastore.SnapshotEntry{replica, other}, time.Now())
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("Restore", []datastore.SnapshotEntry{replica}).Return([]datastore.SnapshotEntry{replica}, nil)
+	storeMock.On("Restore", []datastore.SnapshotEntry{other}).Return([]datastore.SnapshotEntry{}, datastore.ErrStoreFull)
+	networkMock := new(mocks.NetworkMockObject)
+	networkMock.On("IsForgotten", mock.Anything).Return(false)
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetDataStore").Return(storeMock)
+	kademliaMock.On("GetNetwork").Return(networkMock)
+	kademliaMock.On("Republish", replica.Key, replica.Value, time.Minute, ed25519.PublicKey(nil)).Return(3, nil)
+
+	summary, err := ImportSnapshot(kademliaMock, buf)
+
+	assert.ErrorIs(t, err, datastore.ErrStoreFull)
+	assert.Equal(t, ImportSummary{Entries: 2, Restored: 1, Republished: 1}, summary)
+}
+
+func TestImportSnapshot_WhenCorruptedAfterEntry_ShouldKeepRestored(t *testing.T) {
+	expiration := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	replica := datastore.NewSnapshotEntry(util.Hash([]byte("value1")), []byte("value1"), time.Minute, expiration, nil, false)
+	corrupted := datastore.NewSnapshotEntry(util.Hash([]byte("value2")), []byte("value2"), time.Minute, expiration, nil, false)
+	corrupted.Value = []byte("value3")
+	buf := new(bytes.Buffer)
+	datastore.WriteSnapshot(buf, []datastore.SnapshotEntry{replica, corrupted}, time.Now())
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("Restore", []datastore.SnapshotEntry{replica}).Return([]datastore.SnapshotEntry{replica}, nil).Once()
+	networkMock := new(mocks.NetworkMockObject)
+	networkMock.On("IsForgotten", mock.Anything).Return(false)
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetDataStore").Return(storeMock)
+	kademliaMock.On("GetNetwork").Return(networkMock)
+	kademliaMock.On("Republish", replica.Key, replica.Value, time.Minute, ed25519.PublicKey(nil)).Return(3, nil)
+
+	summary, err := ImportSnapshot(kademliaMock, buf)
+
+	assert.ErrorIs(t, err, datastore.ErrSnapshotChecksum)
+	assert.Equal(t, ImportSummary{Entries: 1, Restored: 1, Republished: 1}, summary)
+	storeMock.AssertExpectations(t)
+}
+
+func TestImportSnapshot_WithOtherFile_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, err := ImportSnapshot(kademliaMock, bytes.NewReader([]byte("not a snapshot")))
+
+	assert.ErrorIs(t, err, datastore.ErrSnapshotFormat)
+}
+
+func TestImportStore_WithMissingFile_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, errMissing := ImportStore(kademliaMock, filepath.Join(t.TempDir(), "missing.gz"))
+	_, errNoArgs := ImportStore(kademliaMock, "")
+
+	assert.NotNil(t, errMissing)
+	assert.NotNil(t, errNoArgs)
+}
@@ -2,9 +2,46 @@ package commands
 
 import (
 	"d7024e/kademlia"
-	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 )
 
 func GetStatus(context kademlia.IKademlia, args string) (string, error) {
-	return "", errors.New("not implemented yet")
+	status := context.Status()
+
+	lines := []string{
+		fmt.Sprintf("Node %s at %s", status.ID, strings.Join(append([]string{status.Address}, status.Addresses...), ", ")),
+		fmt.Sprintf("Up %v, %s", status.Uptime.Round(time.Second), status.JoinState),
+		fmt.Sprintf("Routing table: %d contacts in %d buckets", status.Contacts, len(status.Buckets)),
+	}
+	for _, bucket := range status.Buckets {
+		lines = append(lines, fmt.Sprintf("  bucket %d: %d", bucket.Index, bucket.Contacts))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Store: %d dataobjects, %d bytes (%d cached, %d bytes)", status.Store.Entries, status.Store.Bytes, status.Store.CachedEntries, status.Store.CachedBytes),
+		"Sent: "+formatRPCCounts(status.Network.Sent),
+		"Received: "+formatRPCCounts(status.Network.Received),
+		fmt.Sprintf("Timeouts: %d, average RTT %v", status.Network.Timeouts, status.Network.AverageRTT.Round(time.Microsecond)),
+	)
+	return strings.Join(lines, "\n"), nil
+}
+
+// Format RPC counts as "NAME count" pairs in name order
+func formatRPCCounts(counts map[string]uint64) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	return strings.Join(pairs, ", ")
 }
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/datastore"
+	"d7024e/kademlia/network"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStatus_ShouldListStatus(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Status").Return(network.NodeStatus{
+		ID:        "0000000000000000000000000000000000000001",
+		Address:   "10.0.0.1:4000",
+		Uptime:    90*time.Second + time.Millisecond,
+		JoinState: "joined",
+		Contacts:  3,
+		Buckets:   []network.BucketFill{{Index: 158, Contacts: 1}, {Index: 159, Contacts: 2}},
+		Store:     datastore.Stats{Entries: 2, Bytes: 12},
+		Network: network.NetworkStats{
+			Sent:       map[string]uint64{"PING": 2, "FIND_NODE": 5},
+			Received:   map[string]uint64{},
+			Timeouts:   1,
+			AverageRTT: 3 * time.Millisecond,
+		},
+	})
+
+	str, err := GetStatus(kademliaMock, "")
+
+	assert.Nil(t, err)
+	assert.Contains(t, str, "Node 0000000000000000000000000000000000000001 at 10.0.0.1:4000")
+	assert.Contains(t, str, "Up 1m30s, joined")
+	assert.Contains(t, str, "Routing table: 3 contacts in 2 buckets\n  bucket 158: 1\n  bucket 159: 2")
+	assert.Contains(t, str, "Store: 2 dataobjects, 12 bytes")
+	assert.Contains(t, str, "Sent: FIND_NODE 5, PING 2")
+	assert.Contains(t, str, "Received: none")
+	assert.Contains(t, str, "Timeouts: 1, average RTT 3ms")
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"d7024e/kademlia"
+	"d7024e/kademlia/datastore"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Number of dataobjects listed by the store command unless -limit is given
+const STORE_DEFAULT_LIMIT = 50
+
+func ListStore(context kademlia.IKademlia, args string) (string, error) {
+	limit, after, err := SplitLimitOption(args, STORE_DEFAULT_LIMIT)
+	if err != nil {
+		return "", err
+	}
+
+	store := context.GetDataStore()
+	stats := store.Stats()
+	entries := store.Entries(RemoveDoubleQuotes(after), limit)
+
+	lines := []string{fmt.Sprintf("%d dataobjects, %d bytes (%d cached, %d bytes)", stats.Entries, stats.Bytes, stats.CachedEntries, stats.CachedBytes)}
+	for _, entry := range entries {
+		lines = append(lines, FormatEntry(entry, time.Now()))
+	}
+	if len(entries) == limit {
+		lines = append(lines, fmt.Sprintf("More: store -limit %d %s", limit, entries[len(entries)-1].Key))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Format the metadata of a dataobject as one line
+func FormatEntry(entry datastore.Entry, now time.Time) string {
+	kind := "replica"
+	if entry.Cached {
+		kind = "cached"
+	}
+	return fmt.Sprintf("%s  %d bytes  expires in %v  %s", entry.Key, entry.Size, entry.Expiration.Sub(now).Round(time.Second), kind)
+}
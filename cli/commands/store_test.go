@@ -0,0 +1,45 @@
+package commands
+
+import (
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/datastore"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListStore_ShouldListEntriesWithStats(t *testing.T) {
+	expiration := time.Now().Add(time.Hour)
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("Stats").Return(datastore.Stats{Entries: 3, Bytes: 19, CachedEntries: 1, CachedBytes: 7})
+	storeMock.On("Entries").Return([]datastore.Entry{
+		{Key: "key1", Size: 6, Expiration: expiration},
+		{Key: "key2", Size: 7, Expiration: expiration, Cached: true},
+	})
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetDataStore").Return(storeMock)
+
+	str, err := ListStore(kademliaMock, "-limit 2")
+
+	assert.Nil(t, err)
+	assert.Contains(t, str, "3 dataobjects, 19 bytes (1 cached, 7 bytes)")
+	assert.Contains(t, str, "key1  6 bytes")
+	assert.Contains(t, str, "key2  7 bytes")
+	assert.Contains(t, str, "More: store -limit 2 key2")
+}
+
+func TestListStore_WithInvalidLimit_ShouldReturnError(t *testing.T) {
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	_, err := ListStore(kademliaMock, "-limit none")
+
+	assert.NotNil(t, err)
+}
+
+func TestFormatEntry(t *testing.T) {
+	now := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := datastore.Entry{Key: "key1", Size: 6, Expiration: now.Add(90 * time.Second), Cached: true}
+
+	assert.Equal(t, "key1  6 bytes  expires in 1m30s  cached", FormatEntry(entry, now))
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"d7024e/kademlia/datastore"
+	"d7024e/logging"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Every parameter of a node can be given in a config file, as an environment
+// variable and as a flag, and a flag overrides the environment, which
+// overrides the file. The config file is a JSON object keyed by the names of
+// the parameters, e.g. {"port": 14041, "k": 20, "request_timeout": "2s"}.
+// The environment variable of a parameter is its name in upper case with the
+// KADEMLIA_ prefix, e.g. KADEMLIA_REQUEST_TIMEOUT.
+
+const ENV_PREFIX = "KADEMLIA_"
+
+// Name of the parameter that holds the path of the config file. It can only
+// be given as a flag or in the environment.
+const CONFIG_FILE_PARAMETER = "config"
+
+// The parameters of a node
+type Config struct {
+	Port int
+	// Address to listen on, instead of every interface on the port
+	Listen string
+	// Comma separated addresses other nodes reach this node at, if not the
+	// one it listens on, e.g. behind port mapping
+	Advertise     string
+	BootstrapNode string
+	Verbose       bool
+	LogLevel      string
+	LogFormat     string
+
+	// Number of closest nodes a lookup returns, and a value is stored at
+	K int
+	// Number of requests a lookup sends at once
+	Alpha int
+	// Most contacts kept in each bucket of the routing table
+	BucketSize int
+	// How long to wait for the response to a request
+	RequestTimeout time.Duration
+	// TTL of stored dataobjects that are not given one
+	TTL time.Duration
+	// Address the REST API listens on
+	RESTAddress string
+
+	DataDir        string
+	MaxBytes       int
+	MaxEntries     int
+	MaxValueSize   int
+	EvictionPolicy string
+	ExpiryActions  string
+}
+
+// Get the config of a node that is given no parameters
+func Default() Config {
+	return Config{
+		LogLevel:       "info",
+		LogFormat:      "text",
+		K:              20,
+		Alpha:          3,
+		BucketSize:     20,
+		RequestTimeout: 2 * time.Second,
+		TTL:            time.Hour,
+		RESTAddress:    ":8081",
+		ExpiryActions:  "metric",
+	}
+}
+
+// A parameter is bound to a field of the config under its name, and to a
+// flag that may be shorter.
+type parameter struct {
+	name string
+	flag string
+	bind func(fs *flag.FlagSet)
+}
+
+func parameters(config *Config) []parameter {
+	intParameter := func(name string, flagName string, field *int, usage string) parameter {
+		return parameter{name, flagName, func(fs *flag.FlagSet) {
+			fs.IntVar(field, flagName, *field, usage)
+		}}
+	}
+	stringParameter := func(name string, flagName string, field *string, usage string) parameter {
+		return parameter{name, flagName, func(fs *flag.FlagSet) {
+			fs.StringVar(field, flagName, *field, usage)
+		}}
+	}
+	durationParameter := func(name string, flagName string, field *time.Duration, usage string) parameter {
+		return parameter{name, flagName, func(fs *flag.FlagSet) {
+			fs.DurationVar(field, flagName, *field, usage)
+		}}
+	}
+	boolParameter := func(name string, flagName string, field *bool, usage string) parameter {
+		return parameter{name, flagName, func(fs *flag.FlagSet) {
+			fs.BoolVar(field, flagName, *field, usage)
+		}}
+	}
+
+	return []parameter{
+		intParameter("port", "p", &config.Port, "Portnumber"),
+		stringParameter("listen", "listen", &config.Listen, "Address to listen on, e.g. 127.0.0.1:14041. If empty, every interface on the port"),
+		stringParameter("advertise", "advertise", &config.Advertise, "Comma separated addresses other nodes reach this node at, with or without port. If empty, the listen address or the IPs of this computer"),
+		stringParameter("bootstrap_node", "b", &config.BootstrapNode, "Adress of bootstrap node"),
+		boolParameter("verbose", "v", &config.Verbose, "Indicates if a log should be created"),
+		stringParameter("log_level", "log-level", &config.LogLevel, "Lowest level that is logged: debug, info, warn or error"),
+		stringParameter("log_format", "log-format", &config.LogFormat, "Format of the log: text, or json for one object per line"),
+		intParameter("k", "k", &config.K, "Number of closest nodes a lookup returns, and a value is stored at"),
+		intParameter("alpha", "alpha", &config.Alpha, "Number of requests a lookup sends at once"),
+		intParameter("bucket_size", "bucket-size", &config.BucketSize, "Most contacts kept in each bucket of the routing table"),
+		durationParameter("request_timeout", "request-timeout", &config.RequestTimeout, "How long to wait for the response to a request"),
+		durationParameter("ttl", "ttl", &config.TTL, "TTL of stored values that are not given one"),
+		stringParameter("rest_address", "rest", &config.RESTAddress, "Address the REST API listens on"),
+		stringParameter("data_dir", "d", &config.DataDir, "Directory to persist the datastore in. If empty, data is only kept in memory"),
+		intParameter("max_bytes", "max-bytes", &config.MaxBytes, "Maximum total size of stored values in bytes, 0 for no limit"),
+		intParameter("max_entries", "max-entries", &config.MaxEntries, "Maximum number of stored values, 0 for no limit"),
+		intParameter("max_value_size", "max-value-size", &config.MaxValueSize, "Maximum size of a single stored value in bytes, 0 for no limit"),
+		stringParameter("eviction_policy", "eviction", &config.EvictionPolicy, "Eviction policy when the datastore is full: lru, farthest, expiry or none"),
+		stringParameter("on_expired", "on-expired", &config.ExpiryActions, "Comma separated actions to take when a stored value expires: republish, metric and log"),
+	}
+}
+
+// Get the environment variable of a parameter
+func EnvName(name string) string {
+	return ENV_PREFIX + strings.ToUpper(name)
+}
+
+// Load the config of a node from its command line arguments, the environment
+// and the config file given by either, and validate it.
+//
+// Parameters:
+//
+//	`fs` - The flag set to parse the arguments with, e.g. flag.CommandLine.
+//	`args` - The command line arguments, without the program name.
+//	`lookupEnv` - Looks up an environment variable, e.g. os.LookupEnv.
+//
+// Returns:
+//
+//	The config, or an error naming the parameter that is missing or invalid.
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	config := Default()
+	params := parameters(&config)
+
+	// Flags are bound under their flag names, and the file and environment
+	// set them through the same flag set, under the name of the parameter
+	byName := make(map[string]parameter)
+	for _, param := range params {
+		param.bind(fs)
+		byName[param.name] = param
+	}
+	configFile, _ := lookupEnv(EnvName(CONFIG_FILE_PARAMETER))
+	fs.StringVar(&configFile, CONFIG_FILE_PARAMETER, configFile, "Path of a JSON config file, overridden by the environment and flags")
+
+	if err := fs.Parse(args); err != nil {
+		return config, err
+	}
+	fromFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { fromFlags[f.Name] = true })
+
+	set := func(param parameter, value string, source string) error {
+		if fromFlags[param.flag] {
+			return nil
+		}
+		if err := fs.Set(param.flag, value); err != nil {
+			return fmt.Errorf("invalid %s in %s: %w", param.name, source, err)
+		}
+		return nil
+	}
+
+	if configFile != "" {
+		values, err := readFile(configFile)
+		if err != nil {
+			return config, err
+		}
+		for name, value := range values {
+			param, exists := byName[name]
+			if !exists {
+				return config, fmt.Errorf("unknown parameter %q in %s", name, configFile)
+			}
+			if err := set(param, value, configFile); err != nil {
+				return config, err
+			}
+		}
+	}
+	for _, param := range params {
+		if value, found := lookupEnv(EnvName(param.name)); found {
+			if err := set(param, value, EnvName(param.name)); err != nil {
+				return config, err
+			}
+		}
+	}
+
+	return config, config.Validate()
+}
+
+// Read the values of a config file, as they would be given as flags
+func readFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+	defer file.Close()
+
+	var object map[string]json.RawMessage
+	if err := json.NewDecoder(file).Decode(&object); err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(object))
+	for name, raw := range object {
+		// Strings are unquoted, and numbers and booleans kept as they are
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// Check that the parameters can be used by a node
+func (config Config) Validate() error {
+	var errs []string
+	check := func(valid bool, format string, args ...interface{}) {
+		if !valid {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(config.Port >= 0 && config.Port <= 65535, "port must be between 0 and 65535, got %d", config.Port)
+	check(config.K > 0, "k must be positive, got %d", config.K)
+	check(config.Alpha > 0, "alpha must be positive, got %d", config.Alpha)
+	check(config.BucketSize > 0, "bucket_size must be positive, got %d", config.BucketSize)
+	check(config.RequestTimeout > 0, "request_timeout must be positive, got %v", config.RequestTimeout)
+	check(config.TTL > 0, "ttl must be positive, got %v", config.TTL)
+	if config.Listen != "" {
+		_, _, err := net.SplitHostPort(config.Listen)
+		check(err == nil, "listen must be a host and port, got %q", config.Listen)
+	}
+	check(config.RESTAddress != "", "rest_address must not be empty")
+	check(config.MaxBytes >= 0, "max_bytes must not be negative, got %d", config.MaxBytes)
+	check(config.MaxEntries >= 0, "max_entries must not be negative, got %d", config.MaxEntries)
+	check(config.MaxValueSize >= 0, "max_value_size must not be negative, got %d", config.MaxValueSize)
+	if _, err := logging.ParseLevel(config.LogLevel); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if _, err := logging.ParseFormat(config.LogFormat); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if _, err := datastore.ParseEvictionPolicy(config.EvictionPolicy, nil); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if _, err := ParseExpiryActions(config.ExpiryActions); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid config: " + strings.Join(errs, ", "))
+	}
+	return nil
+}
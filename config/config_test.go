@@ -0,0 +1,85 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, found := env[name]
+		return value, found
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "kademlia.json")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad_WithNoParameters_ShouldReturnDefault(t *testing.T) {
+	config, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, lookupIn(nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), config)
+}
+
+func TestLoad_WithAllSources_ShouldPreferFlagsThenEnvironment(t *testing.T) {
+	path := writeConfigFile(t, `{"k": 10, "alpha": 5, "bucket_size": 8, "request_timeout": "500ms", "verbose": true}`)
+	env := map[string]string{
+		"KADEMLIA_CONFIG":          path,
+		"KADEMLIA_ALPHA":           "2",
+		"KADEMLIA_REQUEST_TIMEOUT": "1s",
+	}
+
+	config, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-request-timeout", "3s", "-p", "4000"}, lookupIn(env))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 10, config.K)
+	assert.Equal(t, 2, config.Alpha)
+	assert.Equal(t, 8, config.BucketSize)
+	assert.Equal(t, 3*time.Second, config.RequestTimeout)
+	assert.Equal(t, 4000, config.Port)
+	assert.True(t, config.Verbose)
+}
+
+func TestLoad_WithUnknownParameterInFile_ShouldFail(t *testing.T) {
+	path := writeConfigFile(t, `{"bucketsize": 8}`)
+
+	_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path}, lookupIn(nil))
+
+	assert.ErrorContains(t, err, `unknown parameter "bucketsize"`)
+}
+
+func TestLoad_WithInvalidEnvironment_ShouldNameVariable(t *testing.T) {
+	env := map[string]string{"KADEMLIA_TTL": "an hour"}
+
+	_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, lookupIn(env))
+
+	assert.ErrorContains(t, err, "invalid ttl in KADEMLIA_TTL")
+}
+
+func TestValidate_WithInvalidValues_ShouldListThem(t *testing.T) {
+	config := Default()
+	config.K = 0
+	config.RequestTimeout = -time.Second
+	config.LogLevel = "loud"
+	config.Listen = "14041"
+	config.EvictionPolicy = "random"
+	config.ExpiryActions = "metric, email"
+
+	err := config.Validate()
+
+	assert.ErrorContains(t, err, "k must be positive, got 0")
+	assert.ErrorContains(t, err, "request_timeout must be positive, got -1s")
+	assert.ErrorContains(t, err, `unknown log level "loud"`)
+	assert.ErrorContains(t, err, `listen must be a host and port, got "14041"`)
+	assert.ErrorContains(t, err, `unknown eviction policy "random"`)
+	assert.ErrorContains(t, err, `unknown expiry action "email"`)
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names of the built-in actions a node can take when a dataobject expires,
+// given as a comma separated list in ExpiryActions
+const (
+	EXPIRY_ACTION_REPUBLISH = "republish"
+	EXPIRY_ACTION_METRIC    = "metric"
+	EXPIRY_ACTION_LOG       = "log"
+)
+
+// Split a comma separated list of expiry actions into their names, leaving
+// out empty ones.
+//
+// Returns an error naming the first action that is not built-in.
+func ParseExpiryActions(names string) ([]string, error) {
+	actions := []string{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case EXPIRY_ACTION_REPUBLISH, EXPIRY_ACTION_METRIC, EXPIRY_ACTION_LOG:
+			actions = append(actions, name)
+		default:
+			return nil, fmt.Errorf("unknown expiry action %q, expected %s, %s or %s", name, EXPIRY_ACTION_REPUBLISH, EXPIRY_ACTION_METRIC, EXPIRY_ACTION_LOG)
+		}
+	}
+	return actions, nil
+}
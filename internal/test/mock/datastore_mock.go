@@ -2,6 +2,8 @@ package mock
 
 import (
 	"d7024e/internal/test/mock/util"
+	"d7024e/kademlia/datastore"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -16,9 +18,29 @@ func (store *DataStoreMockObject) Get(key string) (value []byte, exists bool) {
 	return util.GetArrayOrNil[byte](args, 0), args.Bool(1)
 }
 
-func (store *DataStoreMockObject) Set(key string, value []byte) (ok bool) {
+func (store *DataStoreMockObject) Set(key string, value []byte, ttl time.Duration) error {
 	args := store.Called()
-	return args.Bool(0)
+	return args.Error(0)
+}
+
+func (store *DataStoreMockObject) SetPublished(key string, value []byte, ttl time.Duration, publisher []byte) error {
+	args := store.Called()
+	return args.Error(0)
+}
+
+func (store *DataStoreMockObject) SetCached(key string, value []byte, ttl time.Duration, publisher []byte) error {
+	args := store.Called()
+	return args.Error(0)
+}
+
+func (store *DataStoreMockObject) Publisher(key string) (publisher []byte, exists bool) {
+	args := store.Called()
+	return util.GetArrayOrNil[byte](args, 0), args.Bool(1)
+}
+
+func (store *DataStoreMockObject) Update(key string, value []byte, ttl time.Duration, accept func(current []byte) bool) error {
+	args := store.Called()
+	return args.Error(0)
 }
 
 func (store *DataStoreMockObject) Remove(key string) (value []byte, ok bool) {
@@ -28,7 +50,46 @@ func (store *DataStoreMockObject) Remove(key string) (value []byte, ok bool) {
 
 func (store *DataStoreMockObject) RemoveExpired() {}
 
+func (store *DataStoreMockObject) TTL(key string) (ttl time.Duration, exists bool) {
+	args := store.Called()
+	return args.Get(0).(time.Duration), args.Bool(1)
+}
+
 func (store *DataStoreMockObject) Refresh(key string) (ok bool) {
 	args := store.Called()
 	return args.Bool(0)
 }
+
+func (store *DataStoreMockObject) Range(fn func(entry datastore.Entry) bool) {
+	args := store.Called()
+	for _, entry := range util.GetArrayOrNil[datastore.Entry](args, 0) {
+		if !fn(entry) {
+			return
+		}
+	}
+}
+
+func (store *DataStoreMockObject) Entries(after string, limit int) []datastore.Entry {
+	args := store.Called()
+	return util.GetArrayOrNil[datastore.Entry](args, 0)
+}
+
+func (store *DataStoreMockObject) Stats() datastore.Stats {
+	args := store.Called()
+	return args.Get(0).(datastore.Stats)
+}
+
+func (store *DataStoreMockObject) Snapshot() []datastore.SnapshotEntry {
+	args := store.Called()
+	return util.GetArrayOrNil[datastore.SnapshotEntry](args, 0)
+}
+
+func (store *DataStoreMockObject) Restore(entries []datastore.SnapshotEntry) ([]datastore.SnapshotEntry, error) {
+	args := store.Called(entries)
+	return util.GetArrayOrNil[datastore.SnapshotEntry](args, 0), args.Error(1)
+}
+
+func (store *DataStoreMockObject) OnExpiry(listener datastore.ExpiryListener) (remove func()) {
+	args := store.Called(listener)
+	return args.Get(0).(func())
+}
@@ -1,10 +1,15 @@
 package mock
 
 import (
+	"crypto/ed25519"
 	"d7024e/internal/test/mock/util"
 	"d7024e/kademlia/datastore"
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
+	"d7024e/kademlia/record"
+	"d7024e/logging"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -32,23 +37,72 @@ func (k KademliaMockObject) LookupContact(targetID *routing.KademliaID) []routin
 	return util.GetArrayOrNil[routing.Contact](args, 0)
 }
 
-func (k KademliaMockObject) LookupData(hash string) ([]byte, *routing.Contact) {
+func (k KademliaMockObject) LookupData(hash string) ([]byte, *routing.Contact, time.Duration) {
 	args := k.Called(hash)
 
 	data := util.GetArrayOrNil[byte](args, 0)
 	contact := util.GetPointerOrNil[routing.Contact](args, 1)
-	return data, contact
+	return data, contact, args.Get(2).(time.Duration)
 }
 
-func (k KademliaMockObject) Store(data []byte) (string, error) {
-	args := k.Called(data)
+func (k KademliaMockObject) Store(data []byte, ttl time.Duration) (string, error) {
+	args := k.Called(data, ttl)
 
 	return args.String(0), args.Error(1)
 }
 
-func (k KademliaMockObject) ForgetData(hash string, contacts []routing.Contact) error {
+func (k KademliaMockObject) Republish(hash string, data []byte, ttl time.Duration, publisher ed25519.PublicKey) (int, error) {
+	args := k.Called(hash, data, ttl, publisher)
+
+	return args.Int(0), args.Error(1)
+}
+
+func (k KademliaMockObject) ForgetData(hash string, contacts []routing.Contact) ([]rpc.ForgetResponse, error) {
 	args := k.Called(hash)
 
+	results, _ := args.Get(0).([]rpc.ForgetResponse)
+	return results, args.Error(1)
+}
+
+func (k KademliaMockObject) PublishRecord(rec *record.Record, ttl time.Duration) error {
+	args := k.Called(rec, ttl)
+
+	return args.Error(0)
+}
+
+func (k KademliaMockObject) LookupRecord(key string) *record.Record {
+	args := k.Called(key)
+
+	return util.GetPointerOrNil[record.Record](args, 0)
+}
+
+func (k KademliaMockObject) AnnounceProvider(key string, ttl time.Duration) error {
+	args := k.Called(key, ttl)
+
+	return args.Error(0)
+}
+
+func (k KademliaMockObject) FindProviders(key string) []routing.Contact {
+	args := k.Called(key)
+
+	return util.GetArrayOrNil[routing.Contact](args, 0)
+}
+
+func (k KademliaMockObject) Subscribe(topic string) (*network.Subscription, error) {
+	args := k.Called(topic)
+
+	return util.GetPointerOrNil[network.Subscription](args, 0), args.Error(1)
+}
+
+func (k KademliaMockObject) Unsubscribe(sub *network.Subscription) error {
+	args := k.Called(sub)
+
+	return args.Error(0)
+}
+
+func (k KademliaMockObject) Publish(topic string, data []byte) error {
+	args := k.Called(topic, data)
+
 	return args.Error(0)
 }
 
@@ -57,3 +111,17 @@ func (k KademliaMockObject) JoinNetwork(contact *routing.Contact, retries int) b
 
 	return args.Bool(0)
 }
+
+func (k KademliaMockObject) TraceLookup(targetID *routing.KademliaID) rpc.LookupTrace {
+	args := k.Called(targetID)
+	return args.Get(0).(rpc.LookupTrace)
+}
+
+func (k KademliaMockObject) GetLogger() *logging.Logger {
+	return nil
+}
+
+func (k KademliaMockObject) Status() network.NodeStatus {
+	args := k.Called()
+	return args.Get(0).(network.NodeStatus)
+}
@@ -5,6 +5,7 @@ import (
 	"d7024e/kademlia/datastore"
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
+	"d7024e/logging"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -29,19 +30,38 @@ func (net *NetworkMockObject) GetDatastore() datastore.IDataStore {
 	return util.GetPointerOrNil[DataStoreMockObject](args, 0)
 }
 
+func (net *NetworkMockObject) GetBroker() *network.Broker {
+	args := net.Called()
+	return util.GetPointerOrNil[network.Broker](args, 0)
+}
+
+func (net *NetworkMockObject) GetLogger() *logging.Logger {
+	return nil
+}
+
+func (net *NetworkMockObject) GetStats() network.NetworkStats {
+	args := net.Called()
+	return args.Get(0).(network.NetworkStats)
+}
+
+func (net *NetworkMockObject) IsForgotten(key string) bool {
+	args := net.Called(key)
+	return args.Bool(0)
+}
+
 func (net *NetworkMockObject) NewNetworkMessage(
 	rpc int,
 	sender *routing.Contact,
 	target *routing.Contact,
 	bodyDigest string,
-	body string,
+	body []byte,
 	contacts []routing.Contact,
 ) *network.NetworkMessage {
 	args := net.Called(rpc, sender, target, bodyDigest, body, contacts)
 	return util.GetPointerOrNil[network.NetworkMessage](args, 0)
 }
 
-func (net *NetworkMockObject) Listen() {}
+func (net *NetworkMockObject) Listen() error { return nil }
 
 func (net *NetworkMockObject) StopListen() {}
 
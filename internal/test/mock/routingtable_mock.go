@@ -30,3 +30,8 @@ func (rt *RoutingTableMockObject) Nodes() []routing.Contact {
 	args := rt.Called()
 	return util.GetArrayOrNil[routing.Contact](args, 0)
 }
+
+func (rt *RoutingTableMockObject) BucketLengths() []int {
+	args := rt.Called()
+	return util.GetArrayOrNil[int](args, 0)
+}
@@ -0,0 +1,196 @@
+package datastore
+
+import (
+	"d7024e/kademlia/network/routing"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrKeyExists     = errors.New("key already exists")
+	ErrValueTooLarge = errors.New("value exceeds maximum size")
+	ErrStoreFull     = errors.New("datastore is full")
+
+	ErrUpdateRejected = errors.New("update rejected")
+)
+
+// Limits on how much a datastore may hold. A limit of zero means no limit.
+type Capacity struct {
+	// Maximum total size of all values, in bytes
+	MaxBytes int
+	// Maximum number of dataobjects
+	MaxEntries int
+	// Maximum size of a single value, in bytes
+	MaxValueSize int
+	// Decides which dataobjects to evict when the datastore is full. If nil,
+	// nothing is evicted and new dataobjects are refused instead.
+	Policy EvictionPolicy
+}
+
+// A dataobject that may be evicted to make room for a new one.
+type EvictionCandidate struct {
+	Key        string
+	Size       int
+	Expiration time.Time
+	LastAccess time.Time
+}
+
+type EvictionPolicy interface {
+	// Less returns true if `a` should be evicted before `b`.
+	Less(a, b *EvictionCandidate) bool
+}
+
+// Evict the least recently used dataobject first.
+type LRUPolicy struct{}
+
+func (LRUPolicy) Less(a, b *EvictionCandidate) bool {
+	return a.LastAccess.Before(b.LastAccess)
+}
+
+// Evict the dataobject that expires soonest first.
+type SoonestExpiryPolicy struct{}
+
+func (SoonestExpiryPolicy) Less(a, b *EvictionCandidate) bool {
+	return a.Expiration.Before(b.Expiration)
+}
+
+// Evict the dataobject whose key is farthest from the node's own ID first.
+// These are the dataobjects the node is least responsible for. Keys that are
+// not valid Kademlia IDs are considered farthest.
+type FarthestKeyPolicy struct {
+	ID *routing.KademliaID
+}
+
+func (policy FarthestKeyPolicy) Less(a, b *EvictionCandidate) bool {
+	idA := routing.NewKademliaID(a.Key)
+	idB := routing.NewKademliaID(b.Key)
+	if idA == nil || idB == nil {
+		return idA == nil && idB != nil
+	}
+	return idB.CalcDistance(policy.ID).Less(idA.CalcDistance(policy.ID))
+}
+
+// Get an eviction policy by name.
+//
+// Parameters:
+//
+//	`name` - One of "lru", "farthest", "expiry" or "none".
+//	`me` - The ID of this node, used by the "farthest" policy.
+//
+// Returns:
+//
+//	The eviction policy, or nil for "none". An error if the name is unknown.
+func ParseEvictionPolicy(name string, me *routing.KademliaID) (EvictionPolicy, error) {
+	switch name {
+	case "lru":
+		return LRUPolicy{}, nil
+	case "farthest":
+		return FarthestKeyPolicy{me}, nil
+	case "expiry":
+		return SoonestExpiryPolicy{}, nil
+	case "none", "":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown eviction policy %q", name)
+}
+
+// Set the capacity limits of the datastore. Dataobjects already in the
+// datastore are not evicted until a new dataobject is added.
+func (store *DataStore) SetCapacity(capacity Capacity) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.capacity = capacity
+}
+
+// Make room for a new value of the given size, evicting dataobjects if
+// required. The lock must be held by the caller.
+func (store *DataStore) reserve(size int) error {
+	capacity := store.capacity
+	if capacity.MaxValueSize > 0 && size > capacity.MaxValueSize {
+		return ErrValueTooLarge
+	}
+	if capacity.MaxBytes > 0 && size > capacity.MaxBytes {
+		return ErrValueTooLarge
+	}
+	if store.fits(size) {
+		return nil
+	}
+
+	// Expired dataobjects are free to remove
+	for key, dataobject := range store.dataobjects {
+		if dataobject.IsExpired(store.time) {
+			store._expire(key, dataobject)
+		}
+	}
+	if store.fits(size) {
+		return nil
+	}
+	if capacity.Policy == nil {
+		return ErrStoreFull
+	}
+
+	// Each victim is found in a single pass, and usually one is enough
+	for !store.fits(size) {
+		victim, found := store.victim()
+		if !found {
+			return ErrStoreFull
+		}
+		store._remove(victim)
+		store.logger.Debug("Evicted dataobject", "key", victim)
+	}
+	return nil
+}
+
+// Get the key of the dataobject the eviction policy evicts first, or false
+// if the datastore is empty. The lock must be held by the caller.
+func (store *DataStore) victim() (string, bool) {
+	var victim, candidate EvictionCandidate
+	found := false
+	for key, dataobject := range store.dataobjects {
+		candidate = EvictionCandidate{
+			Key:        key,
+			Size:       len(dataobject.Value),
+			Expiration: dataobject.Expiration,
+			LastAccess: dataobject.LastAccess,
+		}
+		if !found || store.capacity.Policy.Less(&candidate, &victim) {
+			victim = candidate
+			found = true
+		}
+	}
+	return victim.Key, found
+}
+
+// Check if a new value of the given size fits within the limits of the
+// datastore. The lock must be held by the caller.
+func (store *DataStore) fits(size int) bool {
+	if store.capacity.MaxEntries > 0 && len(store.dataobjects)+1 > store.capacity.MaxEntries {
+		return false
+	}
+	if store.capacity.MaxBytes > 0 && store.usedBytes+size > store.capacity.MaxBytes {
+		return false
+	}
+	return true
+}
+
+// Put a dataobject in the datastore, replacing any with the same key, and
+// keep count of the total size of the values. The lock must be held by the
+// caller.
+func (store *DataStore) put(key string, dataobject dataObject) {
+	if current, exists := store.dataobjects[key]; exists {
+		store.usedBytes -= len(current.Value)
+	}
+	store.dataobjects[key] = dataobject
+	store.usedBytes += len(dataobject.Value)
+}
+
+// Delete a dataobject from the datastore, and keep count of the total size
+// of the values. The lock must be held by the caller.
+func (store *DataStore) drop(key string) {
+	if current, exists := store.dataobjects[key]; exists {
+		store.usedBytes -= len(current.Value)
+		delete(store.dataobjects, key)
+	}
+}
@@ -0,0 +1,169 @@
+package datastore
+
+import (
+	"d7024e/kademlia/network/routing"
+	"d7024e/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataStore_Set_WithValueLargerThanMaxValueSize_ShouldRefuse(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		dataStore := createNewDatastore(time.Hour, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC))
+		dataStore.SetCapacity(Capacity{MaxValueSize: 4})
+
+		assert.ErrorIs(t, dataStore.Set("key1", []byte("value1"), 0), ErrValueTooLarge)
+		assert.Nil(t, dataStore.Set("key2", []byte("val2"), 0))
+	})
+}
+
+func TestDataStore_Set_WhenFullWithoutPolicy_ShouldRefuse(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		dataStore := createNewDatastore(time.Hour, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC))
+		dataStore.SetCapacity(Capacity{MaxEntries: 2})
+
+		assert.Nil(t, dataStore.Set("key1", []byte("value1"), 0))
+		assert.Nil(t, dataStore.Set("key2", []byte("value2"), 0))
+		assert.ErrorIs(t, dataStore.Set("key3", []byte("value3"), 0), ErrStoreFull)
+		assert.Equal(t, 2, len(dataStore.dataobjects))
+	})
+}
+
+func TestDataStore_Set_WhenFull_ShouldRemoveExpiredFirst(t *testing.T) {
+	currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	dataStore := populate(t, createMemoryDatastore(time.Hour, currentDate), map[string]dataObject{
+		"key1": {Value: []byte("value1"), Expiration: currentDate.Add(-time.Minute)},
+		"key2": {Value: []byte("value2"), Expiration: currentDate.Add(time.Minute)},
+	})
+	dataStore.SetCapacity(Capacity{MaxEntries: 2})
+
+	err := dataStore.Set("key3", []byte("value3"), 0)
+
+	assert.Nil(t, err)
+	assert.NotContains(t, dataStore.dataobjects, "key1")
+	assert.Contains(t, dataStore.dataobjects, "key2")
+	assert.Contains(t, dataStore.dataobjects, "key3")
+}
+
+func TestDataStore_Set_WhenFull_ShouldNotifyRemovedExpired(t *testing.T) {
+	currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeProvider := &util.FakeTimeProvider{InternalTime: currentDate.Add(-time.Hour)}
+	var expiredKeys []string
+	dataStore := NewDataStore(time.Hour, func(key string, value []byte) {
+		expiredKeys = append(expiredKeys, key)
+	}, timeProvider)
+	dataStore.SetCapacity(Capacity{MaxEntries: 1})
+	dataStore.Set("key1", []byte("value1"), time.Minute)
+	timeProvider.InternalTime = currentDate
+
+	err := dataStore.Set("key2", []byte("value2"), 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key1"}, expiredKeys)
+}
+
+func TestDataStore_ShouldCountUsedBytes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		acceptAll := func(current []byte) bool { return true }
+
+		dataStore.Set("key1", []byte("value1"), 0)
+		dataStore.Set("key2", []byte("value22"), 0)
+		dataStore.Update("key1", []byte("v1"), 0, acceptAll)
+		dataStore.Remove("key2")
+		dataStore.Set("key3", []byte("value333"), 0)
+		dataStore = reopen(t, dataStore)
+
+		assert.Equal(t, len("v1")+len("value333"), dataStore.usedBytes)
+	})
+}
+
+func TestDataStore_Set_WhenFull_ShouldEvictByPolicy(t *testing.T) {
+	currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	me := routing.NewKademliaID("0000000000000000000000000000000000000000")
+	keyNear := "0000000000000000000000000000000000000001"
+	keyFar := "F000000000000000000000000000000000000000"
+
+	var tests = []struct {
+		name           string
+		policy         EvictionPolicy
+		expectedEvicts string
+	}{
+		{"LRU", LRUPolicy{}, keyFar},
+		{"Soonest expiry", SoonestExpiryPolicy{}, keyNear},
+		{"Farthest key", FarthestKeyPolicy{me}, keyFar},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The far key is accessed first, and expires last
+			dataStore := createMemoryDatastore(time.Hour, currentDate.Add(-2*time.Minute))
+			dataStore.SetCapacity(Capacity{MaxBytes: 12, Policy: test.policy})
+			dataStore.Set(keyFar, []byte("value1"), 4*time.Minute)
+			dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate.Add(-time.Minute)
+			dataStore.Set(keyNear, []byte("value2"), 2*time.Minute)
+			dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate
+
+			err := dataStore.Set("key3", []byte("value3"), 0)
+
+			assert.Nil(t, err)
+			assert.Equal(t, 2, len(dataStore.dataobjects))
+			assert.NotContains(t, dataStore.dataobjects, test.expectedEvicts)
+		})
+	}
+}
+
+func TestDataStore_Set_WhenFull_ShouldEvictUntilValueFits(t *testing.T) {
+	currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	dataStore := createMemoryDatastore(time.Hour, currentDate)
+	dataStore.SetCapacity(Capacity{MaxBytes: 20, Policy: SoonestExpiryPolicy{}})
+	dataStore.Set("key1", []byte("value1"), 3*time.Minute)
+	dataStore.Set("key2", []byte("value2"), time.Minute)
+	dataStore.Set("key3", []byte("value3"), 2*time.Minute)
+
+	err := dataStore.Set("key4", []byte("a longer value"), 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(dataStore.dataobjects))
+	assert.Contains(t, dataStore.dataobjects, "key1")
+	assert.Contains(t, dataStore.dataobjects, "key4")
+}
+
+func TestDataStore_Set_WithValueLargerThanMaxBytes_ShouldNotEvict(t *testing.T) {
+	currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	dataStore := createMemoryDatastore(time.Hour, currentDate)
+	dataStore.SetCapacity(Capacity{MaxBytes: 8, Policy: LRUPolicy{}})
+	dataStore.Set("key1", []byte("value1"), 0)
+
+	err := dataStore.Set("key2", []byte("a much longer value"), 0)
+
+	assert.ErrorIs(t, err, ErrValueTooLarge)
+	assert.Contains(t, dataStore.dataobjects, "key1")
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	me := routing.NewRandomKademliaID()
+	var tests = []struct {
+		name     string
+		expected EvictionPolicy
+		isError  bool
+	}{
+		{"lru", LRUPolicy{}, false},
+		{"farthest", FarthestKeyPolicy{me}, false},
+		{"expiry", SoonestExpiryPolicy{}, false},
+		{"none", nil, false},
+		{"random", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := ParseEvictionPolicy(test.name, me)
+
+			assert.Equal(t, test.expected, actual)
+			assert.Equal(t, test.isError, err != nil)
+		})
+	}
+}
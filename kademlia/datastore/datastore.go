@@ -1,8 +1,10 @@
 package datastore
 
 import (
+	"bytes"
+	"d7024e/logging"
 	"d7024e/util"
-	"log"
+	"sort"
 	"sync"
 	"time"
 )
@@ -25,10 +27,71 @@ type IDataStore interface {
 	// Parameters:
 	// 	`key` - The key to add.
 	// 	`value` - The value to add.
+	// 	`ttl` - How long the dataobject lives without being refreshed. If zero,
+	// 	the default expiration time of the datastore is used.
 	//
 	// Returns:
-	// 	True if the key was added. Otherwise false.
-	Set(key string, value []byte) (ok bool)
+	// 	Nil if the key was added. Otherwise an error with the reason it was
+	// 	refused, such as ErrKeyExists or ErrStoreFull.
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// Add a new dataobject to the datastore, like Set, and record who
+	// published it.
+	//
+	// Parameters:
+	// 	`key` - The key to add.
+	// 	`value` - The value to add.
+	// 	`ttl` - How long the dataobject lives without being refreshed. If zero,
+	// 	the default expiration time of the datastore is used.
+	// 	`publisher` - The public key of the publisher of the dataobject.
+	//
+	// Returns:
+	// 	Nil if the key was added, or if a replica with the same value and
+	// 	publisher was refreshed with `ttl`. Otherwise an error with the
+	// 	reason it was refused, such as ErrKeyExists or ErrStoreFull.
+	SetPublished(key string, value []byte, ttl time.Duration, publisher []byte) error
+
+	// Add a copy of a dataobject cached by a lookup, like SetPublished. The
+	// copy is told apart from the replicas stored by its publisher.
+	//
+	// Parameters:
+	// 	`key` - The key to add.
+	// 	`value` - The value to add.
+	// 	`ttl` - How long the dataobject lives without being refreshed. If zero,
+	// 	the default expiration time of the datastore is used.
+	// 	`publisher` - The public key of the publisher of the dataobject.
+	//
+	// Returns:
+	// 	Nil if the key was added. Otherwise an error with the reason it was
+	// 	refused, such as ErrKeyExists or ErrStoreFull.
+	SetCached(key string, value []byte, ttl time.Duration, publisher []byte) error
+
+	// Get the public key of the publisher of a dataobject.
+	//
+	// Parameters:
+	// 	`key` - The key to search for.
+	//
+	// Returns:
+	// 	The public key of the publisher and exists will be true. Otherwise
+	// 	publisher is nil and exists will be false. The publisher is also nil
+	// 	if the dataobject was added without one.
+	Publisher(key string) (publisher []byte, exists bool)
+
+	// Add a dataobject to the datastore, or replace the value of an existing
+	// one if `accept` allows it. Used for mutable values, such as records.
+	//
+	// Parameters:
+	// 	`key` - The key to add or update.
+	// 	`value` - The new value.
+	// 	`ttl` - How long the dataobject lives without being refreshed. If zero,
+	// 	the default expiration time of the datastore is used.
+	// 	`accept` - Called with the current value if the key exists. The value
+	// 	is only replaced if it returns true.
+	//
+	// Returns:
+	// 	Nil if the value was added or replaced. ErrUpdateRejected if `accept`
+	// 	returned false, or another error if the value was refused.
+	Update(key string, value []byte, ttl time.Duration, accept func(current []byte) bool) error
 
 	// Remove a key/value pair from the datastore.
 	//
@@ -43,6 +106,16 @@ type IDataStore interface {
 	// Remove all expired dataobjects from the datastore.
 	RemoveExpired()
 
+	// Get the time left until a dataobject expires.
+	//
+	// Parameters:
+	// 	`key` - The key to search for.
+	//
+	// Returns:
+	// 	The remaining TTL of the dataobject and exists will be true.
+	// 	Otherwise ttl is zero and exists will be false.
+	TTL(key string) (ttl time.Duration, exists bool)
+
 	// Refresh the TTL of a dataobject associated with a given key.
 	//
 	// Parameters:
@@ -51,16 +124,67 @@ type IDataStore interface {
 	// Returns:
 	// 	True if the dataobject was found and refreshed. Otherwise, false.
 	Refresh(key string) (ok bool)
-}
 
-type keyValuePair struct {
-	Key   string
-	Value []byte
+	// Call a function with the metadata of every dataobject, in key order,
+	// until it returns false. Neither the TTL nor the last access of the
+	// dataobjects is changed.
+	//
+	// Parameters:
+	// 	`fn` - Called with each dataobject. Returns whether to continue.
+	Range(fn func(entry Entry) bool)
+
+	// Get the metadata of the dataobjects in key order, one page at a time.
+	//
+	// Parameters:
+	// 	`after` - Only keys after this key are returned. Empty for the first
+	// 	page, and the last key of the previous page for the next.
+	// 	`limit` - The most entries to return. Zero for no limit.
+	//
+	// Returns:
+	// 	The metadata of the dataobjects, without their values.
+	Entries(after string, limit int) []Entry
+
+	// Get totals over all dataobjects, such as their number and size.
+	Stats() Stats
+
+	// Take a snapshot of every dataobject, with the time left until it
+	// expires. Neither the TTL nor the last access of the dataobjects is
+	// changed.
+	//
+	// Returns:
+	// 	The dataobjects in key order, ready to be written with WriteSnapshot.
+	Snapshot() []SnapshotEntry
+
+	// Add the dataobjects of a snapshot, each with the time it had left when
+	// the snapshot was taken. Keys that already exist are kept as they are.
+	//
+	// Parameters:
+	// 	`entries` - The dataobjects to add, e.g. read with ReadSnapshot.
+	//
+	// Returns:
+	// 	The dataobjects that were added. If a dataobject is refused, such as
+	// 	with ErrStoreFull, the ones added before it and the reason.
+	Restore(entries []SnapshotEntry) ([]SnapshotEntry, error)
+
+	// Add a listener that is called with every dataobject that expires, when
+	// it is removed by the janitor or found expired.
+	//
+	// Parameters:
+	// 	`listener` - Called with the expired dataobject. Must not block, as
+	// 	expirations are told to the listeners one at a time.
+	//
+	// Returns:
+	// 	A function that removes the listener.
+	OnExpiry(listener ExpiryListener) (remove func())
 }
 
 type dataObject struct {
 	Expiration time.Time
+	LastAccess time.Time
+	TTL        time.Duration
 	Value      []byte
+	Publisher  []byte
+	Cached     bool
 }
 
 type DataStore struct {
@@ -70,6 +194,22 @@ type DataStore struct {
 	dataobjects       map[string]dataObject
 	lock              sync.Mutex
 	time              util.ITimeProvider
+	journal           *journal
+	// Keys refreshed since their expiration was last written to the journal.
+	// Refreshes are written in one go by the janitor and on Close, instead
+	// of on every read.
+	refreshed map[string]bool
+	capacity  Capacity
+	// Total size of the values of the dataobjects, kept by put and drop
+	usedBytes       int
+	expiryListeners expiryListeners
+	// Dataobjects that expired while the lock was held, to be told to the
+	// listeners once it is released
+	expiredEvents []ExpiryEvent
+	// Dataobjects that expired, and how many of them were cached copies
+	expired       int
+	expiredCached int
+	logger        *logging.Logger
 }
 
 // Create a new datastore.
@@ -77,7 +217,8 @@ type DataStore struct {
 // Parameters:
 //
 //	`ttl` - The default expiration time for dataobjects.
-//	`onExpired` - A function that is called when a dataobject expires.
+//	`onExpired` - A function that is called when a dataobject expires, or nil.
+//	More listeners can be added with OnExpiry.
 //	`timeprovider` - A timeprovider that is used to get the current time.
 func NewDataStore(ttl time.Duration, onExpired func(key string, value []byte), timeprovider util.ITimeProvider) *DataStore {
 	datastore := new(DataStore)
@@ -85,56 +226,211 @@ func NewDataStore(ttl time.Duration, onExpired func(key string, value []byte), t
 	datastore.onExpired = onExpired
 	datastore.dataobjects = make(map[string]dataObject)
 	datastore.time = timeprovider
+	datastore.logger = logging.Default()
 
 	runJanitor(datastore, ttl)
 
 	return datastore
 }
 
-func (store *DataStore) Get(key string) (value []byte, exists bool) {
-	log.Println("Datastore: served dataobject", key)
+// Create a new datastore that is persisted to disk.
+//
+// Every change is written to an append-only journal in `dir` before it is
+// applied, and the journal is replayed when the datastore is created, so
+// dataobjects survive a restart of the node. Dataobjects that expired while
+// the node was down are not restored.
+//
+// Parameters:
+//
+//	`dir` - The directory to keep the journal in. Created if it does not exist.
+//	`ttl` - The default expiration time for dataobjects.
+//	`onExpired` - A function that is called when a dataobject expires, or nil.
+//	More listeners can be added with OnExpiry.
+//	`timeprovider` - A timeprovider that is used to get the current time.
+func NewDiskDataStore(dir string, ttl time.Duration, onExpired func(key string, value []byte), timeprovider util.ITimeProvider) (*DataStore, error) {
+	datastore := new(DataStore)
+	datastore.defaultExpiration = ttl
+	datastore.onExpired = onExpired
+	datastore.dataobjects = make(map[string]dataObject)
+	datastore.time = timeprovider
+	datastore.logger = logging.Default()
+
+	journal, err := openJournal(dir, datastore.logger, datastore.applyJournalRecord)
+	if err != nil {
+		return nil, err
+	}
+	datastore.journal = journal
+	datastore.refreshed = make(map[string]bool)
+
+	for key, dataobject := range datastore.dataobjects {
+		if dataobject.IsExpired(timeprovider) {
+			datastore.drop(key)
+		}
+	}
+	if err := datastore.journal.compact(datastore.journalSnapshot()); err != nil {
+		journal.close()
+		return nil, err
+	}
+	datastore.logger.Info("Restored datastore", "dataobjects", len(datastore.dataobjects), "dir", dir)
+
+	runJanitor(datastore, ttl)
+
+	return datastore, nil
+}
+
+// Stop the janitor and, for a datastore persisted to disk, close the journal.
+// Closing a datastore again does nothing.
+func (store *DataStore) Close() error {
+	stopJanitor(store)
 
 	store.lock.Lock()
 	defer store.lock.Unlock()
 
+	if store.journal == nil {
+		return nil
+	}
+	store.flushRefreshed()
+	err := store.journal.close()
+	store.journal = nil
+	return err
+}
+
+func (store *DataStore) Get(key string) (value []byte, exists bool) {
+	store.logger.Debug("Served dataobject", "key", key)
+
+	store.lock.Lock()
+	defer store.unlock()
+
 	dataObject, exists := store.dataobjects[key]
 	if !exists {
 		return nil, false
 	}
 
 	if dataObject.IsExpired(store.time) {
-		store.onExpired(key, dataObject.Value)
-		store._remove(key)
+		store._expire(key, dataObject)
 		return nil, false
 	}
 
-	dataObject.Refresh(store.defaultExpiration, store.time)
+	dataObject.Refresh(store.ttlOf(dataObject), store.time)
+	dataObject.LastAccess = store.time.Now()
 	store.dataobjects[key] = dataObject
+	store.markRefreshed(key)
 
 	return dataObject.Value, exists
 }
 
-func (store *DataStore) Set(key string, value []byte) (ok bool) {
-	log.Println("Datastore: added dataobject", key)
+func (store *DataStore) Set(key string, value []byte, ttl time.Duration) error {
+	return store.SetPublished(key, value, ttl, nil)
+}
+
+func (store *DataStore) SetPublished(key string, value []byte, ttl time.Duration, publisher []byte) error {
+	return store.add(key, value, ttl, publisher, false)
+}
+
+func (store *DataStore) SetCached(key string, value []byte, ttl time.Duration, publisher []byte) error {
+	return store.add(key, value, ttl, publisher, true)
+}
+
+// Add a new dataobject, unless the key exists
+func (store *DataStore) add(key string, value []byte, ttl time.Duration, publisher []byte, cached bool) error {
+	store.logger.Debug("Added dataobject", "key", key)
 
 	store.lock.Lock()
-	defer store.lock.Unlock()
+	defer store.unlock()
 
 	dataobject, exists := store.dataobjects[key]
 	if exists && !dataobject.IsExpired(store.time) {
-		return false
+		// The publisher of a replica storing it again, e.g. when it
+		// republishes it, refreshes it with the TTL it asks for
+		if !cached && !dataobject.Cached && len(publisher) > 0 && bytes.Equal(dataobject.Publisher, publisher) && bytes.Equal(dataobject.Value, value) {
+			return store._set(key, value, ttl, publisher, false)
+		}
+		return ErrKeyExists
+	}
+	if exists {
+		store._expire(key, dataobject)
+	}
+	if err := store.reserve(len(value)); err != nil {
+		return err
+	}
+
+	return store._set(key, value, ttl, publisher, cached)
+}
+
+func (store *DataStore) Publisher(key string) (publisher []byte, exists bool) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	dataObject, exists := store.dataobjects[key]
+	if !exists || dataObject.IsExpired(store.time) {
+		return nil, false
+	}
+	return dataObject.Publisher, true
+}
+
+func (store *DataStore) Update(key string, value []byte, ttl time.Duration, accept func(current []byte) bool) error {
+	store.logger.Debug("Updated dataobject", "key", key)
+
+	store.lock.Lock()
+	defer store.unlock()
+
+	current, exists := store.dataobjects[key]
+	if exists && current.IsExpired(store.time) {
+		store._expire(key, current)
+		exists = false
+	}
+	if exists && !accept(current.Value) {
+		return ErrUpdateRejected
+	}
+
+	// The current value does not count towards the capacity, as it is
+	// replaced. It is put back if the new value does not fit.
+	store.drop(key)
+	if err := store.reserve(len(value)); err != nil {
+		if exists {
+			store.put(key, current)
+		}
+		return err
+	}
+
+	if err := store._set(key, value, ttl, nil, false); err != nil {
+		if exists {
+			store.put(key, current)
+		}
+		return err
+	}
+	return nil
+}
+
+// Add a dataobject without any checks. The lock must be held by the caller.
+func (store *DataStore) _set(key string, value []byte, ttl time.Duration, publisher []byte, cached bool) error {
+	if ttl <= 0 {
+		ttl = store.defaultExpiration
 	}
+	return store._setUntil(key, value, ttl, store.time.Now().Add(ttl), publisher, cached)
+}
 
-	store.dataobjects[key] = dataObject{
-		Expiration: store.time.Now().Add(store.defaultExpiration),
+// Set a dataobject that expires at `expiration`, rather than its TTL from now
+func (store *DataStore) _setUntil(key string, value []byte, ttl time.Duration, expiration time.Time, publisher []byte, cached bool) error {
+	newDataobject := dataObject{
+		Expiration: expiration,
+		LastAccess: store.time.Now(),
+		TTL:        ttl,
 		Value:      value,
+		Publisher:  publisher,
+		Cached:     cached,
+	}
+	if err := store.writeJournal(journalRecord{Op: journalOpSet, Key: key, Value: value, TTL: ttl, Publisher: publisher, Cached: cached, Expiration: newDataobject.Expiration}, true); err != nil {
+		return err
 	}
+	store.put(key, newDataobject)
+	delete(store.refreshed, key)
 
-	return true
+	return nil
 }
 
 func (store *DataStore) Remove(key string) (value []byte, ok bool) {
-	log.Println("Datastore: removed dataobject", key)
+	store.logger.Debug("Removed dataobject", "key", key)
 
 	store.lock.Lock()
 	defer store.lock.Unlock()
@@ -145,40 +441,65 @@ func (store *DataStore) Remove(key string) (value []byte, ok bool) {
 func (store *DataStore) _remove(key string) (value []byte, ok bool) {
 	dataobject, exists := store.dataobjects[key]
 	if exists {
-		delete(store.dataobjects, key)
+		store.drop(key)
+		delete(store.refreshed, key)
+		store.writeJournal(journalRecord{Op: journalOpRemove, Key: key, Expiration: store.time.Now()}, true)
 		return dataobject.Value, true
 	}
 	return nil, false
 }
 
 func (store *DataStore) RemoveExpired() {
-	var removedItems []keyValuePair
-
 	store.lock.Lock()
+	defer store.unlock()
+
 	for key, dataobject := range store.dataobjects {
 		if dataobject.IsExpired(store.time) {
-			value, evicted := store._remove(key)
-			if evicted {
-				removedItems = append(removedItems, keyValuePair{key, value})
-			}
+			store._expire(key, dataobject)
 		}
 	}
-	store.lock.Unlock()
+	store.flushRefreshed()
+	store.compactJournal()
+}
 
-	if store.onExpired == nil {
-		return
+// Remove an expired dataobject, count it, and queue it to be told to the
+// listeners once the lock is released. The lock must be held by the caller.
+func (store *DataStore) _expire(key string, dataobject dataObject) {
+	store._remove(key)
+	store.expired++
+	if dataobject.Cached {
+		store.expiredCached++
 	}
+	store.expiredEvents = append(store.expiredEvents, dataobject.expiryEvent(key))
+}
+
+// Release the lock, and then tell the listeners about the dataobjects that
+// expired while it was held.
+func (store *DataStore) unlock() {
+	events := store.expiredEvents
+	store.expiredEvents = nil
+	store.lock.Unlock()
 
-	for _, item := range removedItems {
-		store.onExpired(item.Key, item.Value)
+	store.notifyExpired(events)
+}
+
+func (store *DataStore) TTL(key string) (ttl time.Duration, exists bool) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	dataObject, exists := store.dataobjects[key]
+	if !exists || dataObject.IsExpired(store.time) {
+		return 0, false
 	}
+
+	return dataObject.Expiration.Sub(store.time.Now()), true
 }
 
 func (store *DataStore) Refresh(key string) (ok bool) {
-	log.Println("Datastore: refreshed dataobject", key)
+	store.logger.Debug("Refreshed dataobject", "key", key)
 
 	store.lock.Lock()
-	defer store.lock.Unlock()
+	defer store.unlock()
 
 	return store._refresh(key)
 }
@@ -190,16 +511,125 @@ func (store *DataStore) _refresh(key string) (ok bool) {
 	}
 
 	if dataObject.IsExpired(store.time) {
-		store._remove(key)
+		store._expire(key, dataObject)
 		return false
 	}
 
-	dataObject.Refresh(store.defaultExpiration, store.time)
+	dataObject.Refresh(store.ttlOf(dataObject), store.time)
 	store.dataobjects[key] = dataObject
+	store.markRefreshed(key)
 
 	return true
 }
 
+// Get the TTL of a dataobject, falling back on the default expiration time
+// of the datastore if it has none.
+func (store *DataStore) ttlOf(object dataObject) time.Duration {
+	if object.TTL <= 0 {
+		return store.defaultExpiration
+	}
+	return object.TTL
+}
+
+// Set the logger of the datastore
+func (store *DataStore) SetLogger(logger *logging.Logger) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.logger = logger
+}
+
+// Write a record to the journal, if the datastore has one. The lock must be
+// held by the caller.
+func (store *DataStore) writeJournal(record journalRecord, sync bool) error {
+	if store.journal == nil {
+		return nil
+	}
+	err := store.journal.append(record, sync)
+	if err != nil {
+		store.logger.Error("Could not write journal", "err", err)
+	}
+	return err
+}
+
+// Remember that the expiration of a dataobject moved, to be written to the
+// journal by flushRefreshed. The lock must be held by the caller.
+func (store *DataStore) markRefreshed(key string) {
+	if store.journal != nil {
+		store.refreshed[key] = true
+	}
+}
+
+// Write one refresh record for each dataobject refreshed since the last
+// flush, however often it was read. The lock must be held by the caller.
+func (store *DataStore) flushRefreshed() {
+	if store.journal == nil || len(store.refreshed) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(store.refreshed))
+	for key := range store.refreshed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if dataobject, exists := store.dataobjects[key]; exists {
+			store.writeJournal(journalRecord{Op: journalOpRefresh, Key: key, Expiration: dataobject.Expiration}, false)
+		}
+	}
+	store.refreshed = make(map[string]bool)
+}
+
+// Rewrite the journal without stale records, if enough of them have piled
+// up. The lock must be held by the caller.
+func (store *DataStore) compactJournal() {
+	if store.journal == nil || !store.journal.shouldCompact(len(store.dataobjects)) {
+		return
+	}
+	if err := store.journal.compact(store.journalSnapshot()); err != nil {
+		store.logger.Error("Could not compact journal", "err", err)
+	}
+}
+
+// Build one set record per dataobject in the datastore, ordered by key.
+func (store *DataStore) journalSnapshot() []journalRecord {
+	records := make([]journalRecord, 0, len(store.dataobjects))
+	for key, dataobject := range store.dataobjects {
+		records = append(records, journalRecord{
+			Op:         journalOpSet,
+			Key:        key,
+			Value:      dataobject.Value,
+			TTL:        dataobject.TTL,
+			Publisher:  dataobject.Publisher,
+			Cached:     dataobject.Cached,
+			Expiration: dataobject.Expiration,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	return records
+}
+
+// Apply a record read from the journal to the in-memory dataobjects.
+func (store *DataStore) applyJournalRecord(record journalRecord) {
+	switch record.Op {
+	case journalOpSet:
+		store.put(record.Key, dataObject{
+			Expiration: record.Expiration,
+			LastAccess: store.time.Now(),
+			TTL:        record.TTL,
+			Value:      record.Value,
+			Publisher:  record.Publisher,
+			Cached:     record.Cached,
+		})
+	case journalOpRemove:
+		store.drop(record.Key)
+	case journalOpRefresh:
+		if dataobject, exists := store.dataobjects[record.Key]; exists {
+			dataobject.Expiration = record.Expiration
+			store.dataobjects[record.Key] = dataobject
+		}
+	}
+}
+
 func (object *dataObject) Refresh(expirationTime time.Duration, timeProvider util.ITimeProvider) {
 	object.Expiration = timeProvider.Now().Add(expirationTime)
 }
@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"d7024e/util"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -10,11 +11,85 @@ import (
 
 func emptyOnExpired(key string, value []byte) {}
 
-func createNewDatastore(ttl time.Duration, currentTime time.Time) *DataStore {
+type datastoreFactory func(ttl time.Duration, currentTime time.Time) *DataStore
+
+func createMemoryDatastore(ttl time.Duration, currentTime time.Time) *DataStore {
 	timeProvider := &util.FakeTimeProvider{InternalTime: currentTime}
 	return NewDataStore(ttl, emptyOnExpired, timeProvider)
 }
 
+// All datastore backends. Tests that use forEachBackend are run once for each.
+var backends = []struct {
+	name   string
+	create func(t *testing.T) datastoreFactory
+}{
+	{"memory", func(t *testing.T) datastoreFactory {
+		return createMemoryDatastore
+	}},
+	{"disk", func(t *testing.T) datastoreFactory {
+		return func(ttl time.Duration, currentTime time.Time) *DataStore {
+			timeProvider := &util.FakeTimeProvider{InternalTime: currentTime}
+			store, err := NewDiskDataStore(t.TempDir(), ttl, emptyOnExpired, timeProvider)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		}
+	}},
+}
+
+func forEachBackend(t *testing.T, test func(t *testing.T, createNewDatastore datastoreFactory)) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.create(t))
+		})
+	}
+}
+
+// Add dataobjects through SetPublished, each at the time that gives it its
+// expiration, and reopen the datastore so that a datastore persisted to disk
+// reads them back from its journal. Returns the datastore to use from then on.
+func populate(t *testing.T, store *DataStore, dataobjects map[string]dataObject) *DataStore {
+	timeProvider := store.time.(*util.FakeTimeProvider)
+	now := timeProvider.InternalTime
+	earliest := now
+	for key, dataobject := range dataobjects {
+		setAt := dataobject.Expiration.Add(-store.defaultExpiration)
+		if setAt.Before(earliest) {
+			earliest = setAt
+		}
+		timeProvider.InternalTime = setAt
+		if err := store.SetPublished(key, dataobject.Value, 0, dataobject.Publisher); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nothing has expired yet when the datastore is reopened
+	timeProvider.InternalTime = earliest
+	store = reopen(t, store)
+	timeProvider.InternalTime = now
+	return store
+}
+
+// Close a datastore persisted to disk and create a new one from its journal.
+// A datastore kept in memory is returned as it is.
+func reopen(t *testing.T, store *DataStore) *DataStore {
+	if store.journal == nil {
+		return store
+	}
+	dir := filepath.Dir(store.journal.path)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewDiskDataStore(dir, store.defaultExpiration, store.onExpired, store.time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reopened.Close() })
+	return reopened
+}
+
 func TestNewDataStore(t *testing.T) {
 	ttl := time.Hour
 	timeProvider := &util.FakeTimeProvider{}
@@ -29,201 +104,313 @@ func TestNewDataStore(t *testing.T) {
 }
 
 func TestDataStore_Get(t *testing.T) {
-	expiredDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
-	currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
-	futureDate := time.Date(1972, 1, 1, 0, 0, 0, 0, time.UTC)
-
-	dataObjects := map[string]dataObject{
-		"key1": {Value: []byte("value1"), Expiration: futureDate},
-		"key2": {Value: []byte("value1"), Expiration: futureDate},
-		"key3": {Value: []byte("value1"), Expiration: currentDate},
-		"key4": {Value: []byte("value1"), Expiration: expiredDate},
-	}
-
-	expectedValidKeys := []string{"key1", "key2", "key3"}
-	expectedExpiredKeys := []string{"key4"}
-	dataStore := createNewDatastore(time.Hour, currentDate)
-	dataStore.dataobjects = dataObjects
-
-	for _, key := range expectedValidKeys {
-		value, ok := dataStore.Get(key)
-		assert.True(t, ok)
-		assert.Equal(t, dataObjects[key].Value, value)
-	}
-	for _, key := range expectedExpiredKeys {
-		value, ok := dataStore.Get(key)
-		assert.False(t, ok)
-		assert.Nil(t, value)
-	}
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		expiredDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+		futureDate := time.Date(1972, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		dataObjects := map[string]dataObject{
+			"key1": {Value: []byte("value1"), Expiration: futureDate},
+			"key2": {Value: []byte("value1"), Expiration: futureDate},
+			"key3": {Value: []byte("value1"), Expiration: currentDate},
+			"key4": {Value: []byte("value1"), Expiration: expiredDate},
+		}
+
+		expectedValidKeys := []string{"key1", "key2", "key3"}
+		expectedExpiredKeys := []string{"key4"}
+		dataStore := populate(t, createNewDatastore(time.Hour, currentDate), dataObjects)
+
+		for _, key := range expectedValidKeys {
+			value, ok := dataStore.Get(key)
+			assert.True(t, ok)
+			assert.Equal(t, dataObjects[key].Value, value)
+		}
+		for _, key := range expectedExpiredKeys {
+			value, ok := dataStore.Get(key)
+			assert.False(t, ok)
+			assert.Nil(t, value)
+		}
+	})
 }
 
 func TestDataStore_Set(t *testing.T) {
-	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
-	expectedExpirationDate := currentDate.Add(time.Hour)
-	expectedValidKeys := []string{"key1", "key2", "key3"}
-	expectedString := "test"
-	expectedObjectValue := []byte(expectedString)
-	dataStore := createNewDatastore(time.Hour, currentDate)
-
-	for _, key := range expectedValidKeys {
-		dataStore.Set(key, expectedObjectValue)
-	}
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		expectedExpirationDate := currentDate.Add(time.Hour)
+		expectedValidKeys := []string{"key1", "key2", "key3"}
+		expectedString := "test"
+		expectedObjectValue := []byte(expectedString)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+
+		for _, key := range expectedValidKeys {
+			dataStore.Set(key, expectedObjectValue, 0)
+		}
+		dataStore = reopen(t, dataStore)
+
+		for key, dataobject := range dataStore.dataobjects {
+			assert.Contains(t, expectedValidKeys, key)
+			assert.Equal(t, expectedObjectValue, dataobject.Value)
+			assert.WithinDuration(t, expectedExpirationDate, dataobject.Expiration, 0)
+		}
+	})
+}
 
-	for key, dataobject := range dataStore.dataobjects {
-		assert.Contains(t, expectedValidKeys, key)
-		assert.Equal(t, expectedObjectValue, dataobject.Value)
-		assert.Equal(t, expectedExpirationDate, dataobject.Expiration)
-	}
+func TestDataStore_Set_WithTTL_ShouldKeepTTL(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+
+		dataStore.Set("key1", []byte("value1"), time.Minute)
+		actualTTL, exists := dataStore.TTL("key1")
+		assert.True(t, exists)
+		assert.Equal(t, time.Minute, actualTTL)
+
+		// Refreshing the dataobject uses its own TTL, not the default
+		dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate.Add(30 * time.Second)
+		dataStore.Refresh("key1")
+		assert.Equal(t, currentDate.Add(90*time.Second), dataStore.dataobjects["key1"].Expiration)
+
+		dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate.Add(3 * time.Minute)
+		_, exists = dataStore.TTL("key1")
+		assert.False(t, exists)
+	})
 }
 
 func TestDataStore_Set_WithExistingKey_WhenNotExpired_ShouldNotReplaceExisting(t *testing.T) {
-	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
-	futureDate := currentDate.Add(time.Minute)
-	dataStore := createNewDatastore(time.Hour, currentDate)
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		futureDate := currentDate.Add(time.Minute)
+		dataStore := createNewDatastore(time.Hour, currentDate)
 
-	keyToAdd := "key1"
-	dataObjects := map[string]dataObject{
-		keyToAdd: {Value: []byte("value1"), Expiration: futureDate},
-	}
-	dataStore.dataobjects = dataObjects
+		keyToAdd := "key1"
+		dataObjects := map[string]dataObject{
+			keyToAdd: {Value: []byte("value1"), Expiration: futureDate},
+		}
+		dataStore = populate(t, dataStore, dataObjects)
 
-	actualSetReturn := dataStore.Set(keyToAdd, []byte("test"))
+		actualSetReturn := dataStore.Set(keyToAdd, []byte("test"), 0)
 
-	assert.False(t, actualSetReturn)
+		assert.ErrorIs(t, actualSetReturn, ErrKeyExists)
+	})
 }
 
 func TestDataStore_Set_WithExistingKey_WhenExpired_ShouldReplaceExisting(t *testing.T) {
-	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
-	expiredDate := currentDate.Add(-1 * time.Hour)
-	dataStore := createNewDatastore(time.Hour, currentDate)
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		expiredDate := currentDate.Add(-1 * time.Hour)
+		dataStore := createNewDatastore(time.Hour, currentDate)
 
-	keyToAdd := "key1"
-	dataObjects := map[string]dataObject{
-		keyToAdd: {Value: []byte("value1"), Expiration: expiredDate},
-	}
-	dataStore.dataobjects = dataObjects
+		keyToAdd := "key1"
+		dataObjects := map[string]dataObject{
+			keyToAdd: {Value: []byte("value1"), Expiration: expiredDate},
+		}
+		dataStore = populate(t, dataStore, dataObjects)
 
-	actualSetReturn := dataStore.Set(keyToAdd, []byte(""))
+		actualSetReturn := dataStore.Set(keyToAdd, []byte(""), 0)
 
-	assert.True(t, actualSetReturn)
+		assert.Nil(t, actualSetReturn)
+	})
 }
 
-func TestDataStore_Remove(t *testing.T) {
-	var tests = []struct {
-		keyToRemove         string
-		expectedOutputOk    bool
-		expectedOutputValue []byte
-		expectedKeysInStore []string
-	}{
-		{"key1", true, []byte("value1"), []string{"key2"}},
-		{"key4", false, nil, []string{"key1", "key2"}},
-	}
-
-	for _, test := range tests {
-		t.Run(test.keyToRemove, func(t *testing.T) {
-			currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
-			futureDate := time.Date(1972, 1, 1, 0, 0, 0, 0, time.UTC)
+func TestDataStore_SetPublished_ShouldKeepFirstPublisher(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+
+		errFirst := dataStore.SetPublished("key1", []byte("value1"), 0, []byte("publisher1"))
+		errSecond := dataStore.SetPublished("key1", []byte("value1"), 0, []byte("publisher2"))
+		publisher, exists := dataStore.Publisher("key1")
+		_, existsMissing := dataStore.Publisher("key2")
+
+		assert.Nil(t, errFirst)
+		assert.ErrorIs(t, errSecond, ErrKeyExists)
+		assert.True(t, exists)
+		assert.Equal(t, []byte("publisher1"), publisher)
+		assert.False(t, existsMissing)
+	})
+}
 
-			dataStore := createNewDatastore(time.Hour, currentDate)
-			dataStore.dataobjects = map[string]dataObject{
-				"key1": {Value: []byte("value1"), Expiration: futureDate},
-				"key2": {Value: []byte("value1"), Expiration: currentDate},
-			}
+func TestDataStore_SetPublished_WithSamePublisherAndValue_ShouldRefresh(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		dataStore.SetPublished("key1", []byte("value1"), time.Minute, []byte("publisher1"))
+		dataStore.SetCached("key2", []byte("value2"), time.Minute, nil)
+
+		errRefresh := dataStore.SetPublished("key1", []byte("value1"), 2*time.Hour, []byte("publisher1"))
+		errOtherValue := dataStore.SetPublished("key1", []byte("value2"), 3*time.Hour, []byte("publisher1"))
+		errCached := dataStore.SetPublished("key2", []byte("value2"), 3*time.Hour, []byte("publisher1"))
+		ttl, _ := dataStore.TTL("key1")
+		ttlCached, _ := dataStore.TTL("key2")
+
+		assert.Nil(t, errRefresh)
+		assert.ErrorIs(t, errOtherValue, ErrKeyExists)
+		assert.ErrorIs(t, errCached, ErrKeyExists)
+		assert.Equal(t, 2*time.Hour, ttl)
+		assert.Equal(t, time.Minute, ttlCached)
+	})
+}
 
-			actualValue, actualOk := dataStore.Remove(test.keyToRemove)
+func TestDataStore_Update(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		acceptNone := func(current []byte) bool { return false }
 
-			assert.Equal(t, test.expectedOutputOk, actualOk)
-			assert.Equal(t, test.expectedOutputValue, actualValue)
-			for key, _ := range dataStore.dataobjects {
-				assert.Contains(t, test.expectedKeysInStore, key)
-			}
+		errAdd := dataStore.Update("key1", []byte("value1"), 0, acceptNone)
+		errRejected := dataStore.Update("key1", []byte("value2"), 0, acceptNone)
+		errReplace := dataStore.Update("key1", []byte("value3"), time.Minute, func(current []byte) bool {
+			return string(current) == "value1"
 		})
-	}
+		value, _ := dataStore.Get("key1")
+		ttl, _ := dataStore.TTL("key1")
+
+		assert.Nil(t, errAdd)
+		assert.ErrorIs(t, errRejected, ErrUpdateRejected)
+		assert.Nil(t, errReplace)
+		assert.Equal(t, []byte("value3"), value)
+		assert.Equal(t, time.Minute, ttl)
+	})
 }
 
-func TestDataStore_RemoveExpired(t *testing.T) {
-	expiredDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
-	currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
-	futureDate := time.Date(1972, 1, 1, 0, 0, 0, 0, time.UTC)
-
-	dataObjects := map[string]dataObject{
-		"key1": {Value: []byte("value1"), Expiration: futureDate},
-		"key2": {Value: []byte("value1"), Expiration: futureDate},
-		"key3": {Value: []byte("value1"), Expiration: currentDate},
-		"key4": {Value: []byte("value1"), Expiration: expiredDate},
-	}
-	dataStore := createNewDatastore(time.Hour, currentDate)
-	dataStore.dataobjects = dataObjects
-
-	expectedValidKeys := []string{"key1", "key2", "key3"}
+func TestDataStore_Update_WhenFull_ShouldKeepCurrentValue(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		dataStore.SetCapacity(Capacity{MaxBytes: 10})
+		acceptAll := func(current []byte) bool { return true }
+
+		dataStore.Update("key1", []byte("value1"), 0, acceptAll)
+		dataStore.Set("key2", []byte("abc"), 0)
+		errReplace := dataStore.Update("key1", []byte("value2"), 0, acceptAll)
+		errTooLarge := dataStore.Update("key1", []byte("value3++"), 0, acceptAll)
+		value, _ := dataStore.Get("key1")
+
+		assert.Nil(t, errReplace)
+		assert.ErrorIs(t, errTooLarge, ErrStoreFull)
+		assert.Equal(t, []byte("value2"), value)
+	})
+}
 
-	dataStore.RemoveExpired()
+func TestDataStore_Remove(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		var tests = []struct {
+			keyToRemove         string
+			expectedOutputOk    bool
+			expectedOutputValue []byte
+			expectedKeysInStore []string
+		}{
+			{"key1", true, []byte("value1"), []string{"key2"}},
+			{"key4", false, nil, []string{"key1", "key2"}},
+		}
+
+		for _, test := range tests {
+			t.Run(test.keyToRemove, func(t *testing.T) {
+				currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+				futureDate := time.Date(1972, 1, 1, 0, 0, 0, 0, time.UTC)
+
+				dataStore := populate(t, createNewDatastore(time.Hour, currentDate), map[string]dataObject{
+					"key1": {Value: []byte("value1"), Expiration: futureDate},
+					"key2": {Value: []byte("value1"), Expiration: currentDate},
+				})
+
+				actualValue, actualOk := dataStore.Remove(test.keyToRemove)
+
+				assert.Equal(t, test.expectedOutputOk, actualOk)
+				assert.Equal(t, test.expectedOutputValue, actualValue)
+				for key, _ := range dataStore.dataobjects {
+					assert.Contains(t, test.expectedKeysInStore, key)
+				}
+			})
+		}
+	})
+}
 
-	for key, _ := range dataStore.dataobjects {
-		assert.Contains(t, expectedValidKeys, key)
-	}
+func TestDataStore_RemoveExpired(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		expiredDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+		futureDate := time.Date(1972, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		dataObjects := map[string]dataObject{
+			"key1": {Value: []byte("value1"), Expiration: futureDate},
+			"key2": {Value: []byte("value1"), Expiration: futureDate},
+			"key3": {Value: []byte("value1"), Expiration: currentDate},
+			"key4": {Value: []byte("value1"), Expiration: expiredDate},
+		}
+		dataStore := populate(t, createNewDatastore(time.Hour, currentDate), dataObjects)
+
+		expectedValidKeys := []string{"key1", "key2", "key3"}
+
+		dataStore.RemoveExpired()
+
+		for key, _ := range dataStore.dataobjects {
+			assert.Contains(t, expectedValidKeys, key)
+		}
+	})
 }
 
 func TestDataStore_Refresh(t *testing.T) {
-	ttl := time.Hour
-	expiredDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
-	currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
-	futureDate := currentDate.Add(ttl)
-
-	var tests = []struct {
-		name                string
-		keyToRefresh        string
-		expectedKeysInStore []string
-		expectedNewDate     time.Time
-		expectedOutput      bool
-	}{
-		{
-			name:                "Refresh not expired key",
-			keyToRefresh:        "key1",
-			expectedKeysInStore: []string{"key1", "key2", "key3", "key4"},
-			expectedNewDate:     futureDate,
-			expectedOutput:      true,
-		},
-		{
-			name:                "Refresh expired key",
-			keyToRefresh:        "key4",
-			expectedKeysInStore: []string{"key1", "key2", "key3"},
-			expectedNewDate:     futureDate,
-			expectedOutput:      false,
-		},
-		{
-			name:                "Refresh non-existing key",
-			keyToRefresh:        "key5",
-			expectedKeysInStore: []string{"key1", "key2", "key3", "key4"},
-			expectedNewDate:     currentDate,
-			expectedOutput:      false,
-		},
-	}
-
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			dataStore := createNewDatastore(ttl, currentDate)
-			dataStore.dataobjects = map[string]dataObject{
-				"key1": {Value: []byte("value1"), Expiration: futureDate},
-				"key2": {Value: []byte("value1"), Expiration: futureDate},
-				"key3": {Value: []byte("value1"), Expiration: currentDate},
-				"key4": {Value: []byte("value1"), Expiration: expiredDate},
-			}
-
-			actual := dataStore.Refresh(test.keyToRefresh)
-			assert.Equal(t, actual, test.expectedOutput)
-
-			refreshedDataObject, exists := dataStore.dataobjects[test.keyToRefresh]
-			if exists {
-				assert.Equal(t, test.expectedNewDate, refreshedDataObject.Expiration)
-			}
-
-			for _, key := range test.expectedKeysInStore {
-				_, ok := dataStore.dataobjects[key]
-				assert.True(t, ok)
-			}
-		})
-	}
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		ttl := time.Hour
+		expiredDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		currentDate := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+		futureDate := currentDate.Add(ttl)
+
+		var tests = []struct {
+			name                string
+			keyToRefresh        string
+			expectedKeysInStore []string
+			expectedNewDate     time.Time
+			expectedOutput      bool
+		}{
+			{
+				name:                "Refresh not expired key",
+				keyToRefresh:        "key1",
+				expectedKeysInStore: []string{"key1", "key2", "key3", "key4"},
+				expectedNewDate:     futureDate,
+				expectedOutput:      true,
+			},
+			{
+				name:                "Refresh expired key",
+				keyToRefresh:        "key4",
+				expectedKeysInStore: []string{"key1", "key2", "key3"},
+				expectedNewDate:     futureDate,
+				expectedOutput:      false,
+			},
+			{
+				name:                "Refresh non-existing key",
+				keyToRefresh:        "key5",
+				expectedKeysInStore: []string{"key1", "key2", "key3", "key4"},
+				expectedNewDate:     currentDate,
+				expectedOutput:      false,
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				dataStore := populate(t, createNewDatastore(ttl, currentDate), map[string]dataObject{
+					"key1": {Value: []byte("value1"), Expiration: futureDate},
+					"key2": {Value: []byte("value1"), Expiration: futureDate},
+					"key3": {Value: []byte("value1"), Expiration: currentDate},
+					"key4": {Value: []byte("value1"), Expiration: expiredDate},
+				})
+
+				actual := dataStore.Refresh(test.keyToRefresh)
+				assert.Equal(t, actual, test.expectedOutput)
+
+				refreshedDataObject, exists := dataStore.dataobjects[test.keyToRefresh]
+				if exists {
+					assert.Equal(t, test.expectedNewDate, refreshedDataObject.Expiration)
+				}
+
+				for _, key := range test.expectedKeysInStore {
+					_, ok := dataStore.dataobjects[key]
+					assert.True(t, ok)
+				}
+			})
+		}
+	})
 }
 
 func Test_dataObject_Refresh(t *testing.T) {
@@ -0,0 +1,83 @@
+package datastore
+
+import (
+	"sync"
+	"time"
+)
+
+// A dataobject that expired and was removed from the datastore
+type ExpiryEvent struct {
+	Key       string
+	Value     []byte
+	Publisher []byte `json:",omitempty"`
+	Cached    bool   `json:",omitempty"`
+	// TTL the dataobject was stored with
+	TTL        time.Duration
+	Expiration time.Time
+}
+
+// Called with every dataobject that expires. Listeners are called without
+// the lock of the datastore held, so they may use the datastore.
+type ExpiryListener func(event ExpiryEvent)
+
+// The listeners of a datastore, by the ID they were added under
+type expiryListeners struct {
+	lock      sync.Mutex
+	listeners map[int]ExpiryListener
+	nextID    int
+}
+
+func (store *DataStore) OnExpiry(listener ExpiryListener) (remove func()) {
+	hooks := &store.expiryListeners
+	hooks.lock.Lock()
+	defer hooks.lock.Unlock()
+
+	if hooks.listeners == nil {
+		hooks.listeners = make(map[int]ExpiryListener)
+	}
+	id := hooks.nextID
+	hooks.nextID++
+	hooks.listeners[id] = listener
+
+	return func() {
+		hooks.lock.Lock()
+		defer hooks.lock.Unlock()
+		delete(hooks.listeners, id)
+	}
+}
+
+// Tell the onExpired callback and every listener that dataobjects expired.
+// The lock must not be held by the caller.
+func (store *DataStore) notifyExpired(events []ExpiryEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	hooks := &store.expiryListeners
+	hooks.lock.Lock()
+	listeners := make([]ExpiryListener, 0, len(hooks.listeners))
+	for _, listener := range hooks.listeners {
+		listeners = append(listeners, listener)
+	}
+	hooks.lock.Unlock()
+
+	for _, event := range events {
+		if store.onExpired != nil {
+			store.onExpired(event.Key, event.Value)
+		}
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+func (object *dataObject) expiryEvent(key string) ExpiryEvent {
+	return ExpiryEvent{
+		Key:        key,
+		Value:      object.Value,
+		Publisher:  object.Publisher,
+		Cached:     object.Cached,
+		TTL:        object.TTL,
+		Expiration: object.Expiration,
+	}
+}
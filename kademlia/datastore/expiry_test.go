@@ -0,0 +1,99 @@
+package datastore
+
+import (
+	"d7024e/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataStore_OnExpiry_ShouldCallEveryListener(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		dataStore.SetPublished("key1", []byte("value1"), time.Minute, []byte("publisher"))
+		dataStore.SetCached("key2", []byte("value2"), time.Minute, nil)
+		dataStore.Set("key3", []byte("value3"), 0)
+
+		first := []ExpiryEvent{}
+		second := []string{}
+		dataStore.OnExpiry(func(event ExpiryEvent) { first = append(first, event) })
+		dataStore.OnExpiry(func(event ExpiryEvent) { second = append(second, event.Key) })
+
+		dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate.Add(2 * time.Minute)
+		_, found := dataStore.Get("key1")
+		dataStore.RemoveExpired()
+
+		assert.False(t, found)
+		assert.Equal(t, []ExpiryEvent{
+			{Key: "key1", Value: []byte("value1"), Publisher: []byte("publisher"), TTL: time.Minute, Expiration: currentDate.Add(time.Minute)},
+			{Key: "key2", Value: []byte("value2"), Cached: true, TTL: time.Minute, Expiration: currentDate.Add(time.Minute)},
+		}, first)
+		assert.Equal(t, []string{"key1", "key2"}, second)
+	})
+}
+
+func TestDataStore_Stats_ShouldCountExpiredWithoutListeners(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		dataStore.SetPublished("key1", []byte("value1"), time.Minute, []byte("publisher"))
+		dataStore.SetCached("key2", []byte("value2"), time.Minute, nil)
+		dataStore.Set("key3", []byte("value3"), 0)
+
+		dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate.Add(2 * time.Minute)
+		dataStore.Get("key1")
+		dataStore.RemoveExpired()
+		stats := dataStore.Stats()
+
+		assert.Equal(t, 2, stats.Expired)
+		assert.Equal(t, 1, stats.ExpiredCached)
+		assert.Equal(t, 1, stats.Entries)
+	})
+}
+
+func TestDataStore_OnExpiry_WhenRemoved_ShouldNotCallListener(t *testing.T) {
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	dataStore := createMemoryDatastore(time.Minute, currentDate)
+	dataStore.Set("key1", []byte("value1"), 0)
+
+	called := false
+	remove := dataStore.OnExpiry(func(event ExpiryEvent) { called = true })
+	remove()
+
+	dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate.Add(2 * time.Minute)
+	dataStore.RemoveExpired()
+
+	assert.False(t, called)
+}
+
+func TestDataStore_OnExpiry_ShouldAllowListenerToUseDataStore(t *testing.T) {
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	dataStore := createMemoryDatastore(time.Minute, currentDate)
+	dataStore.Set("key1", []byte("value1"), 0)
+
+	dataStore.OnExpiry(func(event ExpiryEvent) {
+		dataStore.Set(event.Key, event.Value, time.Hour)
+	})
+
+	dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate.Add(2 * time.Minute)
+	_, found := dataStore.Get("key1")
+	value, foundAgain := dataStore.Get("key1")
+
+	assert.False(t, found)
+	assert.True(t, foundAgain)
+	assert.Equal(t, []byte("value1"), value)
+}
+
+func TestDataStore_Get_WhenOnExpiredIsNil_ShouldRemoveExpired(t *testing.T) {
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	dataStore := NewDataStore(time.Minute, nil, &util.FakeTimeProvider{InternalTime: currentDate})
+	dataStore.Set("key1", []byte("value1"), 0)
+
+	dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate.Add(2 * time.Minute)
+	_, found := dataStore.Get("key1")
+
+	assert.False(t, found)
+	assert.Equal(t, 0, dataStore.Stats().Entries)
+}
@@ -0,0 +1,102 @@
+package datastore
+
+import (
+	"sort"
+	"time"
+)
+
+// Metadata of a dataobject, without its value
+type Entry struct {
+	Key        string
+	Size       int
+	Expiration time.Time
+	LastAccess time.Time
+	// True if the dataobject is a copy cached by a lookup, rather than a
+	// replica stored by its publisher
+	Cached bool
+}
+
+// Totals over all dataobjects in a datastore
+type Stats struct {
+	Entries       int
+	Bytes         int
+	CachedEntries int
+	CachedBytes   int
+	// Soonest expiration of any dataobject. Zero if the datastore is empty.
+	NextExpiration time.Time
+	// Limits of the datastore, zero if there is no limit
+	MaxEntries int
+	MaxBytes   int
+	// Dataobjects that expired since the datastore was created, whether or
+	// not any expiry listener was added, and how many were cached copies
+	Expired       int
+	ExpiredCached int
+}
+
+func (store *DataStore) Range(fn func(entry Entry) bool) {
+	// The entries are collected first, so that `fn` may use the datastore
+	store.lock.Lock()
+	entries := make([]Entry, 0, len(store.dataobjects))
+	for key, dataobject := range store.dataobjects {
+		if !dataobject.IsExpired(store.time) {
+			entries = append(entries, dataobject.entry(key))
+		}
+	}
+	store.lock.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	for _, entry := range entries {
+		if !fn(entry) {
+			return
+		}
+	}
+}
+
+func (store *DataStore) Entries(after string, limit int) []Entry {
+	entries := []Entry{}
+	store.Range(func(entry Entry) bool {
+		if entry.Key <= after {
+			return true
+		}
+		entries = append(entries, entry)
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries
+}
+
+func (store *DataStore) Stats() Stats {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	stats := Stats{
+		MaxEntries:    store.capacity.MaxEntries,
+		MaxBytes:      store.capacity.MaxBytes,
+		Expired:       store.expired,
+		ExpiredCached: store.expiredCached,
+	}
+	for _, dataobject := range store.dataobjects {
+		if dataobject.IsExpired(store.time) {
+			continue
+		}
+		stats.Entries++
+		stats.Bytes += len(dataobject.Value)
+		if dataobject.Cached {
+			stats.CachedEntries++
+			stats.CachedBytes += len(dataobject.Value)
+		}
+		if stats.NextExpiration.IsZero() || dataobject.Expiration.Before(stats.NextExpiration) {
+			stats.NextExpiration = dataobject.Expiration
+		}
+	}
+	return stats
+}
+
+func (object *dataObject) entry(key string) Entry {
+	return Entry{
+		Key:        key,
+		Size:       len(object.Value),
+		Expiration: object.Expiration,
+		LastAccess: object.LastAccess,
+		Cached:     object.Cached,
+	}
+}
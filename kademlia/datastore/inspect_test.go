@@ -0,0 +1,91 @@
+package datastore
+
+import (
+	"d7024e/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataStore_Entries_ShouldPageInKeyOrder(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		dataStore.Set("key3", []byte("value3"), 0)
+		dataStore.Set("key1", []byte("value1"), 0)
+		dataStore.SetCached("key2", []byte("value22"), time.Minute, nil)
+
+		first := dataStore.Entries("", 2)
+		next := dataStore.Entries(first[len(first)-1].Key, 2)
+
+		assert.Equal(t, []Entry{
+			{Key: "key1", Size: 6, Expiration: currentDate.Add(time.Hour), LastAccess: currentDate},
+			{Key: "key2", Size: 7, Expiration: currentDate.Add(time.Minute), LastAccess: currentDate, Cached: true},
+		}, first)
+		assert.Equal(t, 1, len(next))
+		assert.Equal(t, "key3", next[0].Key)
+	})
+}
+
+func TestDataStore_Range_ShouldSkipExpiredAndStop(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		dataStore.Set("key1", []byte("value1"), time.Minute)
+		dataStore.Set("key2", []byte("value2"), 0)
+		dataStore.Set("key3", []byte("value3"), 0)
+		dataStore.time.(*util.FakeTimeProvider).InternalTime = currentDate.Add(2 * time.Minute)
+
+		keys := []string{}
+		dataStore.Range(func(entry Entry) bool {
+			keys = append(keys, entry.Key)
+			return len(keys) < 1
+		})
+		ttl, _ := dataStore.TTL("key2")
+
+		assert.Equal(t, []string{"key2"}, keys)
+		assert.Equal(t, time.Hour-2*time.Minute, ttl)
+	})
+}
+
+func TestDataStore_Stats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		dataStore.SetCapacity(Capacity{MaxEntries: 10})
+		dataStore.Set("key1", []byte("value1"), 0)
+		dataStore.SetCached("key2", []byte("value22"), time.Minute, nil)
+
+		stats := dataStore.Stats()
+
+		assert.Equal(t, Stats{
+			Entries:        2,
+			Bytes:          13,
+			CachedEntries:  1,
+			CachedBytes:    7,
+			NextExpiration: currentDate.Add(time.Minute),
+			MaxEntries:     10,
+		}, stats)
+	})
+}
+
+func TestNewDiskDataStore_ShouldRestoreCachedCopies(t *testing.T) {
+	dir := t.TempDir()
+	timeProvider := &util.FakeTimeProvider{InternalTime: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	store.SetCached("key1", []byte("value1"), 0, nil)
+	store.SetPublished("key2", []byte("value2"), 0, []byte("publisher"))
+	store.Close()
+
+	restored, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	defer restored.Close()
+	entries := restored.Entries("", 0)
+	publisher, _ := restored.Publisher("key1")
+
+	assert.Equal(t, 2, len(entries))
+	assert.True(t, entries[0].Cached)
+	assert.False(t, entries[1].Cached)
+	assert.Nil(t, publisher)
+}
@@ -22,8 +22,16 @@ func (j *Janitor) Run(store *DataStore) {
 	}
 }
 
+// Stop the janitor of the datastore, if it is still running.
 func stopJanitor(store *DataStore) {
-	store.janitor.stop <- true
+	store.lock.Lock()
+	janitor := store.janitor
+	store.janitor = nil
+	store.lock.Unlock()
+
+	if janitor != nil {
+		janitor.stop <- true
+	}
 }
 
 // Create a new janitor with the given interval.
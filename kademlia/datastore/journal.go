@@ -0,0 +1,307 @@
+package datastore
+
+import (
+	"bufio"
+	"d7024e/logging"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The journal is an append-only log of every change made to a datastore.
+// Replaying the log from the start rebuilds the datastore. Each record is
+// written as:
+//
+//	[length uint32][crc32 uint32][op uint8][expiration int64][keylength uint32][key][value]
+//
+// where length and crc32 cover everything after the crc32 field. A record
+// that is cut short or fails its checksum marks the end of the log, which is
+// where a crash during a write would leave it.
+//
+// Set records carry the TTL of the dataobject as an int64 in front of the
+// value. Journals written before TTLs were stored per dataobject use the
+// older set record without it. Dataobjects with a publisher are written as
+// published set records, which also carry the public key of the publisher as
+//
+//	[ttl int64][publisherlength uint16][publisher][value]
+//
+// Copies cached by a lookup are written as cached set records, with the same
+// layout as published set records.
+
+const (
+	JOURNAL_FILENAME = "datastore.log"
+
+	// Minimum number of records in the journal before it is compacted
+	JOURNAL_COMPACTION_THRESHOLD = 1024
+)
+
+const (
+	journalOpSetNoTTL = 1
+	journalOpRemove   = 2
+	journalOpRefresh  = 3
+	journalOpSet      = 4
+	journalOpPublish  = 5
+	journalOpCache    = 6
+
+	journalHeaderSize  = 8
+	journalPayloadSize = 1 + 8 + 4
+	journalMaxRecord   = 64 * 1024 * 1024
+)
+
+var errCorruptRecord = errors.New("corrupt journal record")
+
+type journalRecord struct {
+	Op         byte
+	Key        string
+	Value      []byte
+	TTL        time.Duration
+	Publisher  []byte
+	Cached     bool
+	Expiration time.Time
+}
+
+type journal struct {
+	path    string
+	file    *os.File
+	records int
+}
+
+// Open the journal in the given directory, creating it if it does not exist.
+// Every intact record is passed to `replay` in the order it was written. Any
+// trailing partial record is truncated away.
+func openJournal(dir string, logger *logging.Logger, replay func(record journalRecord)) (*journal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, JOURNAL_FILENAME)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &journal{path: path, file: file}
+	validLength, err := j.replay(replay)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() != validLength {
+		logger.Warn("Truncating journal", "path", path, "from", info.Size(), "to", validLength)
+		if err := file.Truncate(validLength); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(validLength, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// Read all records from the start of the journal. Returns the length of the
+// intact part of the journal.
+func (j *journal) replay(fn func(record journalRecord)) (validLength int64, err error) {
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(j.file)
+	for {
+		record, size, err := readJournalRecord(reader)
+		if err == io.EOF || err == io.ErrUnexpectedEOF || err == errCorruptRecord {
+			return validLength, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		fn(record)
+		validLength += int64(size)
+		j.records++
+	}
+}
+
+// Append a record to the journal. If `sync` is true, the record is flushed
+// to stable storage before returning.
+func (j *journal) append(record journalRecord, sync bool) error {
+	if _, err := j.file.Write(encodeJournalRecord(record)); err != nil {
+		return err
+	}
+	j.records++
+	if sync {
+		return j.file.Sync()
+	}
+	return nil
+}
+
+// Check if the journal holds enough stale records to be worth compacting.
+func (j *journal) shouldCompact(liveRecords int) bool {
+	return j.records > JOURNAL_COMPACTION_THRESHOLD && j.records > 2*liveRecords
+}
+
+// Replace the journal with one that only holds the given records. The new
+// journal is written to a temporary file which then atomically replaces the
+// old one, so a crash during compaction leaves either the old or the new
+// journal intact.
+func (j *journal) compact(records []journalRecord) error {
+	tmpPath := j.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, record := range records {
+		if _, err := writer.Write(encodeJournalRecord(record)); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	syncDir(filepath.Dir(j.path))
+
+	j.file.Close()
+	j.file = tmp
+	j.records = len(records)
+	_, err = j.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (j *journal) close() error {
+	if err := j.file.Sync(); err != nil {
+		j.file.Close()
+		return err
+	}
+	return j.file.Close()
+}
+
+func encodeJournalRecord(record journalRecord) []byte {
+	op := record.Op
+	value := record.Value
+	if op == journalOpSet && (record.Publisher != nil || record.Cached) {
+		op = journalOpPublish
+		if record.Cached {
+			op = journalOpCache
+		}
+		value = make([]byte, 8+2+len(record.Publisher)+len(record.Value))
+		binary.BigEndian.PutUint64(value[0:8], uint64(record.TTL))
+		binary.BigEndian.PutUint16(value[8:10], uint16(len(record.Publisher)))
+		copy(value[10:], record.Publisher)
+		copy(value[10+len(record.Publisher):], record.Value)
+	} else if op == journalOpSet {
+		value = make([]byte, 8+len(record.Value))
+		binary.BigEndian.PutUint64(value[0:8], uint64(record.TTL))
+		copy(value[8:], record.Value)
+	}
+
+	payloadLength := journalPayloadSize + len(record.Key) + len(value)
+	buf := make([]byte, journalHeaderSize+payloadLength)
+
+	payload := buf[journalHeaderSize:]
+	payload[0] = op
+	binary.BigEndian.PutUint64(payload[1:9], uint64(record.Expiration.UnixNano()))
+	binary.BigEndian.PutUint32(payload[9:13], uint32(len(record.Key)))
+	copy(payload[13:], record.Key)
+	copy(payload[13+len(record.Key):], value)
+
+	binary.BigEndian.PutUint32(buf[0:4], uint32(payloadLength))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	return buf
+}
+
+func readJournalRecord(reader io.Reader) (record journalRecord, size int, err error) {
+	header := make([]byte, journalHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return record, 0, err
+	}
+
+	payloadLength := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if payloadLength < journalPayloadSize || payloadLength > journalMaxRecord {
+		return record, 0, errCorruptRecord
+	}
+
+	payload := make([]byte, payloadLength)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return record, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return record, 0, errCorruptRecord
+	}
+
+	keyLength := binary.BigEndian.Uint32(payload[9:13])
+	if uint32(journalPayloadSize)+keyLength > payloadLength {
+		return record, 0, errCorruptRecord
+	}
+
+	record.Op = payload[0]
+	record.Expiration = time.Unix(0, int64(binary.BigEndian.Uint64(payload[1:9])))
+	record.Key = string(payload[13 : 13+keyLength])
+	switch record.Op {
+	case journalOpSetNoTTL:
+		record.Op = journalOpSet
+		record.Value = payload[13+keyLength:]
+	case journalOpSet:
+		value := payload[13+keyLength:]
+		if len(value) < 8 {
+			return record, 0, errCorruptRecord
+		}
+		record.TTL = time.Duration(binary.BigEndian.Uint64(value[0:8]))
+		record.Value = value[8:]
+	case journalOpPublish, journalOpCache:
+		value := payload[13+keyLength:]
+		if len(value) < 10 {
+			return record, 0, errCorruptRecord
+		}
+		publisherLength := int(binary.BigEndian.Uint16(value[8:10]))
+		if len(value) < 10+publisherLength {
+			return record, 0, errCorruptRecord
+		}
+		record.Cached = record.Op == journalOpCache
+		record.Op = journalOpSet
+		record.TTL = time.Duration(binary.BigEndian.Uint64(value[0:8]))
+		if publisherLength > 0 {
+			record.Publisher = value[10 : 10+publisherLength]
+		}
+		record.Value = value[10+publisherLength:]
+	}
+	return record, journalHeaderSize + int(payloadLength), nil
+}
+
+// Flush directory entries, such as a rename, to stable storage. Not all
+// platforms support this, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,261 @@
+package datastore
+
+import (
+	"bytes"
+	"d7024e/util"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDiskDataStore_ShouldRestoreDataobjects(t *testing.T) {
+	dir := t.TempDir()
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeProvider := &util.FakeTimeProvider{InternalTime: currentDate}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	store.Set("key1", []byte("value1"), 0)
+	store.Set("key2", []byte("value2"), 0)
+	store.Set("key3", []byte("value3"), 0)
+	store.Remove("key2")
+	timeProvider.InternalTime = currentDate.Add(time.Minute)
+	store.Refresh("key3")
+	store.Close()
+
+	restored, err := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	defer restored.Close()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(restored.dataobjects))
+	assert.Equal(t, []byte("value1"), restored.dataobjects["key1"].Value)
+	assert.Equal(t, currentDate.Add(time.Hour), restored.dataobjects["key1"].Expiration.UTC())
+	assert.Equal(t, currentDate.Add(time.Minute+time.Hour), restored.dataobjects["key3"].Expiration.UTC())
+}
+
+func TestNewDiskDataStore_WithEveryByteValue_ShouldRestoreSameBytes(t *testing.T) {
+	dir := t.TempDir()
+	value := make([]byte, 256)
+	for i := range value {
+		value[i] = byte(i)
+	}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, &util.TimeProvider{})
+	store.Set("key", value, 0)
+	store.Close()
+
+	restored, err := NewDiskDataStore(dir, time.Hour, emptyOnExpired, &util.TimeProvider{})
+	defer restored.Close()
+
+	assert.Nil(t, err)
+	assert.Equal(t, value, restored.dataobjects["key"].Value)
+}
+
+func TestNewDiskDataStore_ShouldNotRestoreExpiredDataobjects(t *testing.T) {
+	dir := t.TempDir()
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeProvider := &util.FakeTimeProvider{InternalTime: currentDate}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	store.Set("key1", []byte("value1"), 0)
+	store.Close()
+
+	timeProvider.InternalTime = currentDate.Add(2 * time.Hour)
+	restored, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	defer restored.Close()
+
+	assert.Empty(t, restored.dataobjects)
+}
+
+func TestNewDiskDataStore_WithTruncatedJournal_ShouldRecoverIntactRecords(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, JOURNAL_FILENAME)
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeProvider := &util.FakeTimeProvider{InternalTime: currentDate}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	store.Set("key1", []byte("value1"), 0)
+	store.Close()
+
+	// Simulate a crash in the middle of writing a record
+	partialRecord := encodeJournalRecord(journalRecord{Op: journalOpSet, Key: "key2", Value: []byte("value2"), Expiration: currentDate})
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	file.Write(partialRecord[:len(partialRecord)-3])
+	file.Close()
+
+	restored, err := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	assert.Nil(t, err)
+	restored.Set("key3", []byte("value3"), 0)
+	restored.Close()
+
+	restored, _ = NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	defer restored.Close()
+
+	assert.Equal(t, 2, len(restored.dataobjects))
+	assert.Contains(t, restored.dataobjects, "key1")
+	assert.Contains(t, restored.dataobjects, "key3")
+}
+
+func TestNewDiskDataStore_WithCorruptRecord_ShouldStopReplay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, JOURNAL_FILENAME)
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeProvider := &util.FakeTimeProvider{InternalTime: currentDate}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	store.Set("key1", []byte("value1"), 0)
+	store.Set("key2", []byte("value2"), 0)
+	store.Close()
+
+	// Flip the last byte of the last record
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0xFF
+	os.WriteFile(path, data, 0o644)
+
+	restored, err := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	defer restored.Close()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(restored.dataobjects))
+	assert.Contains(t, restored.dataobjects, "key1")
+}
+
+func TestDataStore_RemoveExpired_ShouldCompactJournal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, JOURNAL_FILENAME)
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeProvider := &util.FakeTimeProvider{InternalTime: currentDate}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	defer store.Close()
+	store.Set("key1", []byte("value1"), 0)
+	for i := 0; i < JOURNAL_COMPACTION_THRESHOLD; i++ {
+		store.Set("key2", []byte("value2"), 0)
+		store.Remove("key2")
+	}
+	sizeBefore, _ := os.Stat(path)
+
+	store.RemoveExpired()
+
+	sizeAfter, _ := os.Stat(path)
+	assert.Equal(t, 1, store.journal.records)
+	assert.Less(t, sizeAfter.Size(), sizeBefore.Size())
+	value, exists := store.Get("key1")
+	assert.True(t, exists)
+	assert.Equal(t, []byte("value1"), value)
+}
+
+func TestDataStore_Get_ShouldJournalRefreshOnceUntilFlushed(t *testing.T) {
+	dir := t.TempDir()
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeProvider := &util.FakeTimeProvider{InternalTime: currentDate}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	store.Set("key1", []byte("value1"), 0)
+	recordsAfterSet := store.journal.records
+	timeProvider.InternalTime = currentDate.Add(30 * time.Minute)
+	for i := 0; i < 100; i++ {
+		store.Get("key1")
+	}
+	recordsAfterGets := store.journal.records
+	store.RemoveExpired()
+	recordsAfterFlush := store.journal.records
+	store.Close()
+
+	restored, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	defer restored.Close()
+	timeProvider.InternalTime = currentDate.Add(80 * time.Minute)
+	_, exists := restored.Get("key1")
+
+	assert.Equal(t, recordsAfterSet, recordsAfterGets)
+	assert.Equal(t, recordsAfterSet+1, recordsAfterFlush)
+	assert.True(t, exists)
+}
+
+func TestEncodeJournalRecord(t *testing.T) {
+	expected := journalRecord{
+		Op:         journalOpSet,
+		Key:        "key",
+		Value:      []byte{0, 1, 2, 255},
+		Expiration: time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	encoded := encodeJournalRecord(expected)
+	actual, size, err := readJournalRecord(bytes.NewReader(encoded))
+
+	assert.Nil(t, err)
+	assert.Equal(t, len(encoded), size)
+	assert.Equal(t, expected.Op, actual.Op)
+	assert.Equal(t, expected.Key, actual.Key)
+	assert.Equal(t, expected.Value, actual.Value)
+	assert.True(t, expected.Expiration.Equal(actual.Expiration))
+}
+
+func TestEncodeJournalRecord_WithPublisher(t *testing.T) {
+	expected := journalRecord{
+		Op:         journalOpSet,
+		Key:        "key",
+		Value:      []byte("value"),
+		TTL:        time.Minute,
+		Publisher:  []byte("publisher"),
+		Expiration: time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	encoded := encodeJournalRecord(expected)
+	actual, _, err := readJournalRecord(bytes.NewReader(encoded))
+
+	assert.Nil(t, err)
+	assert.Equal(t, byte(journalOpPublish), encoded[journalHeaderSize])
+	assert.Equal(t, expected.Op, actual.Op)
+	assert.Equal(t, expected.TTL, actual.TTL)
+	assert.Equal(t, expected.Publisher, actual.Publisher)
+	assert.Equal(t, expected.Value, actual.Value)
+}
+
+func TestNewDiskDataStore_ShouldRestorePublisher(t *testing.T) {
+	dir := t.TempDir()
+	timeProvider := &util.FakeTimeProvider{InternalTime: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	store.SetPublished("key1", []byte("value1"), 0, []byte("publisher"))
+	store.Set("key2", []byte("value2"), 0)
+	store.Close()
+
+	restored, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	defer restored.Close()
+	publisher1, _ := restored.Publisher("key1")
+	publisher2, exists2 := restored.Publisher("key2")
+
+	assert.Equal(t, []byte("publisher"), publisher1)
+	assert.Equal(t, []byte("value1"), restored.dataobjects["key1"].Value)
+	assert.Nil(t, publisher2)
+	assert.True(t, exists2)
+}
+
+func TestNewDiskDataStore_ShouldRestoreTTL(t *testing.T) {
+	dir := t.TempDir()
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeProvider := &util.FakeTimeProvider{InternalTime: currentDate}
+
+	store, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	store.Set("key1", []byte("value1"), time.Minute)
+	store.Close()
+
+	restored, _ := NewDiskDataStore(dir, time.Hour, emptyOnExpired, timeProvider)
+	defer restored.Close()
+
+	assert.Equal(t, time.Minute, restored.dataobjects["key1"].TTL)
+}
+
+func TestReadJournalRecord_WithRecordWithoutTTL(t *testing.T) {
+	encoded := encodeJournalRecord(journalRecord{Op: journalOpSetNoTTL, Key: "key", Value: []byte("value")})
+
+	actual, _, err := readJournalRecord(bytes.NewReader(encoded))
+
+	assert.Nil(t, err)
+	assert.Equal(t, byte(journalOpSet), actual.Op)
+	assert.Equal(t, []byte("value"), actual.Value)
+	assert.Equal(t, time.Duration(0), actual.TTL)
+}
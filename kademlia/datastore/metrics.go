@@ -0,0 +1,45 @@
+package datastore
+
+import (
+	"d7024e/metrics"
+	"sync"
+	"sync/atomic"
+)
+
+var registerMetrics sync.Once
+
+// Add the size of a datastore, and the dataobjects that expired from it, to
+// the metrics of the node. Only the first datastore registered is reported,
+// so this is called once, for the datastore of the node.
+//
+// The stats of the datastore are read once per scrape, before any of its
+// metrics is exported.
+func RegisterMetrics(store IDataStore) {
+	registerMetrics.Do(func() {
+		var scraped atomic.Value
+		scraped.Store(Stats{})
+		metrics.OnScrape(func() { scraped.Store(store.Stats()) })
+		stats := func() Stats { return scraped.Load().(Stats) }
+
+		metrics.NewGaugeFunc(
+			"kademlia_datastore_entries",
+			"Dataobjects in the datastore, replicas and cached copies alike.",
+			func() float64 { return float64(stats().Entries) })
+		metrics.NewGaugeFunc(
+			"kademlia_datastore_bytes",
+			"Bytes of the values in the datastore.",
+			func() float64 { return float64(stats().Bytes) })
+		metrics.NewGaugeFunc(
+			"kademlia_datastore_cached_entries",
+			"Dataobjects in the datastore that are copies cached by a lookup.",
+			func() float64 { return float64(stats().CachedEntries) })
+		metrics.NewCounterFunc(
+			"kademlia_datastore_expired_total",
+			"Dataobjects that expired from the datastore, replicas and cached copies alike.",
+			func() float64 { return float64(stats().Expired) })
+		metrics.NewCounterFunc(
+			"kademlia_datastore_expired_cached_total",
+			"Dataobjects that expired from the datastore that were copies cached by a lookup.",
+			func() float64 { return float64(stats().ExpiredCached) })
+	})
+}
@@ -0,0 +1,261 @@
+package datastore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"d7024e/util"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// A snapshot is a portable archive of the dataobjects of a datastore, for
+// backups and for moving a node to another machine. It is a gzip compressed
+// stream of JSON lines: a header, followed by one line per dataobject. gzip
+// checks the archive as a whole, and each dataobject carries a checksum of
+// its value.
+
+const (
+	SNAPSHOT_FORMAT  = "kademlia-datastore-snapshot"
+	SNAPSHOT_VERSION = 2
+
+	// Largest snapshot that is read, in bytes after it is decompressed. The
+	// size of a compressed snapshot says little about its content.
+	SNAPSHOT_MAX_SIZE = 4 << 30
+)
+
+var (
+	ErrSnapshotFormat   = errors.New("not a datastore snapshot")
+	ErrSnapshotChecksum = errors.New("snapshot entry does not match its checksum")
+	ErrSnapshotTooLarge = errors.New("snapshot is too large")
+)
+
+type snapshotHeader struct {
+	Format  string
+	Version int
+	Created time.Time
+}
+
+// A dataobject in a snapshot
+type SnapshotEntry struct {
+	Key   string
+	Value []byte
+	// TTL the dataobject was stored with, and when it expires. Version 1
+	// snapshots only hold the time left until it expired, as the TTL.
+	TTL        time.Duration
+	Expiration time.Time
+	Publisher  []byte `json:",omitempty"`
+	Cached     bool   `json:",omitempty"`
+	// Hex encoded SHA-256 of the value
+	Checksum string
+}
+
+// Create a snapshot entry of a dataobject, with the checksum of its value
+func NewSnapshotEntry(key string, value []byte, ttl time.Duration, expiration time.Time, publisher []byte, cached bool) SnapshotEntry {
+	return SnapshotEntry{
+		Key:        key,
+		Value:      value,
+		TTL:        ttl,
+		Expiration: expiration,
+		Publisher:  publisher,
+		Cached:     cached,
+		Checksum:   snapshotChecksum(value),
+	}
+}
+
+// Write a snapshot of the given dataobjects
+func WriteSnapshot(w io.Writer, entries []SnapshotEntry, created time.Time) error {
+	compressed := gzip.NewWriter(w)
+	encoder := json.NewEncoder(compressed)
+
+	if err := encoder.Encode(snapshotHeader{SNAPSHOT_FORMAT, SNAPSHOT_VERSION, created}); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return compressed.Close()
+}
+
+// Reads the dataobjects of a snapshot one at a time, so that they can be
+// restored without holding the whole snapshot in memory
+type SnapshotReader struct {
+	compressed *gzip.Reader
+	decoder    *json.Decoder
+	header     snapshotHeader
+}
+
+// Start reading a snapshot. Reading fails with ErrSnapshotTooLarge once more
+// than `maxBytes` are decompressed.
+//
+// Returns ErrSnapshotFormat if `r` is not a snapshot, or an error if its
+// version is not supported.
+func NewSnapshotReader(r io.Reader, maxBytes int64) (*SnapshotReader, error) {
+	compressed, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, ErrSnapshotFormat
+	}
+	reader := &SnapshotReader{
+		compressed: compressed,
+		decoder:    json.NewDecoder(&sizeLimitedReader{compressed, maxBytes}),
+	}
+
+	if err := reader.decoder.Decode(&reader.header); err != nil || reader.header.Format != SNAPSHOT_FORMAT {
+		compressed.Close()
+		if errors.Is(err, ErrSnapshotTooLarge) {
+			return nil, err
+		}
+		return nil, ErrSnapshotFormat
+	}
+	if reader.header.Version != 1 && reader.header.Version != SNAPSHOT_VERSION {
+		compressed.Close()
+		return nil, fmt.Errorf("unsupported snapshot version %d", reader.header.Version)
+	}
+	return reader, nil
+}
+
+// Read the next dataobject of the snapshot.
+//
+// Returns io.EOF after the last dataobject. Fails if the snapshot is cut short
+// or too large, or the dataobject does not match its checksum.
+func (reader *SnapshotReader) Next() (SnapshotEntry, error) {
+	var entry SnapshotEntry
+	if err := reader.decoder.Decode(&entry); err != nil {
+		return SnapshotEntry{}, err
+	}
+	if entry.Checksum != snapshotChecksum(entry.Value) {
+		return SnapshotEntry{}, fmt.Errorf("%w: %s", ErrSnapshotChecksum, entry.Key)
+	}
+	if reader.header.Version == 1 {
+		entry.Expiration = reader.header.Created.Add(entry.TTL)
+	}
+	return entry, nil
+}
+
+// Stop reading the snapshot. Does not close the reader it was read from.
+func (reader *SnapshotReader) Close() error {
+	return reader.compressed.Close()
+}
+
+// Read all the dataobjects of a snapshot. Fails if the snapshot is cut short,
+// larger than SNAPSHOT_MAX_SIZE, or any dataobject does not match its
+// checksum.
+func ReadSnapshot(r io.Reader) ([]SnapshotEntry, error) {
+	reader, err := NewSnapshotReader(r, SNAPSHOT_MAX_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	entries := []SnapshotEntry{}
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// Reader that fails with ErrSnapshotTooLarge once more than `left` bytes are
+// read from it
+type sizeLimitedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (reader *sizeLimitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > reader.left+1 {
+		p = p[:reader.left+1]
+	}
+	n, err := reader.r.Read(p)
+	if int64(n) > reader.left {
+		n = int(reader.left)
+		reader.left = 0
+		return n, ErrSnapshotTooLarge
+	}
+	reader.left -= int64(n)
+	return n, err
+}
+
+func (store *DataStore) Snapshot() []SnapshotEntry {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	entries := make([]SnapshotEntry, 0, len(store.dataobjects))
+	for key, dataobject := range store.dataobjects {
+		if dataobject.IsExpired(store.time) {
+			continue
+		}
+		entries = append(entries, NewSnapshotEntry(key, dataobject.Value, store.ttlOf(dataobject), dataobject.Expiration, dataobject.Publisher, dataobject.Cached))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+func (store *DataStore) Restore(entries []SnapshotEntry) ([]SnapshotEntry, error) {
+	restored := []SnapshotEntry{}
+	for _, entry := range entries {
+		if util.Hash(entry.Value) != entry.Key {
+			store.logger.Warn("Skipped snapshot entry: value does not match key", "key", entry.Key)
+			continue
+		}
+		err := store.restore(entry)
+		if errors.Is(err, ErrKeyExists) || errors.Is(err, errExpired) {
+			continue
+		}
+		if err != nil {
+			return restored, err
+		}
+		restored = append(restored, entry)
+	}
+	return restored, nil
+}
+
+var errExpired = errors.New("dataobject has expired")
+
+// Add a dataobject of a snapshot with the TTL and expiration it had. It
+// cannot expire later than its TTL from now.
+func (store *DataStore) restore(entry SnapshotEntry) error {
+	store.lock.Lock()
+	defer store.unlock()
+
+	ttl := entry.TTL
+	if ttl <= 0 {
+		ttl = store.defaultExpiration
+	}
+	expiration := entry.Expiration
+	if latest := store.time.Now().Add(ttl); expiration.After(latest) {
+		expiration = latest
+	}
+	if !expiration.After(store.time.Now()) {
+		return errExpired
+	}
+
+	dataobject, exists := store.dataobjects[entry.Key]
+	if exists && !dataobject.IsExpired(store.time) {
+		return ErrKeyExists
+	}
+	if exists {
+		store._expire(entry.Key, dataobject)
+	}
+	if err := store.reserve(len(entry.Value)); err != nil {
+		return err
+	}
+	return store._setUntil(entry.Key, entry.Value, ttl, expiration, entry.Publisher, entry.Cached)
+}
+
+func snapshotChecksum(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,165 @@
+package datastore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"d7024e/util"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSnapshot_ShouldBeReadBack(t *testing.T) {
+	value := make([]byte, 256)
+	for i := range value {
+		value[i] = byte(i)
+	}
+	expiration := time.Date(1970, 1, 1, 1, 0, 0, 0, time.UTC)
+	expected := []SnapshotEntry{
+		NewSnapshotEntry("key1", value, time.Minute, expiration, []byte("publisher"), false),
+		NewSnapshotEntry("key2", []byte{}, time.Hour, expiration, nil, true),
+	}
+	buf := new(bytes.Buffer)
+
+	errWrite := WriteSnapshot(buf, expected, time.Now())
+	actual, errRead := ReadSnapshot(buf)
+
+	assert.Nil(t, errWrite)
+	assert.Nil(t, errRead)
+	assert.Equal(t, expected, actual)
+}
+
+func TestReadSnapshot_WithWrongChecksum_ShouldReturnError(t *testing.T) {
+	entry := NewSnapshotEntry("key1", []byte("value1"), time.Minute, time.Now(), nil, false)
+	entry.Value = []byte("value2")
+	buf := new(bytes.Buffer)
+	WriteSnapshot(buf, []SnapshotEntry{entry}, time.Now())
+
+	_, err := ReadSnapshot(buf)
+
+	assert.ErrorIs(t, err, ErrSnapshotChecksum)
+}
+
+func TestReadSnapshot_WithOtherData_ShouldReturnErrSnapshotFormat(t *testing.T) {
+	compressed := new(bytes.Buffer)
+	writer := gzip.NewWriter(compressed)
+	writer.Write([]byte(`{"Format":"something else"}`))
+	writer.Close()
+
+	_, errPlain := ReadSnapshot(bytes.NewReader([]byte("not gzip")))
+	_, errCompressed := ReadSnapshot(compressed)
+
+	assert.ErrorIs(t, errPlain, ErrSnapshotFormat)
+	assert.ErrorIs(t, errCompressed, ErrSnapshotFormat)
+}
+
+func TestReadSnapshot_WhenCutShort_ShouldReturnError(t *testing.T) {
+	buf := new(bytes.Buffer)
+	WriteSnapshot(buf, []SnapshotEntry{NewSnapshotEntry("key1", []byte("value1"), time.Minute, time.Now(), nil, false)}, time.Now())
+
+	_, err := ReadSnapshot(bytes.NewReader(buf.Bytes()[:buf.Len()-4]))
+
+	assert.NotNil(t, err)
+}
+
+func TestSnapshotReader_WhenLargerThanMaxBytes_ShouldReturnErrSnapshotTooLarge(t *testing.T) {
+	value := make([]byte, 1024)
+	buf := new(bytes.Buffer)
+	WriteSnapshot(buf, []SnapshotEntry{NewSnapshotEntry("key1", value, time.Minute, time.Now(), nil, false)}, time.Now())
+	compressed := buf.Bytes()
+
+	small, errSmall := NewSnapshotReader(bytes.NewReader(compressed), 1024)
+	_, errNext := small.Next()
+	large, errLarge := NewSnapshotReader(bytes.NewReader(compressed), 4096)
+	_, errLargeNext := large.Next()
+	_, errEnd := large.Next()
+	_, errHeader := NewSnapshotReader(bytes.NewReader(compressed), 16)
+
+	assert.Nil(t, errSmall)
+	assert.ErrorIs(t, errNext, ErrSnapshotTooLarge)
+	assert.Nil(t, errLarge)
+	assert.Nil(t, errLargeNext)
+	assert.Equal(t, io.EOF, errEnd)
+	assert.ErrorIs(t, errHeader, ErrSnapshotTooLarge)
+}
+
+func TestReadSnapshot_WithVersion1_ShouldExpireAfterTimeLeft(t *testing.T) {
+	created := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	compressed := new(bytes.Buffer)
+	writer := gzip.NewWriter(compressed)
+	encoder := json.NewEncoder(writer)
+	encoder.Encode(snapshotHeader{SNAPSHOT_FORMAT, 1, created})
+	encoder.Encode(SnapshotEntry{Key: "key1", Value: []byte("value1"), TTL: time.Minute, Checksum: snapshotChecksum([]byte("value1"))})
+	writer.Close()
+
+	actual, err := ReadSnapshot(compressed)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(actual))
+	assert.True(t, created.Add(time.Minute).Equal(actual[0].Expiration))
+}
+
+func TestDataStore_Restore_ShouldKeepTTLAndExpiration(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		key1 := util.Hash([]byte("value1"))
+		key2 := util.Hash([]byte("value2"))
+		source := createNewDatastore(time.Hour, currentDate)
+		source.SetPublished(key1, []byte("value1"), 0, []byte("publisher"))
+		source.SetCached(key2, []byte("value2"), time.Minute, nil)
+		target := createNewDatastore(time.Hour, currentDate.Add(time.Second))
+		target.Set(key2, []byte("existing"), 0)
+
+		restored, err := target.Restore(source.Snapshot())
+		value, _ := target.Get(key2)
+		ttl, _ := target.TTL(key1)
+		publisher, _ := target.Publisher(key1)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(restored))
+		assert.Equal(t, key1, restored[0].Key)
+		assert.Equal(t, time.Hour, restored[0].TTL)
+		assert.Equal(t, []byte("existing"), value)
+		assert.Equal(t, time.Hour-time.Second, ttl)
+		assert.Equal(t, []byte("publisher"), publisher)
+	})
+}
+
+func TestDataStore_Restore_ShouldSkipInvalidAndExpiredEntries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		valid := NewSnapshotEntry(util.Hash([]byte("valid")), []byte("valid"), time.Minute, currentDate.Add(time.Hour), nil, false)
+
+		restored, err := dataStore.Restore([]SnapshotEntry{
+			NewSnapshotEntry("record:key", []byte("record"), time.Minute, currentDate.Add(time.Minute), nil, false),
+			NewSnapshotEntry(util.Hash([]byte("expired")), []byte("expired"), time.Minute, currentDate.Add(-time.Second), nil, false),
+			valid,
+		})
+		ttl, _ := dataStore.TTL(valid.Key)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []SnapshotEntry{valid}, restored)
+		assert.Equal(t, 1, dataStore.Stats().Entries)
+		assert.Equal(t, time.Minute, ttl)
+	})
+}
+
+func TestDataStore_Restore_WhenFull_ShouldReturnRestoredAndError(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, createNewDatastore datastoreFactory) {
+		currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		dataStore := createNewDatastore(time.Hour, currentDate)
+		dataStore.SetCapacity(Capacity{MaxEntries: 1})
+
+		restored, err := dataStore.Restore([]SnapshotEntry{
+			NewSnapshotEntry(util.Hash([]byte("value1")), []byte("value1"), time.Minute, currentDate.Add(time.Minute), nil, false),
+			NewSnapshotEntry(util.Hash([]byte("value2")), []byte("value2"), time.Minute, currentDate.Add(time.Minute), nil, false),
+		})
+
+		assert.ErrorIs(t, err, ErrStoreFull)
+		assert.Equal(t, 1, len(restored))
+	})
+}
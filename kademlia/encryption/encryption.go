@@ -0,0 +1,135 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Values are encrypted with AES-256-GCM before they are stored. The stored
+// value is the nonce followed by the ciphertext, so nodes only ever hold the
+// ciphertext. The key is either given by the user, or derived from the value
+// itself (convergent encryption), in which case the same value always
+// encrypts to the same ciphertext and is only stored once.
+//
+// Convergent encryption lets anyone who can guess a value confirm that it is
+// stored, so values that are easy to guess should be encrypted with a given
+// key instead.
+
+const KEY_SIZE = 32
+
+var (
+	ErrInvalidKey        = fmt.Errorf("key must be %d bytes", KEY_SIZE)
+	ErrDecryptionFailed  = errors.New("decryption failed, wrong key or corrupt value")
+	ErrInvalidCapability = errors.New("invalid capability, expected [hash]:[key]")
+)
+
+// Derive the key of a value for convergent encryption
+func DeriveKey(plaintext []byte) []byte {
+	key := sha256.Sum256(plaintext)
+	return key[:]
+}
+
+// Encrypt a value with a given key, under a random nonce.
+func Encrypt(key []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Encrypt a value with a key derived from the value itself.
+//
+// Returns the key and the encrypted value.
+func EncryptConvergent(plaintext []byte) (key []byte, ciphertext []byte, err error) {
+	key = DeriveKey(plaintext)
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The key is never used for another value, so a nonce derived from the
+	// key is safe, and keeps the ciphertext the same for the same value
+	nonceSource := sha256.Sum256(append([]byte("nonce:"), key...))
+	nonce := nonceSource[:gcm.NonceSize()]
+	return key, gcm.Seal(append([]byte(nil), nonce...), nonce, plaintext, nil), nil
+}
+
+// Decrypt a value encrypted with Encrypt or EncryptConvergent.
+func Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+
+	nonce := ciphertext[:gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KEY_SIZE {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Parse a hex encoded key
+func ParseKey(str string) ([]byte, error) {
+	key, err := hex.DecodeString(str)
+	if err != nil || len(key) != KEY_SIZE {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// A capability is everything needed to read an encrypted value: the hash it
+// is stored under and the key to decrypt it with. It is written as
+// "[hash]:[key]", with the key in hex.
+type Capability struct {
+	Hash string
+	Key  []byte
+}
+
+func (capability Capability) String() string {
+	return capability.Hash + ":" + hex.EncodeToString(capability.Key)
+}
+
+// Check if a string looks like a capability rather than a plain hash
+func IsCapability(str string) bool {
+	return strings.Contains(str, ":")
+}
+
+// Parse a capability written by Capability.String
+func ParseCapability(str string) (Capability, error) {
+	hashAndKey := strings.SplitN(str, ":", 2)
+	if len(hashAndKey) != 2 || hashAndKey[0] == "" {
+		return Capability{}, ErrInvalidCapability
+	}
+	key, err := ParseKey(hashAndKey[1])
+	if err != nil {
+		return Capability{}, ErrInvalidCapability
+	}
+	return Capability{Hash: hashAndKey[0], Key: key}, nil
+}
@@ -0,0 +1,82 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KEY_SIZE)
+	plaintext := []byte("secret message")
+
+	first, errFirst := Encrypt(key, plaintext)
+	second, _ := Encrypt(key, plaintext)
+	decrypted, errDecrypt := Decrypt(key, first)
+
+	assert.Nil(t, errFirst)
+	assert.Nil(t, errDecrypt)
+	assert.Equal(t, plaintext, decrypted)
+	assert.NotContains(t, string(first), "secret")
+	// A random nonce is used each time
+	assert.NotEqual(t, first, second)
+}
+
+func TestEncryptConvergent(t *testing.T) {
+	plaintext := []byte("secret message")
+
+	key, first, err := EncryptConvergent(plaintext)
+	_, second, _ := EncryptConvergent(plaintext)
+	_, other, _ := EncryptConvergent([]byte("other message"))
+	decrypted, errDecrypt := Decrypt(key, first)
+
+	assert.Nil(t, err)
+	assert.Nil(t, errDecrypt)
+	assert.Equal(t, plaintext, decrypted)
+	assert.Equal(t, DeriveKey(plaintext), key)
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, other)
+}
+
+func TestDecrypt_WithWrongKeyOrCorruptValue_ShouldFail(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KEY_SIZE)
+	ciphertext, _ := Encrypt(key, []byte("secret message"))
+	corrupt := append([]byte(nil), ciphertext...)
+	corrupt[len(corrupt)-1] ^= 1
+
+	_, errWrongKey := Decrypt(bytes.Repeat([]byte{8}, KEY_SIZE), ciphertext)
+	_, errCorrupt := Decrypt(key, corrupt)
+	_, errShort := Decrypt(key, []byte("short"))
+	_, errKeySize := Decrypt([]byte("short key"), ciphertext)
+
+	assert.ErrorIs(t, errWrongKey, ErrDecryptionFailed)
+	assert.ErrorIs(t, errCorrupt, ErrDecryptionFailed)
+	assert.ErrorIs(t, errShort, ErrDecryptionFailed)
+	assert.ErrorIs(t, errKeySize, ErrInvalidKey)
+}
+
+func TestParseCapability(t *testing.T) {
+	expected := Capability{Hash: "a17c9aaa61e80a1bf71d0d850af4e5baa9800bbd", Key: bytes.Repeat([]byte{0xab}, KEY_SIZE)}
+
+	actual, err := ParseCapability(expected.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+	assert.True(t, IsCapability(expected.String()))
+	assert.False(t, IsCapability(expected.Hash))
+}
+
+func TestParseCapability_WithInvalidCapability_ShouldReturnError(t *testing.T) {
+	var tests = []string{
+		"a17c9aaa61e80a1bf71d0d850af4e5baa9800bbd",
+		"a17c9aaa61e80a1bf71d0d850af4e5baa9800bbd:abcd",
+		"a17c9aaa61e80a1bf71d0d850af4e5baa9800bbd:not hex",
+		":" + Capability{Key: bytes.Repeat([]byte{1}, KEY_SIZE)}.String(),
+	}
+
+	for _, test := range tests {
+		_, err := ParseCapability(test)
+		assert.ErrorIs(t, err, ErrInvalidCapability, test)
+	}
+}
@@ -0,0 +1,71 @@
+package kademlia
+
+import (
+	"d7024e/config"
+	"d7024e/kademlia/datastore"
+	"fmt"
+)
+
+// Built-in actions to take when a dataobject expires
+const (
+	// Keep the dataobjects this node published from expiring, by storing them
+	// again at half their TTL. Not an expiry listener, as the replicas expire
+	// on other nodes.
+	EXPIRY_ACTION_REPUBLISH = config.EXPIRY_ACTION_REPUBLISH
+	// Count the expiration in the metrics of the node. The datastore counts
+	// every expiration itself, so this only remains as the default, to keep
+	// configurations that name it working. Not an expiry listener.
+	EXPIRY_ACTION_METRIC = config.EXPIRY_ACTION_METRIC
+	// Log the expiration
+	EXPIRY_ACTION_LOG = config.EXPIRY_ACTION_LOG
+)
+
+// Get the built-in expiry listener with the given name
+func (kademlia *Kademlia) ExpiryAction(name string) (datastore.ExpiryListener, error) {
+	switch name {
+	case EXPIRY_ACTION_REPUBLISH, EXPIRY_ACTION_METRIC:
+		return nil, fmt.Errorf("expiry action %q has no expiry listener", name)
+	case EXPIRY_ACTION_LOG:
+		return kademlia.logExpired, nil
+	}
+	return nil, fmt.Errorf("unknown expiry action %q, expected %s, %s or %s", name, EXPIRY_ACTION_REPUBLISH, EXPIRY_ACTION_METRIC, EXPIRY_ACTION_LOG)
+}
+
+// Add the built-in expiry actions in a comma separated list of names to the
+// datastore of the node, and start republishing if it is one of them. No
+// action is added unless all names are known.
+func (kademlia *Kademlia) AddExpiryActions(names string) error {
+	actions, err := config.ParseExpiryActions(names)
+	if err != nil {
+		return err
+	}
+
+	listeners := []datastore.ExpiryListener{}
+	republish := false
+	for _, name := range actions {
+		if name == EXPIRY_ACTION_REPUBLISH {
+			republish = true
+			continue
+		}
+		if name == EXPIRY_ACTION_METRIC {
+			continue
+		}
+		listener, err := kademlia.ExpiryAction(name)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	for _, listener := range listeners {
+		kademlia.dataStore.OnExpiry(listener)
+	}
+	if republish {
+		kademlia.StartRepublishing()
+	}
+	return nil
+}
+
+func (kademlia *Kademlia) logExpired(event datastore.ExpiryEvent) {
+	kademlia.logger.Info("Dataobject expired", "key", event.Key, "bytes", len(event.Value), "cached", event.Cached)
+}
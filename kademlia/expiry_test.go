@@ -0,0 +1,62 @@
+package kademlia
+
+import (
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/datastore"
+	"d7024e/kademlia/network/routing"
+	"d7024e/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddExpiryActions_WithUnknownName_ShouldAddNone(t *testing.T) {
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	storeMock := new(mocks.DataStoreMockObject)
+	kademlia := NewKademlia(&me, new(mocks.NetworkMockObject), storeMock)
+
+	err := kademlia.AddExpiryActions("metric, unknown")
+
+	assert.Error(t, err)
+	storeMock.AssertNotCalled(t, "OnExpiry")
+}
+
+func TestAddExpiryActions_WithRepublish_ShouldAddNoListener(t *testing.T) {
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	storeMock := new(mocks.DataStoreMockObject)
+	kademlia := NewKademlia(&me, new(mocks.NetworkMockObject), storeMock)
+
+	err := kademlia.AddExpiryActions("republish")
+
+	assert.NoError(t, err)
+	storeMock.AssertNotCalled(t, "OnExpiry")
+}
+
+func TestAddExpiryActions_WithMetric_ShouldAddNoListener(t *testing.T) {
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	storeMock := new(mocks.DataStoreMockObject)
+	kademlia := NewKademlia(&me, new(mocks.NetworkMockObject), storeMock)
+
+	err := kademlia.AddExpiryActions("metric")
+
+	assert.NoError(t, err)
+	storeMock.AssertNotCalled(t, "OnExpiry")
+}
+
+func TestAddExpiryActions_WithLog_ShouldKeepCountOfExpired(t *testing.T) {
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	currentDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeProvider := &util.FakeTimeProvider{InternalTime: currentDate}
+	dataStore := datastore.NewDataStore(time.Minute, nil, timeProvider)
+	kademlia := NewKademlia(&me, new(mocks.NetworkMockObject), dataStore)
+
+	err := kademlia.AddExpiryActions("metric,log")
+	dataStore.SetCached("key1", []byte("value1"), 0, nil)
+	timeProvider.InternalTime = currentDate.Add(2 * time.Minute)
+	dataStore.RemoveExpired()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dataStore.Stats().Expired)
+	assert.Equal(t, 1, dataStore.Stats().ExpiredCached)
+}
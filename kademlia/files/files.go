@@ -0,0 +1,455 @@
+package files
+
+import (
+	"d7024e/kademlia"
+	"d7024e/util"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// Size of each chunk of a file, in bytes. The last chunk may be smaller.
+	// Also the size of each shard of an erasure coded file.
+	FILE_CHUNK_SIZE = 4096
+
+	// Most chunks or parts listed in one manifest
+	FILE_MANIFEST_MAX_ENTRIES = 512
+
+	// Most chunks stored or fetched at the same time
+	FILE_PARALLEL_LOOKUPS = 8
+
+	// Size of the stripe and index a shard is prefixed with before it is
+	// stored
+	FILE_SHARD_HEADER_SIZE = 8
+)
+
+var (
+	ErrNotManifest  = errors.New("value is not a manifest")
+	ErrSizeMismatch = errors.New("reassembled file does not match its size")
+)
+
+// A file reassembled from its chunks
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Stored chunks or manifests that are listed together, and the size of the
+// range of the file they cover. A chunk or manifest is one entry, and an
+// erasure coded stripe is one entry with a hash per shard.
+type entry struct {
+	hashes []string
+	size   int64
+}
+
+// Store a file in the network as chunks, along with a manifest of them.
+//
+// Parameters:
+//
+//	`context` - The node to store the file through.
+//	`name` - The name of the file, kept in the manifest.
+//	`data` - The contents of the file.
+//	`ttl` - How long the chunks and manifests live without being fetched.
+//
+// Returns:
+//
+//	The hash of the manifest, which is what `Get` takes to reassemble the file.
+func Put(context kademlia.IKademlia, name string, data []byte, ttl time.Duration) (string, error) {
+	chunks := split(data, FILE_CHUNK_SIZE)
+	hashes, err := storeAll(context, chunks, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	entries := make([]entry, len(chunks))
+	for i, chunk := range chunks {
+		entries[i] = entry{[]string{hashes[i]}, int64(len(chunk))}
+	}
+	return putManifest(context, Manifest{Format: MANIFEST_FORMAT, Name: name}, entries, ttl)
+}
+
+// Store a file in the network erasure coded, along with a manifest of it.
+//
+// The file is split into stripes of `dataShards` shards, and each stripe is
+// extended with parity shards to `totalShards` shards. Every shard is stored
+// under its own hash, which spreads the shards of a stripe over the keyspace.
+// Shards are prefixed with their stripe and index before they are hashed, so
+// that identical shards, e.g. of a file of zeros, are not stored under the same
+// key. Any `dataShards` shards of a stripe are enough to rebuild it.
+//
+// Parameters:
+//
+//	`context` - The node to store the file through.
+//	`name` - The name of the file, kept in the manifest.
+//	`data` - The contents of the file.
+//	`ttl` - How long the shards and manifests live without being fetched.
+//	`dataShards` - Number of shards the data of a stripe is split into.
+//	`totalShards` - Number of shards stored per stripe, including parity.
+//
+// Returns:
+//
+//	The hash of the manifest, which is what `Get` takes to reassemble the file.
+func PutErasureCoded(context kademlia.IKademlia, name string, data []byte, ttl time.Duration, dataShards int, totalShards int) (string, error) {
+	rs, err := NewReedSolomon(dataShards, totalShards)
+	if err != nil {
+		return "", err
+	}
+	if totalShards > FILE_MANIFEST_MAX_ENTRIES {
+		return "", fmt.Errorf("at most %d shards per stripe", FILE_MANIFEST_MAX_ENTRIES)
+	}
+
+	stripes := split(data, dataShards*FILE_CHUNK_SIZE)
+	var shards [][]byte
+	for stripeIndex, stripe := range stripes {
+		stripeShards := make([][]byte, totalShards)
+		shardSize := (len(stripe) + dataShards - 1) / dataShards
+		for i := 0; i < dataShards; i++ {
+			// The last shards of the last stripe are padded with zeros
+			stripeShards[i] = make([]byte, shardSize)
+			if i*shardSize < len(stripe) {
+				copy(stripeShards[i], stripe[i*shardSize:])
+			}
+		}
+		if err := rs.Encode(stripeShards); err != nil {
+			return "", err
+		}
+		for i, shard := range stripeShards {
+			shards = append(shards, saltShard(stripeIndex, i, shard))
+		}
+	}
+
+	hashes, err := storeAll(context, shards, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	entries := make([]entry, len(stripes))
+	for i, stripe := range stripes {
+		entries[i] = entry{hashes[i*totalShards : (i+1)*totalShards], int64(len(stripe))}
+	}
+	template := Manifest{
+		Format:      MANIFEST_FORMAT,
+		Name:        name,
+		DataShards:  dataShards,
+		TotalShards: totalShards,
+		ShardSize:   FILE_CHUNK_SIZE,
+	}
+	return putManifest(context, template, entries, ttl)
+}
+
+// Find a file in the network by the hash of its manifest and reassemble it.
+// Every chunk is checked against its hash.
+func Get(context kademlia.IKademlia, hash string) (*File, error) {
+	value, _, _ := context.LookupData(hash)
+	if value == nil {
+		return nil, errors.New("manifest not found")
+	}
+	manifest, ok := DecodeManifest(value)
+	if !ok {
+		return nil, ErrNotManifest
+	}
+
+	data, err := Assemble(context, manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Name: manifest.Name, Data: data}, nil
+}
+
+// Fetch the chunks listed in a manifest and join them.
+//
+// Shards of an erasure coded file that are gone or corrupt are rebuilt from
+// the remaining shards of their stripe, and stored again.
+func Assemble(context kademlia.IKademlia, manifest *Manifest) ([]byte, error) {
+	var data []byte
+	var err error
+
+	switch {
+	case len(manifest.Parts) > 0:
+		data, err = assembleParts(context, manifest)
+	case manifest.TotalShards > 0:
+		data, err = assembleStripes(context, manifest)
+	default:
+		data, err = assembleChunks(context, manifest)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) != manifest.Size {
+		return nil, ErrSizeMismatch
+	}
+	return data, nil
+}
+
+func assembleChunks(context kademlia.IKademlia, manifest *Manifest) ([]byte, error) {
+	chunks, err := fetchAll(context, manifest.Chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	// The size is not trusted to preallocate with, until the data adds up to it
+	data := []byte{}
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return data, nil
+}
+
+func assembleParts(context kademlia.IKademlia, manifest *Manifest) ([]byte, error) {
+	parts, err := fetchAll(context, manifest.Parts)
+	if err != nil {
+		return nil, err
+	}
+
+	data := []byte{}
+	for i, part := range parts {
+		partManifest, ok := DecodeManifest(part)
+		if !ok {
+			return nil, fmt.Errorf("part %s: %w", manifest.Parts[i], ErrNotManifest)
+		}
+		partData, err := Assemble(context, partManifest)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, partData...)
+		if int64(len(data)) > manifest.Size {
+			return nil, ErrSizeMismatch
+		}
+	}
+	return data, nil
+}
+
+func assembleStripes(context kademlia.IKademlia, manifest *Manifest) ([]byte, error) {
+	rs, err := NewReedSolomon(manifest.DataShards, manifest.TotalShards)
+	if err != nil {
+		return nil, err
+	}
+
+	shards := fetchAvailable(context, manifest.Chunks)
+	data := []byte{}
+	for start := 0; start < len(shards); start += manifest.TotalShards {
+		stripe := shards[start : start+manifest.TotalShards]
+		stripeHashes := manifest.Chunks[start : start+manifest.TotalShards]
+
+		// A nested manifest does not know where its stripes are in the file,
+		// so the stripe a shard was salted with is taken from the shards
+		stripeIndex := -1
+		var missing []int
+		for i, shard := range stripe {
+			stripe[i] = unsaltShard(&stripeIndex, i, shard)
+			if stripe[i] == nil {
+				missing = append(missing, i)
+			}
+		}
+		if len(missing) > 0 {
+			if err := rs.Reconstruct(stripe); err != nil {
+				return nil, fmt.Errorf("stripe %d: %w", start/manifest.TotalShards, err)
+			}
+			repairShards(context, stripeIndex, stripe, stripeHashes, missing)
+		}
+
+		stripeSize := int64(manifest.DataShards * manifest.ShardSize)
+		if remaining := manifest.Size - int64(len(data)); remaining < stripeSize {
+			stripeSize = remaining
+		}
+		var stripeData []byte
+		for _, shard := range stripe[:manifest.DataShards] {
+			stripeData = append(stripeData, shard...)
+		}
+		if int64(len(stripeData)) < stripeSize {
+			return nil, ErrSizeMismatch
+		}
+		data = append(data, stripeData[:stripeSize]...)
+	}
+	return data, nil
+}
+
+// Store rebuilt shards again, so the stripe is back at full strength
+func repairShards(context kademlia.IKademlia, stripeIndex int, stripe [][]byte, hashes []string, missing []int) {
+	for _, i := range missing {
+		salted := saltShard(stripeIndex, i, stripe[i])
+		if util.Hash(salted) != hashes[i] {
+			context.GetLogger().Warn("Rebuilt shard does not match its hash", "key", hashes[i])
+			continue
+		}
+		if _, err := context.Store(salted, 0); err != nil {
+			context.GetLogger().Warn("Could not repair shard", "key", hashes[i], "err", err)
+			continue
+		}
+		context.GetLogger().Info("Repaired shard", "key", hashes[i])
+		RepairedShards.Inc()
+	}
+}
+
+// Prefix a shard with its stripe and index, as it is stored
+func saltShard(stripeIndex int, index int, shard []byte) []byte {
+	salted := make([]byte, FILE_SHARD_HEADER_SIZE, FILE_SHARD_HEADER_SIZE+len(shard))
+	binary.BigEndian.PutUint32(salted[0:4], uint32(stripeIndex))
+	binary.BigEndian.PutUint32(salted[4:8], uint32(index))
+	return append(salted, shard...)
+}
+
+// Get a shard from its stored value. Returns nil if the value is nil, or is
+// not prefixed with the given index and the stripe of the other shards. The
+// stripe is set from the first shard, if it is -1.
+func unsaltShard(stripeIndex *int, index int, value []byte) []byte {
+	if len(value) < FILE_SHARD_HEADER_SIZE || binary.BigEndian.Uint32(value[4:8]) != uint32(index) {
+		return nil
+	}
+	stripe := int(binary.BigEndian.Uint32(value[0:4]))
+	if *stripeIndex == -1 {
+		*stripeIndex = stripe
+	} else if stripe != *stripeIndex {
+		return nil
+	}
+	return value[FILE_SHARD_HEADER_SIZE:]
+}
+
+// Group the entries into manifests until they fit in a single one, and store
+// that one. Returns the hash of the top manifest.
+func putManifest(context kademlia.IKademlia, template Manifest, entries []entry, ttl time.Duration) (string, error) {
+	isChunks := true
+	for countHashes(entries) > FILE_MANIFEST_MAX_ENTRIES {
+		// Entries are never split between manifests, to keep stripes whole
+		perManifest := FILE_MANIFEST_MAX_ENTRIES / len(entries[0].hashes)
+
+		var manifests [][]byte
+		var sizes []int64
+		for start := 0; start < len(entries); start += perManifest {
+			end := start + perManifest
+			if end > len(entries) {
+				end = len(entries)
+			}
+			part := template
+			part.Name = ""
+			manifest := fillManifest(part, entries[start:end], isChunks)
+			manifests = append(manifests, EncodeManifest(manifest))
+			sizes = append(sizes, manifest.Size)
+		}
+
+		hashes, err := storeAll(context, manifests, ttl)
+		if err != nil {
+			return "", err
+		}
+		entries = make([]entry, len(manifests))
+		for i := range manifests {
+			entries[i] = entry{[]string{hashes[i]}, sizes[i]}
+		}
+		isChunks = false
+	}
+
+	return context.Store(EncodeManifest(fillManifest(template, entries, isChunks)), ttl)
+}
+
+func fillManifest(manifest Manifest, entries []entry, isChunks bool) *Manifest {
+	var hashes []string
+	for _, entry := range entries {
+		hashes = append(hashes, entry.hashes...)
+		manifest.Size += entry.size
+	}
+	if isChunks {
+		manifest.Chunks = hashes
+	} else {
+		manifest.Parts = hashes
+	}
+	return &manifest
+}
+
+func countHashes(entries []entry) int {
+	count := 0
+	for _, entry := range entries {
+		count += len(entry.hashes)
+	}
+	return count
+}
+
+// Split data into chunks of at most `size` bytes
+func split(data []byte, size int) [][]byte {
+	var chunks [][]byte
+	for start := 0; start < len(data); start += size {
+		end := start + size
+		if end > len(data) {
+			end = len(data)
+		}
+		chunks = append(chunks, data[start:end])
+	}
+	return chunks
+}
+
+// Store values in parallel. Returns their hashes in the same order.
+func storeAll(context kademlia.IKademlia, values [][]byte, ttl time.Duration) ([]string, error) {
+	hashes := make([]string, len(values))
+	err := forEachParallel(len(values), func(i int) error {
+		hash, err := context.Store(values[i], ttl)
+		if err != nil {
+			return err
+		}
+		hashes[i] = hash
+		return nil
+	})
+	return hashes, err
+}
+
+// Fetch values by their hashes in parallel. Returns the values in the same
+// order.
+func fetchAll(context kademlia.IKademlia, hashes []string) ([][]byte, error) {
+	values := make([][]byte, len(hashes))
+	err := forEachParallel(len(hashes), func(i int) error {
+		value, _, _ := context.LookupData(hashes[i])
+		if value == nil {
+			return fmt.Errorf("chunk %s not found", hashes[i])
+		}
+		if util.Hash(value) != hashes[i] {
+			return fmt.Errorf("chunk %s does not match its hash", hashes[i])
+		}
+		values[i] = value
+		return nil
+	})
+	return values, err
+}
+
+// Fetch values by their hashes in parallel. Values that are not found or do
+// not match their hash are nil.
+func fetchAvailable(context kademlia.IKademlia, hashes []string) [][]byte {
+	values := make([][]byte, len(hashes))
+	forEachParallel(len(hashes), func(i int) error {
+		value, _, _ := context.LookupData(hashes[i])
+		if value != nil && util.Hash(value) == hashes[i] {
+			values[i] = value
+		} else {
+			context.GetLogger().Info("Shard is gone", "key", hashes[i])
+		}
+		return nil
+	})
+	return values
+}
+
+// Call fn for 0 to n-1, at most FILE_PARALLEL_LOOKUPS at a time. Returns the
+// first error, if any.
+func forEachParallel(n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	semaphore := make(chan struct{}, FILE_PARALLEL_LOOKUPS)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(i)
+			<-semaphore
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
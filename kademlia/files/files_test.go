@@ -0,0 +1,257 @@
+package files
+
+import (
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/network/routing"
+	"d7024e/util"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A node that keeps stored values in memory instead of in a network
+type fakeKademlia struct {
+	mocks.KademliaMockObject
+	lock   sync.Mutex
+	values map[string][]byte
+}
+
+func newFakeKademlia() *fakeKademlia {
+	return &fakeKademlia{values: make(map[string][]byte)}
+}
+
+func (k *fakeKademlia) Store(data []byte, ttl time.Duration) (string, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	hash := util.Hash(data)
+	k.values[hash] = data
+	return hash, nil
+}
+
+func (k *fakeKademlia) LookupData(hash string) ([]byte, *routing.Contact, time.Duration) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	return k.values[hash], nil, 0
+}
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func TestPut_ShouldStoreChunksAndManifest(t *testing.T) {
+	context := newFakeKademlia()
+	data := randomData(3*FILE_CHUNK_SIZE + 10)
+
+	hash, err := Put(context, "file.bin", data, 0)
+	manifest, ok := DecodeManifest(context.values[hash])
+
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "file.bin", manifest.Name)
+	assert.Equal(t, int64(len(data)), manifest.Size)
+	assert.Equal(t, 4, len(manifest.Chunks))
+	assert.Equal(t, data[:FILE_CHUNK_SIZE], context.values[manifest.Chunks[0]])
+	assert.Equal(t, data[3*FILE_CHUNK_SIZE:], context.values[manifest.Chunks[3]])
+}
+
+func TestGet_ShouldReassembleFile(t *testing.T) {
+	var tests = []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"single chunk", 100},
+		{"exact chunks", 2 * FILE_CHUNK_SIZE},
+		{"nested manifests", (FILE_MANIFEST_MAX_ENTRIES + 10) * FILE_CHUNK_SIZE},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			context := newFakeKademlia()
+			data := randomData(test.size)
+
+			hash, _ := Put(context, "file.bin", data, 0)
+			actual, err := Get(context, hash)
+
+			assert.Nil(t, err)
+			assert.Equal(t, "file.bin", actual.Name)
+			assert.Equal(t, len(data), len(actual.Data))
+			assert.Equal(t, data, actual.Data)
+		})
+	}
+}
+
+func TestGet_WithNestedManifests_ShouldListParts(t *testing.T) {
+	context := newFakeKademlia()
+	data := randomData((FILE_MANIFEST_MAX_ENTRIES + 1) * FILE_CHUNK_SIZE)
+
+	hash, _ := Put(context, "file.bin", data, 0)
+	manifest, _ := DecodeManifest(context.values[hash])
+
+	assert.Empty(t, manifest.Chunks)
+	assert.Equal(t, 2, len(manifest.Parts))
+}
+
+func TestGet_WithCorruptChunk_ShouldReturnError(t *testing.T) {
+	context := newFakeKademlia()
+	hash, _ := Put(context, "file.bin", randomData(2*FILE_CHUNK_SIZE), 0)
+	manifest, _ := DecodeManifest(context.values[hash])
+	context.values[manifest.Chunks[1]] = []byte("corrupt")
+
+	_, err := Get(context, hash)
+
+	assert.NotNil(t, err)
+}
+
+func TestGet_WithMissingChunk_ShouldReturnError(t *testing.T) {
+	context := newFakeKademlia()
+	hash, _ := Put(context, "file.bin", randomData(2*FILE_CHUNK_SIZE), 0)
+	manifest, _ := DecodeManifest(context.values[hash])
+	delete(context.values, manifest.Chunks[0])
+
+	_, err := Get(context, hash)
+
+	assert.NotNil(t, err)
+}
+
+func TestGet_WithValueThatIsNotManifest_ShouldReturnError(t *testing.T) {
+	context := newFakeKademlia()
+	hash, _ := context.Store([]byte("plain text"), 0)
+
+	_, err := Get(context, hash)
+
+	assert.ErrorIs(t, err, ErrNotManifest)
+}
+
+func TestDecodeManifest(t *testing.T) {
+	var tests = []struct {
+		name     string
+		value    string
+		expected bool
+	}{
+		{"manifest", `{"Format":"kademlia-manifest/1","Size":0}`, true},
+		{"other format", `{"Format":"other","Size":0}`, false},
+		{"chunks and parts", `{"Format":"kademlia-manifest/1","Chunks":["a"],"Parts":["b"]}`, false},
+		{"chunks", `{"Format":"kademlia-manifest/1","Size":4096,"Chunks":["a"]}`, true},
+		{"negative size", `{"Format":"kademlia-manifest/1","Size":-1,"Chunks":["a"]}`, false},
+		{"size larger than chunks", `{"Format":"kademlia-manifest/1","Size":4097,"Chunks":["a"]}`, false},
+		{"stripes", `{"Format":"kademlia-manifest/1","Size":8192,"Chunks":["a","b","c"],"DataShards":2,"TotalShards":3,"ShardSize":4096}`, true},
+		{"size larger than stripes", `{"Format":"kademlia-manifest/1","Size":8193,"Chunks":["a","b","c"],"DataShards":2,"TotalShards":3,"ShardSize":4096}`, false},
+		{"more data shards than shards", `{"Format":"kademlia-manifest/1","Size":0,"Chunks":["a","b","c"],"DataShards":3,"TotalShards":3,"ShardSize":4096}`, false},
+		{"parts", `{"Format":"kademlia-manifest/1","Size":1000000,"Parts":["a"]}`, true},
+		{"text", "plain text", false},
+		{"empty", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, actual := DecodeManifest([]byte(test.value))
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestPutErasureCoded_ShouldStoreShardsPerStripe(t *testing.T) {
+	context := newFakeKademlia()
+	data := randomData(3*FILE_CHUNK_SIZE + 10)
+
+	hash, err := PutErasureCoded(context, "file.bin", data, 0, 3, 5)
+	manifest, ok := DecodeManifest(context.values[hash])
+
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 3, manifest.DataShards)
+	assert.Equal(t, 5, manifest.TotalShards)
+	assert.Equal(t, int64(len(data)), manifest.Size)
+	assert.Equal(t, 2*5, len(manifest.Chunks))
+	assert.Equal(t, saltShard(0, 0, data[:FILE_CHUNK_SIZE]), context.values[manifest.Chunks[0]])
+}
+
+func TestPutErasureCoded_WithIdenticalShards_ShouldStoreEachUnderOwnKey(t *testing.T) {
+	context := newFakeKademlia()
+	data := make([]byte, 2*3*FILE_CHUNK_SIZE)
+
+	hash, _ := PutErasureCoded(context, "zeros.bin", data, 0, 3, 5)
+	manifest, _ := DecodeManifest(context.values[hash])
+	keys := map[string]bool{}
+	for _, shardHash := range manifest.Chunks {
+		keys[shardHash] = true
+	}
+	actual, err := Get(context, hash)
+
+	assert.Equal(t, len(manifest.Chunks), len(keys))
+	assert.Nil(t, err)
+	assert.Equal(t, data, actual.Data)
+}
+
+func TestGet_WithErasureCodedFile_ShouldReassembleFile(t *testing.T) {
+	var tests = []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"partial stripe", 100},
+		{"exact stripes", 2 * 3 * FILE_CHUNK_SIZE},
+		{"nested manifests", (FILE_MANIFEST_MAX_ENTRIES/5 + 2) * 3 * FILE_CHUNK_SIZE},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			context := newFakeKademlia()
+			data := randomData(test.size)
+
+			hash, _ := PutErasureCoded(context, "file.bin", data, 0, 3, 5)
+			actual, err := Get(context, hash)
+
+			assert.Nil(t, err)
+			assert.Equal(t, len(data), len(actual.Data))
+			assert.Equal(t, data, actual.Data)
+		})
+	}
+}
+
+func TestGet_WithMissingShards_ShouldRebuildAndRepairShards(t *testing.T) {
+	context := newFakeKademlia()
+	data := randomData(2*3*FILE_CHUNK_SIZE + 10)
+	hash, _ := PutErasureCoded(context, "file.bin", data, 0, 3, 5)
+	manifest, _ := DecodeManifest(context.values[hash])
+	expectedShards := map[string][]byte{}
+	for _, shardHash := range manifest.Chunks {
+		expectedShards[shardHash] = context.values[shardHash]
+	}
+	repairedBefore := RepairedShards.Value()
+
+	// Lose two shards of each stripe, and corrupt one
+	delete(context.values, manifest.Chunks[0])
+	delete(context.values, manifest.Chunks[4])
+	delete(context.values, manifest.Chunks[6])
+	context.values[manifest.Chunks[7]] = []byte("corrupt")
+	actual, err := Get(context, hash)
+
+	assert.Nil(t, err)
+	assert.Equal(t, data, actual.Data)
+	assert.Equal(t, repairedBefore+4, RepairedShards.Value())
+	for _, shardHash := range manifest.Chunks {
+		assert.Equal(t, expectedShards[shardHash], context.values[shardHash])
+	}
+}
+
+func TestGet_WithTooFewShards_ShouldReturnError(t *testing.T) {
+	context := newFakeKademlia()
+	hash, _ := PutErasureCoded(context, "file.bin", randomData(100), 0, 3, 5)
+	manifest, _ := DecodeManifest(context.values[hash])
+	delete(context.values, manifest.Chunks[0])
+	delete(context.values, manifest.Chunks[1])
+	delete(context.values, manifest.Chunks[3])
+
+	_, err := Get(context, hash)
+
+	assert.ErrorIs(t, err, ErrTooFewShards)
+}
@@ -0,0 +1,78 @@
+package files
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Marks a stored value as a manifest
+const MANIFEST_FORMAT = "kademlia-manifest/1"
+
+// A manifest describes a file that is stored as separate chunks. The chunks
+// are listed in order by their hashes. Files with more chunks than fit in one
+// manifest list the hashes of other manifests in Parts instead, each of which
+// covers a consecutive range of the file.
+type Manifest struct {
+	Format string
+	// Name of the file. Only set on the top manifest of a file.
+	Name string `json:",omitempty"`
+	// Size of the range of the file the manifest covers, in bytes
+	Size   int64
+	Chunks []string `json:",omitempty"`
+	Parts  []string `json:",omitempty"`
+
+	// Set if the file is erasure coded. Chunks then lists the shards of each
+	// stripe in turn, TotalShards at a time, and any DataShards of the shards
+	// of a stripe are enough to rebuild it. ShardSize is the size of the
+	// shards of every stripe but the last.
+	DataShards  int `json:",omitempty"`
+	TotalShards int `json:",omitempty"`
+	ShardSize   int `json:",omitempty"`
+}
+
+// Serialize a manifest to store it
+func EncodeManifest(manifest *Manifest) []byte {
+	data, _ := json.Marshal(manifest)
+	return data
+}
+
+// Deserialize a stored value into a manifest.
+//
+// Returns the manifest and true if the value is a manifest. Otherwise, nil
+// and false.
+func DecodeManifest(data []byte) (*Manifest, bool) {
+	if len(data) == 0 || data[0] != '{' {
+		return nil, false
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Format != MANIFEST_FORMAT {
+		return nil, false
+	}
+	if len(manifest.Chunks) > 0 && len(manifest.Parts) > 0 {
+		return nil, false
+	}
+	if manifest.TotalShards > 0 && (manifest.DataShards < 1 || manifest.DataShards >= manifest.TotalShards ||
+		manifest.ShardSize <= 0 || manifest.ShardSize > FILE_CHUNK_SIZE || len(manifest.Chunks)%manifest.TotalShards != 0) {
+		return nil, false
+	}
+	if manifest.Size < 0 || manifest.Size > manifest.maxSize() {
+		return nil, false
+	}
+	return &manifest, true
+}
+
+// Get the largest size the chunks listed in a manifest can add up to, or the
+// largest size there is if it lists parts, whose size is only known once they
+// are fetched.
+func (manifest *Manifest) maxSize() int64 {
+	switch {
+	case len(manifest.Parts) > 0:
+		return math.MaxInt64
+	case manifest.TotalShards > 0:
+		stripes := int64(len(manifest.Chunks) / manifest.TotalShards)
+		return stripes * int64(manifest.DataShards) * int64(manifest.ShardSize)
+	default:
+		return int64(len(manifest.Chunks)) * FILE_CHUNK_SIZE
+	}
+}
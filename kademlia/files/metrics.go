@@ -0,0 +1,10 @@
+package files
+
+import "d7024e/metrics"
+
+var (
+	// Shards of erasure coded files that were found gone and stored again
+	RepairedShards = metrics.NewCounter(
+		"kademlia_file_shards_repaired_total",
+		"Shards of erasure coded files that were rebuilt and stored again.")
+)
@@ -0,0 +1,242 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reed-Solomon erasure coding over GF(2^8). A stripe of m data shards is
+// extended with n-m parity shards, and the data can be rebuilt from any m of
+// the n shards.
+//
+// The code is systematic: the first m shards are the data itself. Parity
+// shards are computed with an encoding matrix derived from a Vandermonde
+// matrix, so that any m of its rows form an invertible matrix.
+
+var ErrTooFewShards = errors.New("too few shards to reconstruct data")
+
+// Field tables for GF(2^8) with the polynomial x^8 + x^4 + x^3 + x^2 + 1
+var (
+	gfExp [512]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+func gfPow(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])*n)%255]
+}
+
+type ReedSolomon struct {
+	dataShards  int
+	totalShards int
+	// totalShards x dataShards encoding matrix, with the identity on top
+	matrix [][]byte
+}
+
+// Create an erasure code that extends `dataShards` data shards to
+// `totalShards` shards.
+func NewReedSolomon(dataShards, totalShards int) (*ReedSolomon, error) {
+	if dataShards < 1 || totalShards <= dataShards || totalShards > 256 {
+		return nil, fmt.Errorf("invalid erasure code %d/%d, need 0 < data shards < total shards <= 256", dataShards, totalShards)
+	}
+
+	vandermonde := make([][]byte, totalShards)
+	for row := range vandermonde {
+		vandermonde[row] = make([]byte, dataShards)
+		for col := range vandermonde[row] {
+			vandermonde[row][col] = gfPow(byte(row), col)
+		}
+	}
+
+	top, _ := invertMatrix(vandermonde[:dataShards])
+	return &ReedSolomon{
+		dataShards:  dataShards,
+		totalShards: totalShards,
+		matrix:      multiplyMatrix(vandermonde, top),
+	}, nil
+}
+
+// Compute the parity shards of a stripe.
+//
+// Parameters:
+//
+//	`shards` - totalShards shards, of which the first dataShards hold the
+//	data. All data shards must have the same length. The parity shards are
+//	overwritten.
+func (rs *ReedSolomon) Encode(shards [][]byte) error {
+	if len(shards) != rs.totalShards {
+		return fmt.Errorf("expected %d shards, got %d", rs.totalShards, len(shards))
+	}
+	size := len(shards[0])
+	for _, shard := range shards[:rs.dataShards] {
+		if len(shard) != size {
+			return errors.New("data shards differ in length")
+		}
+	}
+
+	for row := rs.dataShards; row < rs.totalShards; row++ {
+		shards[row] = rs.combine(rs.matrix[row], shards[:rs.dataShards], size)
+	}
+	return nil
+}
+
+// Rebuild the missing shards of a stripe.
+//
+// Parameters:
+//
+//	`shards` - totalShards shards, where missing shards are nil. All present
+//	shards must have the same length. Missing shards are filled in.
+//
+// Returns ErrTooFewShards if fewer than dataShards shards are present.
+func (rs *ReedSolomon) Reconstruct(shards [][]byte) error {
+	if len(shards) != rs.totalShards {
+		return fmt.Errorf("expected %d shards, got %d", rs.totalShards, len(shards))
+	}
+
+	var rows [][]byte
+	var present [][]byte
+	size := -1
+	for i, shard := range shards {
+		if shard == nil || len(present) == rs.dataShards {
+			continue
+		}
+		if size >= 0 && len(shard) != size {
+			return errors.New("shards differ in length")
+		}
+		size = len(shard)
+		rows = append(rows, rs.matrix[i])
+		present = append(present, shard)
+	}
+	if len(present) < rs.dataShards {
+		return ErrTooFewShards
+	}
+
+	// The present shards are the data multiplied by their rows of the
+	// encoding matrix, so the inverse of those rows gives back the data
+	decode, err := invertMatrix(rows)
+	if err != nil {
+		return err
+	}
+	data := make([][]byte, rs.dataShards)
+	for i := range data {
+		if shards[i] != nil {
+			data[i] = shards[i]
+		} else {
+			data[i] = rs.combine(decode[i], present, size)
+		}
+	}
+
+	for i := range shards {
+		if shards[i] != nil {
+			continue
+		}
+		if i < rs.dataShards {
+			shards[i] = data[i]
+		} else {
+			shards[i] = rs.combine(rs.matrix[i], data, size)
+		}
+	}
+	return nil
+}
+
+// Compute the sum of the shards multiplied by the coefficients
+func (rs *ReedSolomon) combine(coefficients []byte, shards [][]byte, size int) []byte {
+	out := make([]byte, size)
+	for i, coefficient := range coefficients {
+		if coefficient == 0 {
+			continue
+		}
+		for k, b := range shards[i] {
+			out[k] ^= gfMul(coefficient, b)
+		}
+	}
+	return out
+}
+
+func multiplyMatrix(a, b [][]byte) [][]byte {
+	result := make([][]byte, len(a))
+	for row := range a {
+		result[row] = make([]byte, len(b[0]))
+		for col := range b[0] {
+			var sum byte
+			for k := range b {
+				sum ^= gfMul(a[row][k], b[k][col])
+			}
+			result[row][col] = sum
+		}
+	}
+	return result
+}
+
+// Invert a square matrix with Gauss-Jordan elimination
+func invertMatrix(matrix [][]byte) ([][]byte, error) {
+	n := len(matrix)
+	work := make([][]byte, n)
+	for row := range work {
+		work[row] = make([]byte, 2*n)
+		copy(work[row], matrix[row])
+		work[row][n+row] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for pivot < n && work[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == n {
+			return nil, errors.New("matrix is singular")
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		scale := gfInv(work[col][col])
+		for k := range work[col] {
+			work[col][k] = gfMul(work[col][k], scale)
+		}
+		for row := 0; row < n; row++ {
+			if row == col || work[row][col] == 0 {
+				continue
+			}
+			factor := work[row][col]
+			for k := range work[row] {
+				work[row][k] ^= gfMul(factor, work[col][k])
+			}
+		}
+	}
+
+	inverse := make([][]byte, n)
+	for row := range inverse {
+		inverse[row] = work[row][n:]
+	}
+	return inverse, nil
+}
@@ -0,0 +1,88 @@
+package files
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodedStripe(t *testing.T, rs *ReedSolomon, size int) [][]byte {
+	random := rand.New(rand.NewSource(int64(size)))
+	shards := make([][]byte, rs.totalShards)
+	for i := 0; i < rs.dataShards; i++ {
+		shards[i] = make([]byte, size)
+		random.Read(shards[i])
+	}
+	if err := rs.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	return shards
+}
+
+func TestReedSolomon_Encode_ShouldKeepDataShards(t *testing.T) {
+	rs, _ := NewReedSolomon(3, 5)
+	data := [][]byte{{1, 2}, {3, 4}, {5, 6}, nil, nil}
+
+	err := rs.Encode(data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{{1, 2}, {3, 4}, {5, 6}}, data[:3])
+	assert.Equal(t, 2, len(data[3]))
+	assert.Equal(t, 2, len(data[4]))
+}
+
+func TestReedSolomon_Reconstruct_FromAnyDataShards(t *testing.T) {
+	rs, _ := NewReedSolomon(3, 6)
+	expected := encodedStripe(t, rs, 64)
+
+	// Try every combination of missing shards
+	for missing := 0; missing < 1<<rs.totalShards; missing++ {
+		shards := make([][]byte, rs.totalShards)
+		present := 0
+		for i := range shards {
+			if missing&(1<<i) == 0 {
+				shards[i] = append([]byte(nil), expected[i]...)
+				present++
+			}
+		}
+
+		err := rs.Reconstruct(shards)
+
+		if present < rs.dataShards {
+			assert.ErrorIs(t, err, ErrTooFewShards)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, expected, shards)
+		}
+	}
+}
+
+func TestReedSolomon_Reconstruct_WithManyShards(t *testing.T) {
+	rs, _ := NewReedSolomon(10, 14)
+	expected := encodedStripe(t, rs, 1000)
+	shards := append([][]byte(nil), expected...)
+	shards[0], shards[5], shards[9], shards[12] = nil, nil, nil, nil
+
+	err := rs.Reconstruct(shards)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, shards)
+}
+
+func TestNewReedSolomon_WithInvalidParameters_ShouldReturnError(t *testing.T) {
+	var tests = []struct {
+		dataShards  int
+		totalShards int
+	}{
+		{0, 2},
+		{3, 3},
+		{4, 3},
+		{10, 257},
+	}
+
+	for _, test := range tests {
+		_, err := NewReedSolomon(test.dataShards, test.totalShards)
+		assert.NotNil(t, err)
+	}
+}
@@ -1,13 +1,18 @@
 package kademlia
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"d7024e/config"
 	"d7024e/kademlia/datastore"
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
 	"d7024e/kademlia/network/rpc"
+	"d7024e/kademlia/record"
+	"d7024e/logging"
 	"d7024e/util"
 	"errors"
-	"log"
 	"math"
 	"math/rand"
 	"sync"
@@ -25,57 +30,140 @@ type IKademlia interface {
 	GetNetwork() network.INetwork
 	GetDataStore() datastore.IDataStore
 
+	// Get the logger of this node, which adds the ID of the node to every
+	// message.
+	GetLogger() *logging.Logger
+
 	LookupContact(targetID *routing.KademliaID) []routing.Contact
-	LookupData(hash string) ([]byte, *routing.Contact)
-	Store(data []byte) (string, error)
-	ForgetData(hash string, contacts []routing.Contact) error
+	LookupData(hash string) ([]byte, *routing.Contact, time.Duration)
+	Store(data []byte, ttl time.Duration) (string, error)
+	Republish(hash string, data []byte, ttl time.Duration, publisher ed25519.PublicKey) (int, error)
+	ForgetData(hash string, contacts []routing.Contact) ([]rpc.ForgetResponse, error)
+	PublishRecord(rec *record.Record, ttl time.Duration) error
+	LookupRecord(key string) *record.Record
+	AnnounceProvider(key string, ttl time.Duration) error
+	FindProviders(key string) []routing.Contact
+	Subscribe(topic string) (*network.Subscription, error)
+	Unsubscribe(sub *network.Subscription) error
+	Publish(topic string, data []byte) error
 	JoinNetwork(contact *routing.Contact, retries int) bool
+
+	// Lookup the closest contacts of `targetID`, and record every find node
+	// message the lookup sent.
+	TraceLookup(targetID *routing.KademliaID) rpc.LookupTrace
+
+	// Get the status of the node: who it is, how long it has run, whether it
+	// joined the network, what it knows and stores, and its traffic.
+	Status() network.NodeStatus
 }
 
 type Kademlia struct {
 	me        *routing.Contact
 	network   network.INetwork
 	dataStore datastore.IDataStore
-}
 
-// Hyperparameters
-const K int = 20 //k closest
-const A int = 3  //alpha, 1 is effectively no concurrency
+	// Identity of the node as the publisher of the data it stores
+	key ed25519.PrivateKey
+
+	started time.Time
+	// One of the JOIN_STATE constants, unset until the node joins
+	joinState atomic.Value
+
+	logger *logging.Logger
+
+	// Number of closest nodes a lookup returns
+	k int
+	// Number of requests a lookup sends at once, 1 is effectively no
+	// concurrency
+	alpha int
+	// Default TTL of the nodes, that dataobjects stored without one are kept
+	// for
+	ttl time.Duration
 
+	// Dataobjects this node published, to be republished once started
+	published    *publishedObjects
+	republishing sync.Once
+}
+
+// Create a new Kademlia node. The node publishes data under a new random
+// identity, unless another one is set with SetIdentity.
 func NewKademlia(me *routing.Contact, network network.INetwork, datastore datastore.IDataStore) *Kademlia {
-	return &Kademlia{me, network, datastore}
+	_, key, err := ed25519.GenerateKey(cryptorand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	kademlia := &Kademlia{me: me, network: network, dataStore: datastore, key: key, started: time.Now(), published: newPublishedObjects()}
+	kademlia.SetLogger(logging.Default())
+	kademlia.Configure(config.Default())
+	return kademlia
+}
+
+// Set the parameters of lookups, and the default TTL, from the config
+func (kademlia *Kademlia) Configure(config config.Config) {
+	kademlia.k = config.K
+	kademlia.alpha = config.Alpha
+	kademlia.ttl = config.TTL
+}
+
+// Set the logger of the node. The ID of the node is added to every message.
+func (kademlia *Kademlia) SetLogger(logger *logging.Logger) {
+	kademlia.logger = logger.With("node", kademlia.me.ID.String())
+}
+
+// Set the key the node publishes data with. Only the same key can make other
+// nodes forget the data later, so it should be kept across restarts.
+func (kademlia *Kademlia) SetIdentity(key ed25519.PrivateKey) {
+	kademlia.key = key
+}
+
+// Get the public key the node publishes data with
+func (kademlia *Kademlia) GetPublicKey() ed25519.PublicKey {
+	return kademlia.key.Public().(ed25519.PublicKey)
 }
 
 // Getters
 func (kademlia *Kademlia) GetMe() *routing.Contact            { return kademlia.me }
 func (kademlia *Kademlia) GetNetwork() network.INetwork       { return kademlia.network }
 func (kademlia *Kademlia) GetDataStore() datastore.IDataStore { return kademlia.dataStore }
+func (kademlia *Kademlia) GetLogger() *logging.Logger         { return kademlia.logger }
 
 // Lookup contacts
 func (kademlia *Kademlia) LookupContact(targetID *routing.KademliaID) []routing.Contact {
-	candidateList := NewCandidateList(targetID, K)
-	kClosestContacts := kademlia.network.GetRoutingTable().FindClosestContacts(targetID, K)
+	started := time.Now()
+	contacts, hops := kademlia.lookupContact(targetID, nil)
+	observeLookup(LOOKUP_KIND_NODE, hops, started, len(contacts) > 0)
+	return contacts
+}
+
+// Lookup contacts, and count the hops the lookup took. Every find node
+// message sent is recorded in `tracer`, unless it is nil.
+func (kademlia *Kademlia) lookupContact(targetID *routing.KademliaID, tracer *lookupTracer) ([]routing.Contact, int) {
+	candidateList := NewCandidateList(targetID, kademlia.k)
+	kClosestContacts := kademlia.network.GetRoutingTable().FindClosestContacts(targetID, kademlia.k)
 
 	candidateList.AddMultiple(kClosestContacts)
-	kademlia.lookupContactAux(targetID, kClosestContacts, candidateList)
+	hops := kademlia.lookupContactAux(targetID, kClosestContacts, candidateList, tracer, TRACE_NO_PARENT)
 
 	contacts := make([]routing.Contact, candidateList.Len())
 	for i, candidate := range candidateList.GetAll() {
 		contacts[i] = candidate.Contact
 	}
 
-	return contacts
+	return contacts, hops
 }
 
-func (kademlia *Kademlia) lookupContactAux(targetID *routing.KademliaID, contacts []routing.Contact, cl *CandidateList) {
+// Returns the number of hops of the longest path of nodes that were asked.
+// `parent` is the traced step that returned `contacts`.
+func (kademlia *Kademlia) lookupContactAux(targetID *routing.KademliaID, contacts []routing.Contact, cl *CandidateList, tracer *lookupTracer, parent int) (hops int) {
 	var wg sync.WaitGroup
+	pathHops := make([]int, len(contacts))
 
 	for i, contact := range contacts {
-		if i > A {
+		if i >= kademlia.alpha {
 			break
 		}
 		wg.Add(1)
-		go func(contact routing.Contact, targetId *routing.KademliaID, cl *CandidateList, wg *sync.WaitGroup) {
+		go func(i int, contact routing.Contact, targetId *routing.KademliaID, cl *CandidateList, wg *sync.WaitGroup) {
 			defer wg.Done()
 
 			candidate := cl.Get(contact.ID)
@@ -84,10 +172,11 @@ func (kademlia *Kademlia) lookupContactAux(targetID *routing.KademliaID, contact
 				return
 			}
 
-			channel := make(chan []routing.Contact, 1)
-			go rpc.SendFindContactMessage(kademlia.network, &contact, targetID, channel)
-			contacts := <-channel
+			sent := time.Now()
+			contacts, timeout := rpc.FindContact(kademlia.network, &contact, targetID)
 			cl.Check(contact.ID)
+			pathHops[i] = 1
+			step := tracer.record(parent, contact, targetId, time.Since(sent), timeout, contacts)
 
 			if len(contacts) == 0 {
 				// No contacts recieved
@@ -99,20 +188,31 @@ func (kademlia *Kademlia) lookupContactAux(targetID *routing.KademliaID, contact
 			}
 
 			cl.AddMultiple(contacts)
-			kademlia.lookupContactAux(targetId, contacts, cl)
-		}(contact, targetID, cl, &wg)
+			pathHops[i] += kademlia.lookupContactAux(targetId, contacts, cl, tracer, step)
+		}(i, contact, targetID, cl, &wg)
 	}
 
 	wg.Wait()
+	for _, path := range pathHops {
+		if path > hops {
+			hops = path
+		}
+	}
+	return hops
 }
 
 // send lookup message to closest nodes
-func (kademlia *Kademlia) LookupData(hash string) ([]byte, *routing.Contact) {
+//
+// Returns the value, the contact it was found on and the time left until it
+// expires there. If the value is not found, all are nil or zero.
+func (kademlia *Kademlia) LookupData(hash string) (value []byte, holder *routing.Contact, ttl time.Duration) {
+	started := time.Now()
 	kademliaIdFromHash := routing.NewKademliaID(hash)
 	if kademliaIdFromHash == nil {
-		return nil, nil
+		return nil, nil, 0
 	}
-	contacts := kademlia.LookupContact(kademliaIdFromHash)
+	contacts, hops := kademlia.lookupContact(kademliaIdFromHash, nil)
+	defer func() { observeLookup(LOOKUP_KIND_VALUE, hops, started, value != nil) }()
 	noResponseArray := []*routing.Contact{}
 
 	for _, contact := range contacts {
@@ -120,27 +220,34 @@ func (kademlia *Kademlia) LookupData(hash string) ([]byte, *routing.Contact) {
 	}
 
 	for _, contact := range contacts {
-		valuechannel := make(chan string, 1)
+		valuechannel := make(chan rpc.LookupResponse, 1)
 		go rpc.SendLookupMessage(kademlia.network, &contact, hash, valuechannel) //send FindLocally to each
-		value := <-valuechannel
-		if value == "" {
+		response := <-valuechannel
+		if response.Value == nil {
 			noResponseArray = append(noResponseArray, &contact)
+		} else if util.Hash(response.Value) != hash {
+			// Never trust a value that does not match the key, try the next holder
+			kademlia.logger.Warn("Possible poisoning attempt: value does not match hash", "rpc", "FIND_VALUE", "peer", contact.String(), "key", hash)
+			LookupPoisoningAttempts.Inc()
 		} else {
 			if len(noResponseArray) > 0 {
 				lastContact := noResponseArray[len(noResponseArray)-1]
-				log.Printf("Storing at last empty contact with ID:  %v \n", lastContact.ID)
-				go rpc.SendStoreMessage(kademlia.network, lastContact, hash, []byte(value))
+				kademlia.logger.Debug("Caching value at closest node without it", "peer", lastContact.String(), "key", hash)
+				go rpc.SendCacheMessage(kademlia.network, lastContact, hash, response.Value, response.TTL)
 			}
 
-			return []byte(value), &contact
+			return response.Value, &contact, response.TTL
 		}
 	}
 
-	return nil, nil
+	return nil, nil, 0
 }
 
 // send store message to closest nodes
-func (kademlia *Kademlia) Store(data []byte) (string, error) {
+//
+// The data is kept for `ttl` on each node, or the default TTL of the node if
+// zero.
+func (kademlia *Kademlia) Store(data []byte, ttl time.Duration) (string, error) {
 	hashed := util.Hash(data)
 	stringToByte := []byte(hashed)
 
@@ -151,46 +258,255 @@ func (kademlia *Kademlia) Store(data []byte) (string, error) {
 		return "", err
 	} else {
 		for _, contact := range contacts { // for each of the <=5 contacts found...
-			log.Printf("Storing message with hash %s at node %s\n", hashed, contact.String())
+			kademlia.logger.Debug("Storing value", "rpc", "STORE", "peer", contact.String(), "key", hashed)
 			// TODO: Make this concurrent
-			ok := rpc.SendStoreMessage(kademlia.network, &contact, hashed, data) //send StoreLocally to each
-			if !ok {
-				log.Println("Could not store message at node " + contact.String())
+			err := rpc.SendStoreMessage(kademlia.network, &contact, hashed, data, ttl, kademlia.key) //send StoreLocally to each
+			if err != nil {
+				kademlia.logger.Info("Could not store value", "rpc", "STORE", "peer", contact.String(), "key", hashed, "err", err)
 			}
 		}
 	}
+	kademlia.rememberPublished(hashed, data, ttl)
 	return hashed, nil
 
 }
 
+// Store data again at the current closest nodes of its hash, on behalf of its
+// original publisher, e.g. after it was restored from a snapshot
+//
+// The data is kept for `ttl` on each node. Only this node can sign for itself,
+// so the data is stored as a cached copy, without a publisher, unless
+// `publisher` is this node, in which case it is republished with the rest of
+// what this node published. Returns the number of nodes that stored it.
+func (kademlia *Kademlia) Republish(hash string, data []byte, ttl time.Duration, publisher ed25519.PublicKey) (int, error) {
+	kademliaIdFromHash := routing.NewKademliaID(hash)
+	if kademliaIdFromHash == nil {
+		return 0, errors.New("invalid hash")
+	}
+	contacts := kademlia.LookupContact(kademliaIdFromHash)
+	if len(contacts) == 0 {
+		return 0, errors.New("no suitable contacts found for storage")
+	}
+
+	var key ed25519.PrivateKey
+	if bytes.Equal(publisher, kademlia.GetPublicKey()) {
+		key = kademlia.key
+		kademlia.rememberPublished(hash, data, ttl)
+	}
+
+	var stored int32
+	var wg sync.WaitGroup
+	for i := range contacts {
+		wg.Add(1)
+		go func(contact *routing.Contact) {
+			defer wg.Done()
+			var err error
+			if key != nil {
+				err = rpc.SendStoreMessage(kademlia.network, contact, hash, data, ttl, key)
+			} else {
+				err = rpc.SendCacheMessage(kademlia.network, contact, hash, data, ttl)
+			}
+			if err != nil {
+				kademlia.logger.Info("Could not republish value", "rpc", "STORE", "peer", contact.String(), "key", hash, "err", err)
+			} else {
+				atomic.AddInt32(&stored, 1)
+			}
+		}(&contacts[i])
+	}
+	wg.Wait()
+	return int(stored), nil
+}
+
 // Send forget message to specified contacts
-func (kademlia *Kademlia) ForgetData(hash string, contacts []routing.Contact) error {
+//
+// The message is signed with the identity of the node, so only data the node
+// published is forgotten. Each contact also keeps a tombstone that stops the
+// data from being stored there again, whether or not it held the data, so
+// `contacts` should be the current k closest nodes of the hash. The node stops
+// republishing the data.
+//
+// Returns the outcome on each contact, in the order of `contacts`.
+func (kademlia *Kademlia) ForgetData(hash string, contacts []routing.Contact) ([]rpc.ForgetResponse, error) {
 	kademliaIdFromHash := routing.NewKademliaID(hash)
 	if kademliaIdFromHash == nil {
-		return errors.New("invalid hash")
+		return nil, errors.New("invalid hash")
 	}
 
+	kademlia.published.remove(hash)
+
+	results := make([]rpc.ForgetResponse, len(contacts))
+	var wg sync.WaitGroup
+	for i, contact := range contacts {
+		wg.Add(1)
+		go func(i int, contact routing.Contact) {
+			defer wg.Done()
+			removed, err := rpc.SendForgetDataMessage(kademlia.network, &contact, hash, kademlia.key)
+			results[i] = rpc.ForgetResponse{Contact: contact, Removed: removed, Err: err}
+		}(i, contact)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// Send a signed record to the closest nodes of its key
+//
+// The record is kept for `ttl` on each node, or the default TTL of the node
+// if zero. Returns an error if no node accepted the record, e.g. because they
+// already hold a newer version.
+func (kademlia *Kademlia) PublishRecord(rec *record.Record, ttl time.Duration) error {
+	if err := rec.Verify(); err != nil {
+		return err
+	}
+
+	contacts := kademlia.LookupContact(routing.NewKademliaID(rec.Key()))
+	if len(contacts) == 0 {
+		return errors.New("no suitable contacts found for storage")
+	}
+
+	var lastErr error
+	accepted := 0
 	for _, contact := range contacts {
-		rpc.SendForgetDataMessage(kademlia.network, &contact, hash)
+		err := rpc.SendStoreRecordMessage(kademlia.network, &contact, rec, ttl)
+		if err != nil {
+			kademlia.logger.Info("Could not store record", "rpc", "STORE_RECORD", "peer", contact.String(), "key", rec.Key(), "err", err)
+			lastErr = err
+		} else {
+			accepted++
+		}
 	}
 
+	if accepted == 0 {
+		return lastErr
+	}
 	return nil
 }
 
+// Find the newest validly signed version of the record stored under `key`
+//
+// All of the closest nodes of the key are asked, and the newest version any
+// of them holds is returned, or nil if none holds a valid record. Nodes that
+// hold an older version are sent the newest one.
+func (kademlia *Kademlia) LookupRecord(key string) *record.Record {
+	started := time.Now()
+	kademliaIdFromKey := routing.NewKademliaID(key)
+	if kademliaIdFromKey == nil {
+		return nil
+	}
+	contacts, hops := kademlia.lookupContact(kademliaIdFromKey, nil)
+
+	responses := make(chan rpc.RecordResponse, len(contacts))
+	for i := range contacts {
+		go rpc.SendFindRecordMessage(kademlia.network, &contacts[i], key, responses)
+	}
+
+	var newest rpc.RecordResponse
+	staleContacts := []*routing.Contact{}
+	for range contacts {
+		response := <-responses
+		if response.Record == nil {
+			continue
+		}
+		if response.Record.NewerThan(newest.Record) {
+			if newest.Record != nil {
+				staleContacts = append(staleContacts, newest.Contact)
+			}
+			newest = response
+		} else if response.Record.Sequence < newest.Record.Sequence {
+			staleContacts = append(staleContacts, response.Contact)
+		}
+	}
+
+	for _, contact := range staleContacts {
+		kademlia.logger.Debug("Updating stale record", "rpc", "STORE_RECORD", "peer", contact.String(), "key", key)
+		go rpc.SendStoreRecordMessage(kademlia.network, contact, newest.Record, newest.TTL)
+	}
+
+	observeLookup(LOOKUP_KIND_RECORD, hops, started, newest.Record != nil)
+	return newest.Record
+}
+
+// Announce to the closest nodes of `key` that this node can serve its content
+//
+// The nodes keep this node as a provider for `ttl`, or the default provider
+// TTL if zero. Returns an error if no node added the provider.
+func (kademlia *Kademlia) AnnounceProvider(key string, ttl time.Duration) error {
+	kademliaIdFromKey := routing.NewKademliaID(key)
+	if kademliaIdFromKey == nil {
+		return errors.New("invalid key")
+	}
+
+	contacts := kademlia.LookupContact(kademliaIdFromKey)
+	if len(contacts) == 0 {
+		return errors.New("no suitable contacts found for storage")
+	}
+
+	var lastErr error
+	accepted := 0
+	for _, contact := range contacts {
+		err := rpc.SendAddProviderMessage(kademlia.network, &contact, key, ttl)
+		if err != nil {
+			kademlia.logger.Info("Could not add provider", "rpc", "ADD_PROVIDER", "peer", contact.String(), "key", key, "err", err)
+			lastErr = err
+		} else {
+			accepted++
+		}
+	}
+
+	if accepted == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// Find the contacts that can serve the content of `key`
+//
+// All of the closest nodes of the key are asked, and every provider any of
+// them knows is returned once.
+func (kademlia *Kademlia) FindProviders(key string) []routing.Contact {
+	kademliaIdFromKey := routing.NewKademliaID(key)
+	if kademliaIdFromKey == nil {
+		return nil
+	}
+	contacts := kademlia.LookupContact(kademliaIdFromKey)
+
+	responses := make(chan rpc.ProvidersResponse, len(contacts))
+	for i := range contacts {
+		go rpc.SendGetProvidersMessage(kademlia.network, &contacts[i], key, responses)
+	}
+
+	providers := []routing.Contact{}
+	seen := make(map[routing.KademliaID]bool)
+	for range contacts {
+		response := <-responses
+		for _, provider := range response.Providers {
+			if provider.ID != nil && !seen[*provider.ID] {
+				seen[*provider.ID] = true
+				providers = append(providers, provider)
+			}
+		}
+	}
+	return providers
+}
+
 // Join a kademlia network by through a known node
 func (kademlia *Kademlia) JoinNetwork(knownNode *routing.Contact, retries int) bool {
-	log.Printf("Joining network via %v...", knownNode)
+	kademlia.logger.Info("Joining network", "peer", knownNode.Address)
+	kademlia.joinState.Store(JOIN_STATE_JOINING)
 
 	contacts, deadContacts := kademlia.joinNetworkAux(knownNode, 0, retries)
 
 	if contacts == 0 {
-		log.Printf("Failed to join network, no contacts received")
+		kademlia.logger.Error("Failed to join network, no contacts received", "peer", knownNode.Address)
+		kademlia.joinState.Store(JOIN_STATE_FAILED)
 		return false
 	} else if contacts != 0 && contacts == deadContacts {
-		log.Printf("Failed to join network, no contacts responded in time")
+		kademlia.logger.Error("Failed to join network, no contacts responded in time", "peer", knownNode.Address)
+		kademlia.joinState.Store(JOIN_STATE_FAILED)
 		return false
 	}
-	log.Printf("Succesfully joined network, recieved %d (%d dead) nodes from %v\n", contacts, deadContacts, knownNode.Address)
+	kademlia.joinState.Store(JOIN_STATE_JOINED)
+	kademlia.logger.Info("Joined network", "peer", knownNode.Address, "contacts", contacts, "dead", deadContacts)
 	return true
 }
 
@@ -207,7 +523,7 @@ func (kademlia *Kademlia) joinNetworkAux(knownNode *routing.Contact, numberOfRet
 	contacts := <-repononseChannel
 	backoffTime := getExponentialBackoffTime(numberOfRetries)
 	if len(contacts) == 0 {
-		log.Printf("No contacts recieved from %v, trying again in %v\n", knownNode.Address, backoffTime)
+		kademlia.logger.Info("No contacts received, trying again", "peer", knownNode.Address, "backoff", backoffTime)
 		time.Sleep(backoffTime)
 		return kademlia.joinNetworkAux(knownNode, numberOfRetries+1, maxRestries)
 	}
@@ -1,10 +1,14 @@
 package kademlia
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	mocks "d7024e/internal/test/mock"
 	"d7024e/kademlia/datastore"
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
+	"d7024e/kademlia/record"
 	"d7024e/util"
 	"math"
 	"testing"
@@ -78,12 +82,17 @@ func TestLookupContact(t *testing.T) {
 
 	// Run test
 	kademlia := NewKademlia(&me, networkMock, nil)
+	lookups := Lookups.WithLabelValues(LOOKUP_KIND_NODE, "true").Value()
 	actual := kademlia.LookupContact(targetId)
+	// Node A, then B, then C and D
+	_, hops := kademlia.lookupContact(targetId, nil)
 
 	assert.Equal(t, len(expected), len(actual))
 	for i := 0; i < len(expected); i++ {
 		assert.Equal(t, expected[i].ID, actual[i].ID)
 	}
+	assert.Equal(t, 3, hops)
+	assert.Equal(t, lookups+1, Lookups.WithLabelValues(LOOKUP_KIND_NODE, "true").Value())
 }
 
 func TestLookupContactAux(t *testing.T) {
@@ -130,7 +139,7 @@ func TestLookupContactAux(t *testing.T) {
 
 	// Run test
 	kademlia := NewKademlia(&me, networkMock, nil)
-	kademlia.lookupContactAux(targetId, []routing.Contact{nodeA}, candidateList)
+	kademlia.lookupContactAux(targetId, []routing.Contact{nodeA}, candidateList, nil, TRACE_NO_PARENT)
 	actual := candidateList.GetAll()
 
 	assert.Equal(t, len(expected), len(actual))
@@ -151,7 +160,7 @@ func TestLookupDataSucces(t *testing.T) {
 	nodeAFindNode_Request := network.NetworkMessage{BodyDigest: "1"}
 	nodeAFindNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
 	nodeAFindValue_Request := network.NetworkMessage{BodyDigest: "3"}
-	nodeAFindValue_Response := network.NetworkMessage{BodyDigest: "4", Body: expectedData}
+	nodeAFindValue_Response := network.NetworkMessage{BodyDigest: "4", Body: []byte(expectedData)}
 	rpcRefresh_Request := network.NetworkMessage{RPC: network.MESSAGE_RPC_DATA_REFRESH}
 
 	// Setup mocks
@@ -160,20 +169,67 @@ func TestLookupDataSucces(t *testing.T) {
 	networkMock.On("GetMe").Return(&me)
 	networkMock.On("GetRoutingTable").Return(routingMock)
 	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA})
-	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, expectedDataHash, mock.Anything).Return(&nodeAFindNode_Request)   // FindNode
-	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_VALUE, mock.Anything, mock.Anything, expectedDataHash, mock.Anything, mock.Anything).Return(&nodeAFindValue_Request) // FindValue
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(expectedDataHash), mock.Anything).Return(&nodeAFindNode_Request) // FindNode
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_VALUE, mock.Anything, mock.Anything, expectedDataHash, mock.Anything, mock.Anything).Return(&nodeAFindValue_Request)       // FindValue
 	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_DATA_REFRESH, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&rpcRefresh_Request)
+	networkMock.On("SendMessageWithResponse", rpcRefresh_Request).Return(network.NetworkMessage{Body: []byte("true")}, false)
 	networkMock.On("SendMessageWithResponse", nodeAFindNode_Request).Return(nodeAFindNode_Response, false)
 	networkMock.On("SendMessageWithResponse", nodeAFindValue_Request).Return(nodeAFindValue_Response, false)
 
 	// Run test
 	kademlia := NewKademlia(&me, networkMock, nil)
-	actualData, actualContact := kademlia.LookupData(expectedDataHash)
+	actualData, actualContact, _ := kademlia.LookupData(expectedDataHash)
 
 	assert.Equal(t, expectedData, string(actualData))
 	assert.Equal(t, nodeA.ID, actualContact.ID)
 }
 
+func TestLookupData_WithMismatchingValue_ShouldTryNextContact(t *testing.T) {
+	expectedData := "data"
+	expectedDataHash := util.Hash([]byte(expectedData))
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	attemptsBefore := LookupPoisoningAttempts.Value()
+
+	// Create nodes, nodeA is closest to the hash and is asked first
+	nodeA := routing.NewContact(routing.NewKademliaID(expectedDataHash), "nodeA")
+	nodeB := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeB")
+	isContact := func(expected routing.Contact) interface{} {
+		return mock.MatchedBy(func(contact *routing.Contact) bool { return contact.ID.Equals(expected.ID) })
+	}
+
+	// Create network messages
+	findNode_Request := network.NetworkMessage{BodyDigest: "1"}
+	findNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
+	nodeAFindValue_Request := network.NetworkMessage{BodyDigest: "3"}
+	nodeAFindValue_Response := network.NetworkMessage{BodyDigest: "4", Body: []byte("poisoned")}
+	nodeBFindValue_Request := network.NetworkMessage{BodyDigest: "5"}
+	nodeBFindValue_Response := network.NetworkMessage{BodyDigest: "6", Body: []byte(expectedData)}
+	rpcRefresh_Request := network.NetworkMessage{RPC: network.MESSAGE_RPC_DATA_REFRESH}
+
+	// Setup mocks
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA, nodeB})
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(expectedDataHash), mock.Anything).Return(&findNode_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_VALUE, mock.Anything, isContact(nodeA), expectedDataHash, mock.Anything, mock.Anything).Return(&nodeAFindValue_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_VALUE, mock.Anything, isContact(nodeB), expectedDataHash, mock.Anything, mock.Anything).Return(&nodeBFindValue_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_DATA_REFRESH, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&rpcRefresh_Request)
+	networkMock.On("SendMessageWithResponse", rpcRefresh_Request).Return(network.NetworkMessage{Body: []byte("true")}, false)
+	networkMock.On("SendMessageWithResponse", findNode_Request).Return(findNode_Response, false)
+	networkMock.On("SendMessageWithResponse", nodeAFindValue_Request).Return(nodeAFindValue_Response, false)
+	networkMock.On("SendMessageWithResponse", nodeBFindValue_Request).Return(nodeBFindValue_Response, false)
+
+	// Run test
+	kademlia := NewKademlia(&me, networkMock, nil)
+	actualData, actualContact, _ := kademlia.LookupData(expectedDataHash)
+
+	assert.Equal(t, expectedData, string(actualData))
+	assert.Equal(t, nodeB.ID, actualContact.ID)
+	assert.Equal(t, attemptsBefore+1, LookupPoisoningAttempts.Value())
+}
+
 func TestLookupDataTimeout(t *testing.T) {
 	t.Skip("Not implemented")
 }
@@ -193,7 +249,7 @@ func TestLookupDataNotFound(t *testing.T) {
 	nodeAFindNode_Request := network.NetworkMessage{BodyDigest: "1"}
 	nodeAFindNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
 	nodeAFindValue_Request := network.NetworkMessage{BodyDigest: "3"}
-	nodeAFindValue_Response := network.NetworkMessage{BodyDigest: "4", Body: ""}
+	nodeAFindValue_Response := network.NetworkMessage{BodyDigest: "4"}
 	rpcRefresh_Request := network.NetworkMessage{RPC: network.MESSAGE_RPC_DATA_REFRESH}
 
 	// Setup mocks
@@ -202,15 +258,16 @@ func TestLookupDataNotFound(t *testing.T) {
 	networkMock.On("GetMe").Return(&me)
 	networkMock.On("GetRoutingTable").Return(routingMock)
 	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA})
-	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, requestedDataHash, mock.Anything).Return(&nodeAFindNode_Request)   // FindNode
-	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_VALUE, mock.Anything, mock.Anything, requestedDataHash, mock.Anything, mock.Anything).Return(&nodeAFindValue_Request) // FindValue
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(requestedDataHash), mock.Anything).Return(&nodeAFindNode_Request) // FindNode
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_VALUE, mock.Anything, mock.Anything, requestedDataHash, mock.Anything, mock.Anything).Return(&nodeAFindValue_Request)       // FindValue
 	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_DATA_REFRESH, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&rpcRefresh_Request)
+	networkMock.On("SendMessageWithResponse", rpcRefresh_Request).Return(network.NetworkMessage{Body: []byte("true")}, false)
 	networkMock.On("SendMessageWithResponse", nodeAFindNode_Request).Return(nodeAFindNode_Response, false)
 	networkMock.On("SendMessageWithResponse", nodeAFindValue_Request).Return(nodeAFindValue_Response, false)
 
 	// Run test
 	kademlia := NewKademlia(&me, networkMock, nil)
-	actualData, actualContact := kademlia.LookupData(requestedDataHash)
+	actualData, actualContact, _ := kademlia.LookupData(requestedDataHash)
 
 	assert.Equal(t, expectedData, actualData)
 	assert.Equal(t, expectedContact, actualContact)
@@ -225,23 +282,122 @@ func TestForgetData_WithHash_ShouldForgetData(t *testing.T) {
 	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
 	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
 	forgetData_Request := network.NetworkMessage{BodyDigest: "3"}
+	forgetData_Response := network.NetworkMessage{BodyDigest: "4", Body: []byte("true")}
 
 	// Setup mocks
 	networkMock := new(mocks.NetworkMockObject)
 	networkMock.On("GetMe").Return(&me)
 	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_DATA_FORGET, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&forgetData_Request)
+	networkMock.On("SendMessageWithResponse", mock.Anything).Return(forgetData_Response, false)
 
 	// Run test
 	kademlia := NewKademlia(&me, networkMock, nil)
 	contacts := []routing.Contact{nodeA}
-	err := kademlia.ForgetData(dataHash, contacts)
+	responses, err := kademlia.ForgetData(dataHash, contacts)
 	assert.Nil(t, err)
+	assert.Equal(t, []rpc.ForgetResponse{{Contact: nodeA, Removed: true}}, responses)
+	assert.Equal(t, kademlia.GetPublicKey(), forgetData_Request.PublicKey)
+	assert.NotEmpty(t, forgetData_Request.Signature)
 }
 
 func TestForgetData_WhenHashIsInvalid_ShouldReturnError(t *testing.T) {
 	t.Skip("Not implemented")
 }
 
+func TestPublishRecord(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	rec := record.New(privateKey, 1, []byte("value"))
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
+
+	// Create network messages
+	findNode_Request := network.NetworkMessage{BodyDigest: "1"}
+	findNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
+	storeRecord_Request := network.NetworkMessage{BodyDigest: "3", TTL: time.Minute}
+	storeRecord_Response := network.NetworkMessage{BodyDigest: "4", Body: []byte(network.ErrStaleRecord.Error())}
+
+	// Setup mocks
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA})
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(rec.Key()), mock.Anything).Return(&findNode_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_STORE_RECORD, mock.Anything, mock.Anything, rec.Key(), record.Encode(rec), mock.Anything).Return(&storeRecord_Request)
+	networkMock.On("SendMessageWithResponse", findNode_Request).Return(findNode_Response, false)
+	networkMock.On("SendMessageWithResponse", storeRecord_Request).Return(storeRecord_Response, false)
+
+	// Run test
+	kademlia := NewKademlia(&me, networkMock, nil)
+	actual := kademlia.PublishRecord(rec, time.Minute)
+	forged := *rec
+	forged.Sequence++
+
+	assert.NotNil(t, actual)
+	assert.Contains(t, actual.Error(), network.ErrStaleRecord.Error())
+	assert.ErrorIs(t, kademlia.PublishRecord(&forged, 0), record.ErrInvalidSignature)
+}
+
+func TestLookupRecord_ShouldReturnNewestRecord(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	older := record.New(privateKey, 1, []byte("older"))
+	newer := record.New(privateKey, 2, []byte("newer"))
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
+	nodeB := routing.NewContact(routing.NewKademliaID("00000000000000000000000000000000000000F0"), "nodeB")
+	isContact := func(expected routing.Contact) interface{} {
+		return mock.MatchedBy(func(contact *routing.Contact) bool { return contact.ID.Equals(expected.ID) })
+	}
+
+	// Create network messages
+	findNode_Request := network.NetworkMessage{BodyDigest: "1"}
+	findNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
+	nodeAFindRecord_Request := network.NetworkMessage{BodyDigest: "3"}
+	nodeAFindRecord_Response := network.NetworkMessage{BodyDigest: "4", Body: record.Encode(older)}
+	nodeBFindRecord_Request := network.NetworkMessage{BodyDigest: "5"}
+	nodeBFindRecord_Response := network.NetworkMessage{BodyDigest: "6", Body: record.Encode(newer)}
+	storeRecord_Request := network.NetworkMessage{BodyDigest: "7"}
+	storeRecord_Response := network.NetworkMessage{BodyDigest: "8", Body: []byte("true")}
+
+	// Setup mocks
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA, nodeB})
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(newer.Key()), mock.Anything).Return(&findNode_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_RECORD, mock.Anything, isContact(nodeA), newer.Key(), mock.Anything, mock.Anything).Return(&nodeAFindRecord_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_RECORD, mock.Anything, isContact(nodeB), newer.Key(), mock.Anything, mock.Anything).Return(&nodeBFindRecord_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_STORE_RECORD, mock.Anything, isContact(nodeA), newer.Key(), record.Encode(newer), mock.Anything).Return(&storeRecord_Request)
+	networkMock.On("SendMessageWithResponse", findNode_Request).Return(findNode_Response, false)
+	networkMock.On("SendMessageWithResponse", nodeAFindRecord_Request).Return(nodeAFindRecord_Response, false)
+	networkMock.On("SendMessageWithResponse", nodeBFindRecord_Request).Return(nodeBFindRecord_Response, false)
+	// The request is changed by the goroutine that stores the record, so it
+	// is matched by its digest, and the call is told over a channel
+	stored := make(chan bool, 1)
+	networkMock.On("SendMessageWithResponse", mock.MatchedBy(func(msg network.NetworkMessage) bool {
+		return msg.BodyDigest == storeRecord_Request.BodyDigest
+	})).Return(storeRecord_Response, false).Run(func(mock.Arguments) { stored <- true })
+
+	// Run test
+	kademlia := NewKademlia(&me, networkMock, nil)
+	actual := kademlia.LookupRecord(newer.Key())
+
+	assert.Equal(t, newer, actual)
+	select {
+	case <-stored:
+	case <-time.After(time.Second):
+		t.Error("Expected the newest record to be stored at the node with the older one")
+	}
+}
+
+func TestLookupRecord_WhenKeyIsInvalid_ShouldReturnNil(t *testing.T) {
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	kademlia := NewKademlia(&me, new(mocks.NetworkMockObject), nil)
+
+	assert.Nil(t, kademlia.LookupRecord("invalid"))
+}
+
 func TestJoinNetwork_WhenNetworkIsEmpty(t *testing.T) {
 	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "me")
 	knownNode := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000002"), "bootstrap")
@@ -344,3 +500,212 @@ func Test_getExponentialBackoffTime(t *testing.T) {
 
 	assert.Greater(t, actual, expectedAtLeast) // We can't assert exact value because of randomness
 }
+
+func TestFindProviders_ShouldReturnEachProviderOnce(t *testing.T) {
+	key := "00000000000000000000000000000000000000ff"
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
+	nodeB := routing.NewContact(routing.NewKademliaID("00000000000000000000000000000000000000F0"), "nodeB")
+	providerA := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000A00"), "providerA")
+	providerB := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000B00"), "providerB")
+	isContact := func(expected routing.Contact) interface{} {
+		return mock.MatchedBy(func(contact *routing.Contact) bool { return contact.ID.Equals(expected.ID) })
+	}
+
+	// Create network messages
+	findNode_Request := network.NetworkMessage{BodyDigest: "1"}
+	findNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
+	nodeAGetProviders_Request := network.NetworkMessage{BodyDigest: "3"}
+	nodeAGetProviders_Response := network.NetworkMessage{BodyDigest: "4", Contacts: []routing.Contact{providerA}}
+	nodeBGetProviders_Request := network.NetworkMessage{BodyDigest: "5"}
+	nodeBGetProviders_Response := network.NetworkMessage{BodyDigest: "6", Contacts: []routing.Contact{providerA, providerB}}
+
+	// Setup mocks
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA, nodeB})
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(key), mock.Anything).Return(&findNode_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_GET_PROVIDERS, mock.Anything, isContact(nodeA), key, mock.Anything, mock.Anything).Return(&nodeAGetProviders_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_GET_PROVIDERS, mock.Anything, isContact(nodeB), key, mock.Anything, mock.Anything).Return(&nodeBGetProviders_Request)
+	networkMock.On("SendMessageWithResponse", findNode_Request).Return(findNode_Response, false)
+	networkMock.On("SendMessageWithResponse", nodeAGetProviders_Request).Return(nodeAGetProviders_Response, false)
+	networkMock.On("SendMessageWithResponse", nodeBGetProviders_Request).Return(nodeBGetProviders_Response, false)
+
+	// Run test
+	kademlia := NewKademlia(&me, networkMock, nil)
+	actual := kademlia.FindProviders(key)
+
+	assert.ElementsMatch(t, []routing.Contact{providerA, providerB}, actual)
+}
+
+func TestAnnounceProvider_WhenNoNodeAccepts_ShouldReturnError(t *testing.T) {
+	key := "00000000000000000000000000000000000000ff"
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
+
+	// Create network messages
+	findNode_Request := network.NetworkMessage{BodyDigest: "1"}
+	findNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
+	addProvider_Request := network.NetworkMessage{BodyDigest: "3"}
+
+	// Setup mocks
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA})
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(key), mock.Anything).Return(&findNode_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_ADD_PROVIDER, mock.Anything, mock.Anything, key, mock.Anything, mock.Anything).Return(&addProvider_Request)
+	networkMock.On("SendMessageWithResponse", findNode_Request).Return(findNode_Response, false)
+	networkMock.On("SendMessageWithResponse", mock.Anything).Return(network.NetworkMessage{}, true)
+
+	// Run test
+	kademlia := NewKademlia(&me, networkMock, nil)
+	err := kademlia.AnnounceProvider(key, time.Minute)
+
+	assert.NotNil(t, err)
+}
+
+func TestSubscribe_ShouldAnnounceOnlyFirstSubscription(t *testing.T) {
+	key := network.TopicKey("news")
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
+	broker := network.NewBroker()
+
+	// Create network messages
+	findNode_Request := network.NetworkMessage{BodyDigest: "1"}
+	findNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
+	subscribe_Request := network.NetworkMessage{BodyDigest: "3", TTL: network.NETWORK_DEFAULT_SUBSCRIPTION_TTL}
+	subscribe_Response := network.NetworkMessage{BodyDigest: "4", Body: []byte("true")}
+
+	// Setup mocks
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetBroker").Return(broker)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA})
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(key), mock.Anything).Return(&findNode_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_SUBSCRIBE, mock.Anything, mock.Anything, key, mock.Anything, mock.Anything).Return(&subscribe_Request)
+	networkMock.On("SendMessageWithResponse", findNode_Request).Return(findNode_Response, false)
+	networkMock.On("SendMessageWithResponse", subscribe_Request).Return(subscribe_Response, false)
+
+	// Run test
+	kademlia := NewKademlia(&me, networkMock, nil)
+	subA, errA := kademlia.Subscribe("news")
+	subB, errB := kademlia.Subscribe("news")
+
+	assert.Nil(t, errA)
+	assert.Nil(t, errB)
+	assert.NotEqual(t, subA, subB)
+	networkMock.AssertNumberOfCalls(t, "SendMessageWithResponse", 2)
+	broker.Unsubscribe(subA)
+	broker.Unsubscribe(subB)
+}
+
+func TestSubscribe_WhenNoNodeAccepts_ShouldCloseSubscription(t *testing.T) {
+	key := network.TopicKey("news")
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
+	broker := network.NewBroker()
+
+	// Create network messages
+	findNode_Request := network.NetworkMessage{BodyDigest: "1"}
+	findNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
+	subscribe_Request := network.NetworkMessage{BodyDigest: "3", TTL: network.NETWORK_DEFAULT_SUBSCRIPTION_TTL}
+
+	// Setup mocks
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetBroker").Return(broker)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA})
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(key), mock.Anything).Return(&findNode_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_SUBSCRIBE, mock.Anything, mock.Anything, key, mock.Anything, mock.Anything).Return(&subscribe_Request)
+	networkMock.On("SendMessageWithResponse", findNode_Request).Return(findNode_Response, false)
+	networkMock.On("SendMessageWithResponse", subscribe_Request).Return(network.NetworkMessage{}, true)
+
+	// Run test
+	kademlia := NewKademlia(&me, networkMock, nil)
+	sub, err := kademlia.Subscribe("news")
+
+	assert.Nil(t, sub)
+	assert.ErrorIs(t, err, rpc.ErrTimeout)
+	assert.False(t, broker.IsSubscribed("news"))
+}
+
+func TestRepublish_WithOtherPublisher_ShouldStoreCachedCopies(t *testing.T) {
+	data := []byte("value")
+	hash := util.Hash(data)
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
+	nodeB := routing.NewContact(routing.NewKademliaID("00000000000000000000000000000000000000F0"), "nodeB")
+	isContact := func(expected routing.Contact) interface{} {
+		return mock.MatchedBy(func(contact *routing.Contact) bool { return contact.ID.Equals(expected.ID) })
+	}
+
+	// Create network messages
+	findNode_Request := network.NetworkMessage{BodyDigest: "1"}
+	findNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
+	nodeAStore_Request := network.NetworkMessage{BodyDigest: "3"}
+	nodeAStore_Response := network.NetworkMessage{BodyDigest: "4", Body: []byte("true")}
+	nodeBStore_Request := network.NetworkMessage{BodyDigest: "5"}
+	nodeBStore_Response := network.NetworkMessage{BodyDigest: "6", Body: []byte(datastore.ErrStoreFull.Error())}
+
+	// Setup mocks
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA, nodeB})
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, []byte(hash), mock.Anything).Return(&findNode_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_STORE, mock.Anything, isContact(nodeA), hash, data, mock.Anything).Return(&nodeAStore_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_STORE, mock.Anything, isContact(nodeB), hash, data, mock.Anything).Return(&nodeBStore_Request)
+	networkMock.On("SendMessageWithResponse", findNode_Request).Return(findNode_Response, false)
+	isStore := func(request network.NetworkMessage) interface{} {
+		return mock.MatchedBy(func(msg network.NetworkMessage) bool { return msg.BodyDigest == request.BodyDigest })
+	}
+	networkMock.On("SendMessageWithResponse", isStore(nodeAStore_Request)).Return(nodeAStore_Response, false)
+	networkMock.On("SendMessageWithResponse", isStore(nodeBStore_Request)).Return(nodeBStore_Response, false)
+
+	// Run test
+	kademlia := NewKademlia(&me, networkMock, nil)
+	stored, err := kademlia.Republish(hash, data, time.Minute, publicKey)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stored)
+	for _, request := range []network.NetworkMessage{nodeAStore_Request, nodeBStore_Request} {
+		assert.True(t, request.Cache)
+		assert.Equal(t, time.Minute, request.TTL)
+		assert.Nil(t, request.PublicKey)
+	}
+}
+
+func TestStatus_ShouldListFilledBuckets(t *testing.T) {
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	lengths := make([]int, routing.IDLength*8)
+	lengths[158] = 1
+	lengths[159] = 2
+	routingTableMock := new(mocks.RoutingTableMockObject)
+	routingTableMock.On("BucketLengths").Return(lengths)
+	networkMock := new(mocks.NetworkMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetRoutingTable").Return(routingTableMock)
+	networkMock.On("GetStats").Return(network.NetworkStats{Timeouts: 1})
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("Stats").Return(datastore.Stats{Entries: 4})
+	kademlia := NewKademlia(&me, networkMock, storeMock)
+
+	status := kademlia.Status()
+
+	assert.Equal(t, "0000000000000000000000000000000000000000", status.ID)
+	assert.Equal(t, JOIN_STATE_NOT_JOINED, status.JoinState)
+	assert.Equal(t, 3, status.Contacts)
+	assert.Equal(t, []network.BucketFill{{Index: 158, Contacts: 1}, {Index: 159, Contacts: 2}}, status.Buckets)
+	assert.Equal(t, 4, status.Store.Entries)
+	assert.Equal(t, uint64(1), status.Network.Timeouts)
+}
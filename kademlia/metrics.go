@@ -0,0 +1,48 @@
+package kademlia
+
+import (
+	"d7024e/metrics"
+	"strconv"
+	"time"
+)
+
+// Kinds of lookups, as counted in the metrics
+const (
+	LOOKUP_KIND_NODE   = "node"
+	LOOKUP_KIND_VALUE  = "value"
+	LOOKUP_KIND_RECORD = "record"
+)
+
+var (
+	// Values returned by FIND_VALUE that do not hash to the requested key
+	LookupPoisoningAttempts = metrics.NewCounter(
+		"kademlia_lookup_poisoning_attempts_total",
+		"FIND_VALUE responses rejected because the value did not match its hash.")
+
+	// Lookups by kind, and whether they found what they looked for
+	Lookups = metrics.NewCounterVec(
+		"kademlia_lookups_total",
+		"Lookups, by kind and whether they found any contact, value or record.",
+		"kind", "found")
+
+	// Hops of the longest path of nodes a lookup asked, by kind
+	LookupHops = metrics.NewHistogramVec(
+		"kademlia_lookup_hops",
+		"Hops of the longest path of nodes asked by a lookup, by kind.",
+		[]float64{1, 2, 3, 4, 5, 6, 8, 10, 15, 20},
+		"kind")
+
+	// Time a lookup took, by kind
+	LookupDuration = metrics.NewHistogramVec(
+		"kademlia_lookup_duration_seconds",
+		"Time a lookup took, by kind.",
+		metrics.DefaultBuckets,
+		"kind")
+)
+
+// Add a lookup that started at `started` to the metrics
+func observeLookup(kind string, hops int, started time.Time, found bool) {
+	Lookups.WithLabelValues(kind, strconv.FormatBool(found)).Inc()
+	LookupHops.WithLabelValues(kind).Observe(float64(hops))
+	LookupDuration.WithLabelValues(kind).Observe(time.Since(started).Seconds())
+}
@@ -0,0 +1,133 @@
+package network
+
+import (
+	"d7024e/kademlia/network/routing"
+	"encoding/json"
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+var ErrSenderNotBound = errors.New("sender does not advertise the IP the request came from")
+
+// A contact kept for a key until it expires, e.g. a provider or a subscriber
+type contactEntry struct {
+	Contact    routing.Contact
+	Expiration time.Time
+}
+
+// Add `contact` to the contact list kept under `storeKey` until `ttl` has
+// passed. Adding a contact again only changes when it expires.
+//
+// When the list holds `max` contacts, the contact that expires soonest makes
+// room.
+func (network *Network) addContactEntry(storeKey string, contact routing.Contact, ttl time.Duration, max int, now time.Time) error {
+	contact.Distance = nil
+
+	network.contactListLock.Lock()
+	defer network.contactListLock.Unlock()
+
+	current, _ := network.metadata.Get(storeKey)
+	entries := []contactEntry{{contact, now.Add(ttl)}}
+	for _, entry := range liveContactEntries(current, now) {
+		if !entry.Contact.ID.Equals(contact.ID) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Expiration.After(entries[j].Expiration) })
+	if len(entries) > max {
+		entries = entries[:max]
+	}
+	return network.writeContactEntries(storeKey, entries, now)
+}
+
+// Remove the contact with the ID and address of `contact` from the contact
+// list kept under `storeKey`. Returns whether the contact was in the list.
+func (network *Network) removeContactEntry(storeKey string, contact routing.Contact, now time.Time) (bool, error) {
+	network.contactListLock.Lock()
+	defer network.contactListLock.Unlock()
+
+	current, exists := network.metadata.Get(storeKey)
+	if !exists {
+		return false, nil
+	}
+
+	removed := false
+	entries := []contactEntry{}
+	for _, entry := range liveContactEntries(current, now) {
+		if entry.Contact.ID.Equals(contact.ID) && entry.Contact.Address == contact.Address {
+			removed = true
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+	if !removed {
+		return false, nil
+	}
+	if len(entries) == 0 {
+		network.metadata.Remove(storeKey)
+		return true, nil
+	}
+	return true, network.writeContactEntries(storeKey, entries, now)
+}
+
+// Get the contacts in the contact list kept under `storeKey` that have not
+// expired
+func (network *Network) getContactEntries(storeKey string, now time.Time) []routing.Contact {
+	current, _ := network.metadata.Get(storeKey)
+	entries := liveContactEntries(current, now)
+
+	contacts := make([]routing.Contact, len(entries))
+	for i, entry := range entries {
+		contacts[i] = entry.Contact
+	}
+	return contacts
+}
+
+// Write a contact list sorted with the latest expiration first. The list
+// lives as long as the contact that expires last.
+func (network *Network) writeContactEntries(storeKey string, entries []contactEntry, now time.Time) error {
+	data, _ := json.Marshal(entries)
+	return network.metadata.Update(storeKey, data, entries[0].Expiration.Sub(now), func(current []byte) bool {
+		return true
+	})
+}
+
+// Decode a contact list and drop the contacts that have expired
+func liveContactEntries(data []byte, now time.Time) []contactEntry {
+	var entries []contactEntry
+	if len(data) == 0 || json.Unmarshal(data, &entries) != nil {
+		return nil
+	}
+
+	live := entries[:0]
+	for _, entry := range entries {
+		if entry.Contact.ID != nil && entry.Expiration.After(now) {
+			live = append(live, entry)
+		}
+	}
+	return live
+}
+
+// Get the contact that sent a request, with only the addresses it advertises
+// at the IP the request came from. A node can then not add another node to a
+// contact list, e.g. as a provider. The port is not checked, as requests are
+// sent from a new socket each.
+//
+// Returns ErrSenderNotBound if the contact advertises no address at that IP.
+func boundSender(sender routing.Contact, senderAddr *net.UDPAddr) (routing.Contact, error) {
+	var addresses []string
+	for _, address := range sender.AllAddresses() {
+		host, _, err := net.SplitHostPort(address)
+		if err == nil && net.ParseIP(host).Equal(senderAddr.IP) {
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) == 0 {
+		return sender, ErrSenderNotBound
+	}
+	sender.Address = addresses[0]
+	sender.Addresses = addresses[1:]
+	return sender, nil
+}
@@ -0,0 +1,148 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"d7024e/kademlia/datastore"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	// How far the time a FORGET was signed may be from the clock of the node
+	// that receives it. Older FORGETs are refused, so a captured one cannot
+	// be replayed once the value is stored again.
+	NETWORK_FORGET_MAX_AGE = time.Minute
+
+	// Tombstones are kept in the metadata store under the key of the
+	// forgotten dataobject with this prefix.
+	NETWORK_TOMBSTONE_KEY_PREFIX = "tombstone:"
+
+	// Tombstones left by a FORGET whose signer did not own the dataobject
+	// here are kept apart, under the key of the dataobject with this prefix.
+	NETWORK_WEAK_TOMBSTONE_KEY_PREFIX = "weak-tombstone:"
+
+	// How long a tombstone is kept. It outlives any TTL a STORE may ask for.
+	NETWORK_TOMBSTONE_TTL = NETWORK_MAX_TTL
+)
+
+var (
+	ErrUnauthorizedForget = errors.New("forget is not signed by the publisher")
+	ErrStaleForget        = errors.New("forget was signed too long ago")
+	ErrForgotten          = errors.New("value was forgotten by its publisher")
+)
+
+// Get the metadata store key of the tombstone of the dataobject with the given key
+func TombstoneStoreKey(key string) string {
+	return NETWORK_TOMBSTONE_KEY_PREFIX + key
+}
+
+// Get the metadata store key of the weak tombstone of the dataobject with the
+// given key
+func WeakTombstoneStoreKey(key string) string {
+	return NETWORK_WEAK_TOMBSTONE_KEY_PREFIX + key
+}
+
+// Sign a FORGET message with the key of the publisher of the dataobject.
+//
+// The time of signing is sent in the body of the message, and the public key
+// and signature along with it.
+func SignForget(msg *NetworkMessage, key ed25519.PrivateKey, now time.Time) {
+	msg.Body = make([]byte, 8)
+	binary.BigEndian.PutUint64(msg.Body, uint64(now.UnixNano()))
+	msg.PublicKey = key.Public().(ed25519.PublicKey)
+	msg.Signature = ed25519.Sign(key, forgetPayload(msg.BodyDigest, msg.Body))
+}
+
+// Forget a dataobject if the FORGET message is signed by its publisher.
+// Returns whether the dataobject was held and removed.
+//
+// Cached copies, and replicas stored before STOREs had to be signed, have no
+// publisher, and are removed by any signed FORGET, so that no one can keep a
+// dataobject from being forgotten by storing it first.
+//
+// A tombstone is left by every FORGET that is accepted, so that a replica
+// that missed it cannot store the dataobject here again, e.g. when a lookup
+// caches it on the way. If the signer removed a replica it published, the
+// tombstone refuses every STORE of the dataobject until it expires. Otherwise
+// the node cannot tell who published the dataobject, and leaves a weak
+// tombstone. It refuses cached copies and STOREs signed by the signer of the
+// FORGET, but not those of another publisher, so that no one can keep the
+// dataobjects of others from being stored by forgetting them first.
+func (network *Network) forget(msg *NetworkMessage) (removed bool, err error) {
+	if err := verifyForget(msg, time.Now()); err != nil {
+		return false, err
+	}
+	publisher, exists := network.datastore.Publisher(msg.BodyDigest)
+	if exists && len(publisher) > 0 && !bytes.Equal(publisher, msg.PublicKey) {
+		return false, ErrUnauthorizedForget
+	}
+	if exists {
+		network.datastore.Remove(msg.BodyDigest)
+	}
+
+	if len(publisher) > 0 {
+		err = network.metadata.Update(TombstoneStoreKey(msg.BodyDigest), msg.PublicKey, NETWORK_TOMBSTONE_TTL, func(current []byte) bool {
+			return true
+		})
+	} else {
+		// The publisher of a weak tombstone is the signer of the FORGET. An
+		// existing tombstone is kept, so that a FORGET of another signer
+		// cannot take its place.
+		err = network.metadata.SetPublished(WeakTombstoneStoreKey(msg.BodyDigest), msg.PublicKey, NETWORK_TOMBSTONE_TTL, msg.PublicKey)
+		if errors.Is(err, datastore.ErrKeyExists) {
+			err = nil
+		}
+	}
+	if err != nil {
+		network.logger.Error("Could not leave tombstone", "key", msg.BodyDigest, "err", err)
+	}
+	return exists, nil
+}
+
+// Check if a dataobject has a tombstone, weak or not. The tombstone is not
+// refreshed by checking it, so that STOREs do not keep it from expiring.
+func (network *Network) IsForgotten(key string) bool {
+	if _, exists := network.metadata.TTL(TombstoneStoreKey(key)); exists {
+		return true
+	}
+	_, exists := network.metadata.TTL(WeakTombstoneStoreKey(key))
+	return exists
+}
+
+// Check if a tombstone refuses a STORE of a dataobject signed by `publisher`,
+// or of a cached copy if `publisher` is nil. Like IsForgotten, the tombstone
+// is not refreshed.
+func (network *Network) isForgottenFor(key string, publisher ed25519.PublicKey) bool {
+	if _, exists := network.metadata.TTL(TombstoneStoreKey(key)); exists {
+		return true
+	}
+	signer, exists := network.metadata.Publisher(WeakTombstoneStoreKey(key))
+	return exists && (publisher == nil || bytes.Equal(signer, publisher))
+}
+
+// Check that a FORGET message is recently signed by the key it carries.
+func verifyForget(msg *NetworkMessage, now time.Time) error {
+	if len(msg.PublicKey) != ed25519.PublicKeySize || len(msg.Body) != 8 {
+		return ErrUnauthorizedForget
+	}
+	if !ed25519.Verify(msg.PublicKey, forgetPayload(msg.BodyDigest, msg.Body), msg.Signature) {
+		return ErrUnauthorizedForget
+	}
+
+	signed := time.Unix(0, int64(binary.BigEndian.Uint64(msg.Body)))
+	if age := now.Sub(signed); age > NETWORK_FORGET_MAX_AGE || age < -NETWORK_FORGET_MAX_AGE {
+		return ErrStaleForget
+	}
+	return nil
+}
+
+// The bytes signed to forget the dataobject with the given key
+func forgetPayload(key string, signed []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("kademlia-forget:")
+	buf.WriteString(key)
+	buf.Write(signed)
+	return buf.Bytes()
+}
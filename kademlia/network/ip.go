@@ -1,20 +1,288 @@
 package network
 
 import (
-	"log"
+	"d7024e/kademlia/network/routing"
+	"errors"
+	"fmt"
 	"net"
+	"strings"
 )
 
-// Get IP-address of this computer
-func GetOutboundIP() string {
-	// https://stackoverflow.com/a/37382208
-	conn, err := net.Dial("udp", "8.8.8.8:80")
+// Returned with the loopback address when no network interface has an
+// address that other computers can reach
+var ErrNoLocalIP = errors.New("no network interface with a non-loopback address")
+
+const LOOPBACK_IP = "127.0.0.1"
+
+// Get the IP addresses of this computer that other nodes are most likely to
+// reach it at, from the addresses of the network interfaces that are up: the
+// first IPv4 address and the first IPv6 address, in that order, of those it
+// has.
+//
+// Needs no network connection. If no interface has an address other than
+// loopback or link-local, the loopback address is returned with ErrNoLocalIP.
+func GetLocalIPs() ([]string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return []string{LOOPBACK_IP}, fmt.Errorf("%w: %v", ErrNoLocalIP, err)
+	}
+
+	var ips []net.IP
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				ips = append(ips, ipnet.IP)
+			}
+		}
+	}
+
+	chosen := chooseLocalIPs(ips)
+	if len(chosen) == 0 {
+		return []string{LOOPBACK_IP}, ErrNoLocalIP
+	}
+	addresses := make([]string, len(chosen))
+	for i, ip := range chosen {
+		addresses[i] = ip.String()
+	}
+	return addresses, nil
+}
+
+// Choose the first IPv4 address and the first IPv6 address that can be
+// reached from other computers, of those there are
+func chooseLocalIPs(ips []net.IP) []net.IP {
+	var ipv4, ipv6 net.IP
+	for _, ip := range ips {
+		if !ip.IsGlobalUnicast() {
+			continue
+		}
+		if ip.To4() != nil {
+			if ipv4 == nil {
+				ipv4 = ip
+			}
+		} else if ipv6 == nil {
+			ipv6 = ip
+		}
+	}
+
+	chosen := []net.IP{}
+	for _, ip := range []net.IP{ipv4, ipv6} {
+		if ip != nil {
+			chosen = append(chosen, ip)
+		}
+	}
+	return chosen
+}
+
+// Get the addresses a node that listens on `listen` advertises to other
+// nodes, the first of which is its contact address.
+//
+// `advertise`, a comma separated list, is used if it is set, with the port of
+// `listen` for each address that has none, e.g. for a node behind port
+// mapping. Otherwise, the host of `listen` is used, unless it listens on
+// every interface, in which case the IP addresses of this computer are found
+// with GetLocalIPs. A node that listens on 0.0.0.0 only advertises IPv4.
+//
+// Returns an error if `listen` is not a host and port. If the addresses fall
+// back to loopback, they are returned with ErrNoLocalIP.
+func AdvertisedAddresses(listen string, advertise string) ([]string, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", listen, err)
+	}
+
+	if advertise != "" {
+		addresses := []string{}
+		for _, address := range strings.Split(advertise, ",") {
+			address = strings.TrimSpace(address)
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				address = net.JoinHostPort(strings.Trim(address, "[]"), port)
+			}
+			addresses = append(addresses, address)
+		}
+		return addresses, nil
+	}
+
+	listenIP := net.ParseIP(host)
+	if host != "" && (listenIP == nil || !listenIP.IsUnspecified()) {
+		return []string{net.JoinHostPort(host, port)}, nil
+	}
+	ips, err := GetLocalIPs()
+	addresses := []string{}
+	for _, ip := range ips {
+		if listenIP != nil && listenIP.To4() != nil && addressFamily(ip) != FAMILY_IPV4 {
+			continue
+		}
+		addresses = append(addresses, net.JoinHostPort(ip, port))
+	}
+	if len(addresses) == 0 {
+		return []string{net.JoinHostPort(LOOPBACK_IP, port)}, ErrNoLocalIP
+	}
+	return addresses, err
+}
+
+// Families of an address, as decided by its host
+const (
+	FAMILY_IPV4 = "ipv4"
+	FAMILY_IPV6 = "ipv6"
+	// A host name, which may resolve to either family
+	FAMILY_ANY = "any"
+)
+
+// Get the family of an IP address, or of the host of a host and port
+func addressFamily(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return FAMILY_ANY
+	}
+	if ip.To4() != nil {
+		return FAMILY_IPV4
+	}
+	return FAMILY_IPV6
+}
+
+// Most contacts whose last responding address is remembered. When full, an
+// arbitrary contact is forgotten to make room.
+const NETWORK_MAX_PREFERRED_ADDRESSES = 4096
+
+// Whether this node may send to the host of an address. Unspecified and
+// multicast hosts never reach a single peer, and loopback hosts only reach
+// peers on this computer, so they are only kept if this node is on loopback
+// itself, e.g. on a test network.
+func sendableAddress(address string, loopback bool) bool {
+	host, _, err := net.SplitHostPort(address)
 	if err != nil {
-		log.Fatal(err)
+		host = address
+	}
+	if host == "" {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return loopback
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return true
+	}
+	if ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	return loopback || !ip.IsLoopback()
+}
+
+// Whether this node advertises a loopback address
+func (network *Network) onLoopback() bool {
+	for _, address := range network.GetMe().AllAddresses() {
+		if !sendableAddress(address, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the addresses of a contact this node may send to: the first address of
+// each family, in the order the contact gave them, of those it may send to.
+// A contact cannot make this node try any number of addresses, or send to
+// hosts that reach no peer or this computer.
+func (network *Network) sendableAddresses(contact *routing.Contact) []string {
+	loopback := network.onLoopback()
+	families := make(map[string]bool)
+	addresses := []string{}
+	for _, address := range contact.AllAddresses() {
+		family := addressFamily(address)
+		if families[family] || !sendableAddress(address, loopback) {
+			continue
+		}
+		families[family] = true
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// Keep only the addresses of a contact this node may send to, as given by
+// sendableAddresses. Returns false, leaving the contact as it was, if there
+// are none.
+func (network *Network) sanitizeContact(contact *routing.Contact) bool {
+	addresses := network.sendableAddresses(contact)
+	if len(addresses) == 0 {
+		return false
+	}
+	contact.Address = addresses[0]
+	contact.Addresses = addresses[1:]
+	return true
+}
+
+// Order the addresses of a contact this node may send to by how likely it is
+// to reach them: the address that last responded, then the addresses of a
+// family this node has an address of, each in the order the contact gave
+// them.
+func (network *Network) candidateAddresses(contact *routing.Contact) []string {
+	addresses := network.sendableAddresses(contact)
+	if len(addresses) <= 1 {
+		return addresses
+	}
+
+	families := map[string]bool{FAMILY_ANY: true}
+	for _, address := range network.GetMe().AllAddresses() {
+		family := addressFamily(address)
+		if family == FAMILY_ANY {
+			// A node known by a host name may have either family
+			families[FAMILY_IPV4] = true
+			families[FAMILY_IPV6] = true
+		}
+		families[family] = true
 	}
-	defer conn.Close()
 
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
+	preferred := ""
+	if contact.ID != nil {
+		network.addressLock.Lock()
+		preferred = network.preferredAddresses[contact.ID.String()]
+		network.addressLock.Unlock()
+	}
 
-	return localAddr.IP.String()
+	ordered := make([]string, 0, len(addresses))
+	var unreachable []string
+	for _, address := range addresses {
+		switch {
+		case address == preferred:
+			ordered = append([]string{address}, ordered...)
+		case families[addressFamily(address)]:
+			ordered = append(ordered, address)
+		default:
+			unreachable = append(unreachable, address)
+		}
+	}
+	return append(ordered, unreachable...)
+}
+
+// Remember the address of a contact that responded, to be tried first the
+// next time, or forget it if `address` is empty
+func (network *Network) setPreferredAddress(contact *routing.Contact, address string) {
+	if contact.ID == nil || len(contact.Addresses) == 0 {
+		return
+	}
+	network.addressLock.Lock()
+	defer network.addressLock.Unlock()
+
+	id := contact.ID.String()
+	if address == "" {
+		delete(network.preferredAddresses, id)
+		return
+	}
+	if _, exists := network.preferredAddresses[id]; !exists && len(network.preferredAddresses) >= NETWORK_MAX_PREFERRED_ADDRESSES {
+		for other := range network.preferredAddresses {
+			delete(network.preferredAddresses, other)
+			break
+		}
+	}
+	network.preferredAddresses[id] = address
 }
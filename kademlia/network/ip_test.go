@@ -0,0 +1,146 @@
+package network
+
+import (
+	"d7024e/config"
+	"d7024e/kademlia/network/routing"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChooseLocalIPs_ShouldChooseFirstReachableOfEachFamily(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("fe80::1"),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("127.0.0.1"),
+		net.ParseIP("172.19.0.5"),
+		net.ParseIP("2001:db8::2"),
+		net.ParseIP("172.19.0.6"),
+	}
+
+	assert.Equal(t, []net.IP{net.ParseIP("172.19.0.5"), net.ParseIP("2001:db8::1")}, chooseLocalIPs(ips))
+	assert.Equal(t, []net.IP{net.ParseIP("2001:db8::1")}, chooseLocalIPs(ips[:3]))
+	assert.Empty(t, chooseLocalIPs(ips[:1]))
+}
+
+func TestAdvertisedAddresses_WithSpecificListenHost_ShouldUseIt(t *testing.T) {
+	ipv4, err := AdvertisedAddresses("127.0.0.1:14041", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:14041"}, ipv4)
+
+	ipv6, err := AdvertisedAddresses("[::1]:14041", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"[::1]:14041"}, ipv6)
+}
+
+func TestAdvertisedAddresses_WithAdvertise_ShouldPreferIt(t *testing.T) {
+	mapped, err := AdvertisedAddresses(":14041", "203.0.113.7:24041")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"203.0.113.7:24041"}, mapped)
+
+	hostsOnly, err := AdvertisedAddresses("0.0.0.0:14041", "node.example.com, 2001:db8::7,[2001:db8::8]")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node.example.com:14041", "[2001:db8::7]:14041", "[2001:db8::8]:14041"}, hostsOnly)
+}
+
+func TestAdvertisedAddresses_WithInvalidListen_ShouldFail(t *testing.T) {
+	_, err := AdvertisedAddresses("14041", "")
+
+	assert.ErrorContains(t, err, `invalid listen address "14041"`)
+}
+
+func TestConfigure_WithListenAndAdvertise_ShouldSetAddresses(t *testing.T) {
+	network, me := CreateTestNetwork(14041)
+	config := config.Default()
+	config.Listen = "127.0.0.1:14042"
+	config.Advertise = "203.0.113.7,2001:db8::7"
+
+	err := network.Configure(config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:14042", network.listenAddress)
+	assert.Equal(t, "203.0.113.7:14042", me.Address)
+	assert.Equal(t, []string{"[2001:db8::7]:14042"}, me.Addresses)
+}
+
+func TestCandidateAddresses_ShouldPreferReachableFamilyThenLastResponse(t *testing.T) {
+	network, me := CreateTestNetwork(14041)
+	me.Address = "[2001:db8::1]:14041"
+	me.Addresses = nil
+	contact := routing.NewContact(routing.NewRandomKademliaID(), "10.0.0.2:14041")
+	contact.Addresses = []string{"[2001:db8::2]:14041"}
+
+	assert.Equal(t, []string{"[2001:db8::2]:14041", "10.0.0.2:14041"}, network.candidateAddresses(&contact))
+
+	network.setPreferredAddress(&contact, "10.0.0.2:14041")
+	assert.Equal(t, []string{"10.0.0.2:14041", "[2001:db8::2]:14041"}, network.candidateAddresses(&contact))
+}
+
+func TestSendableAddresses_ShouldKeepFirstOfEachFamilyThatReachesAPeer(t *testing.T) {
+	network, me := CreateTestNetwork(14041)
+	me.Address = "203.0.113.1:14041"
+	me.Addresses = nil
+	contact := routing.NewContact(routing.NewRandomKademliaID(), "127.0.0.1:14041")
+	contact.Addresses = []string{
+		"0.0.0.0:14041", "[::]:14041", "224.0.0.1:14041", "[ff02::1]:14041", "localhost:14041",
+		"10.0.0.2:14041", "10.0.0.3:14041", "[2001:db8::2]:14041", "[2001:db8::3]:14041",
+		"node2:14041", "node3:14041",
+	}
+
+	assert.Equal(t, []string{"10.0.0.2:14041", "[2001:db8::2]:14041", "node2:14041"}, network.sendableAddresses(&contact))
+
+	me.Address = "127.0.0.1:14041"
+	assert.Equal(t, []string{"127.0.0.1:14041", "localhost:14041", "[2001:db8::2]:14041"}, network.sendableAddresses(&contact))
+}
+
+func TestSanitizeContact_WithNoSendableAddress_ShouldRefuse(t *testing.T) {
+	network, me := CreateTestNetwork(14041)
+	me.Address = "203.0.113.1:14041"
+	me.Addresses = nil
+	contact := routing.NewContact(routing.NewRandomKademliaID(), "127.0.0.1:14041")
+	contact.Addresses = []string{"0.0.0.0:14041"}
+
+	assert.False(t, network.sanitizeContact(&contact))
+
+	contact.Addresses = []string{"10.0.0.2:14041", "10.0.0.3:14041"}
+	assert.True(t, network.sanitizeContact(&contact))
+	assert.Equal(t, "10.0.0.2:14041", contact.Address)
+	assert.Empty(t, contact.Addresses)
+}
+
+func TestSetPreferredAddress_WhenFull_ShouldForgetAnother(t *testing.T) {
+	network, _ := CreateTestNetwork(14041)
+	contact := routing.NewContact(nil, "10.0.0.2:14041")
+	contact.Addresses = []string{"[2001:db8::2]:14041"}
+
+	for i := 0; i < NETWORK_MAX_PREFERRED_ADDRESSES+10; i++ {
+		contact.ID = routing.NewRandomKademliaID()
+		network.setPreferredAddress(&contact, contact.Address)
+	}
+
+	assert.Len(t, network.preferredAddresses, NETWORK_MAX_PREFERRED_ADDRESSES)
+	assert.Equal(t, contact.Address, network.preferredAddresses[contact.ID.String()])
+}
+
+func TestSendMessageWithResponse_WithSeveralAddresses_ShouldTryEach(t *testing.T) {
+	networkA, _ := CreateTestNetwork(14043)
+	networkB, _ := CreateTestNetwork(14044)
+	go networkB.Listen()
+	defer networkB.StopListen()
+	time.Sleep(50 * time.Millisecond)
+
+	networkA.GetMe().Address = "127.0.0.1:14043"
+	networkA.GetMe().Addresses = nil
+	target := *networkB.GetMe()
+	target.Address = "[::1]:14045"
+	target.Addresses = []string{"127.0.0.1:14044"}
+	networkA.requestTimeout = 100 * time.Millisecond
+	msg := *networkA.NewNetworkMessage(MESSAGE_RPC_PING, networkA.GetMe(), &target, "", nil, nil)
+
+	_, timeout := networkA.SendMessageWithResponse(msg)
+
+	assert.False(t, timeout)
+	assert.Equal(t, "127.0.0.1:14044", networkA.candidateAddresses(&target)[0])
+}
@@ -0,0 +1,55 @@
+package network
+
+import (
+	"d7024e/kademlia/datastore"
+	"d7024e/util"
+)
+
+// Providers, subscribers, records and tombstones are kept apart from the
+// dataobjects, in a metadata store of their own. They are not found by
+// FIND_VALUE, do not take up the capacity of the datastore and are left out of
+// its stats, listings and snapshots.
+
+const (
+	// Most entries kept in the metadata store. When full, the entry that
+	// expires soonest makes room for a new one.
+	NETWORK_MAX_METADATA_ENTRIES = 100000
+
+	// Largest entry kept in the metadata store, in bytes
+	NETWORK_MAX_METADATA_SIZE = 64 * 1024
+)
+
+// Create the store a node keeps its metadata in, persisted to `dir`, or only
+// in memory if `dir` is empty.
+//
+// Returns an error if the store could not be opened in `dir`.
+func NewMetadataStore(dir string, timeprovider util.ITimeProvider) (*datastore.DataStore, error) {
+	var store *datastore.DataStore
+	if dir == "" {
+		store = datastore.NewDataStore(NETWORK_DEFAULT_PROVIDER_TTL, nil, timeprovider)
+	} else {
+		var err error
+		store, err = datastore.NewDiskDataStore(dir, NETWORK_DEFAULT_PROVIDER_TTL, nil, timeprovider)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	store.SetCapacity(datastore.Capacity{
+		MaxEntries:   NETWORK_MAX_METADATA_ENTRIES,
+		MaxValueSize: NETWORK_MAX_METADATA_SIZE,
+		Policy:       datastore.SoonestExpiryPolicy{},
+	})
+	return store, nil
+}
+
+// Get the store this node keeps its metadata in
+func (network *Network) GetMetadataStore() datastore.IDataStore {
+	return network.metadata
+}
+
+// Replace the store this node keeps its metadata in, e.g. with one persisted
+// to disk. Must be called before the node listens.
+func (network *Network) SetMetadataStore(store datastore.IDataStore) {
+	network.metadata = store
+}
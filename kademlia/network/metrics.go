@@ -0,0 +1,54 @@
+package network
+
+import "d7024e/metrics"
+
+var (
+	// STORE requests refused because the value does not hash to its key
+	StorePoisoningAttempts = metrics.NewCounter(
+		"kademlia_store_poisoning_attempts_total",
+		"STORE requests refused because the value did not match its hash.")
+
+	// FORGET requests refused because they are not signed by the publisher
+	UnauthorizedForgets = metrics.NewCounter(
+		"kademlia_unauthorized_forgets_total",
+		"FORGET requests refused because they were not signed by the publisher.")
+
+	// FORGET requests refused because they were signed too long ago
+	StaleForgets = metrics.NewCounter(
+		"kademlia_stale_forgets_total",
+		"FORGET requests refused because they were signed too long ago.")
+
+	// Requests sent and received, by RPC name
+	RPCsSent = metrics.NewCounterVec(
+		"kademlia_rpc_sent_total",
+		"Requests sent to other nodes, by RPC.",
+		"rpc")
+	RPCsReceived = metrics.NewCounterVec(
+		"kademlia_rpc_received_total",
+		"Requests received from other nodes, by RPC.",
+		"rpc")
+
+	// Requests that got no response in time
+	RPCTimeouts = metrics.NewCounter(
+		"kademlia_rpc_timeouts_total",
+		"Requests sent that got no response in time.")
+
+	// Time from sending a request to receiving its response
+	RPCRoundTrip = metrics.NewHistogram(
+		"kademlia_rpc_round_trip_seconds",
+		"Time from sending a request to receiving its response.",
+		metrics.DefaultBuckets)
+
+	// Bytes of the UDP messages sent and received, requests and responses alike
+	BytesSent = metrics.NewCounter(
+		"kademlia_network_sent_bytes_total",
+		"Bytes of messages sent to other nodes.")
+	BytesReceived = metrics.NewCounter(
+		"kademlia_network_received_bytes_total",
+		"Bytes of messages received from other nodes.")
+
+	// Messages that could not be read
+	DeserializeErrors = metrics.NewCounter(
+		"kademlia_network_deserialize_errors_total",
+		"Messages received that could not be deserialized.")
+)
@@ -1,15 +1,19 @@
 package network
 
 import (
+	"crypto/ed25519"
+	"d7024e/config"
 	"d7024e/kademlia/datastore"
 	"d7024e/kademlia/network/routing"
+	"d7024e/logging"
 	"d7024e/util"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,22 +25,52 @@ const (
 	MESSAGE_RPC_FIND_VALUE   = 4
 	MESSAGE_RPC_DATA_REFRESH = 5
 	MESSAGE_RPC_DATA_FORGET  = 6
+	MESSAGE_RPC_STORE_RECORD = 7
+	MESSAGE_RPC_FIND_RECORD  = 8
+	MESSAGE_RPC_ADD_PROVIDER = 9
+	// 10 is MESSAGE_RESPONSE
+	MESSAGE_RPC_GET_PROVIDERS = 11
+	MESSAGE_RPC_SUBSCRIBE     = 12
+	MESSAGE_RPC_UNSUBSCRIBE   = 13
+	MESSAGE_RPC_PUBLISH       = 14
+	MESSAGE_RPC_DELIVER       = 15
 
 	// RPC response
 	MESSAGE_RESPONSE = 10
 )
 
 const (
-	NETWORK_INCOMING_BUFFER        = 8192
-	NETWORK_REQUEST_TIMEOUT        = 2 * time.Second
-	NETWORK_REQUEST_TIMEOUT_STRING = "::timeout::"
+	// Largest UDP payload, so a message is never cut short when it is read
+	NETWORK_INCOMING_BUFFER = 65507
+
+	// Longest TTL a node accepts for a stored dataobject. Longer TTLs are
+	// shortened to this.
+	NETWORK_MAX_TTL = 24 * time.Hour
 )
 
+var ErrDigestMismatch = errors.New("value does not match hash")
+
 type INetwork interface {
 	GetMe() *routing.Contact
 	GetRoutingTable() routing.IRoutingTable
 	GetDatastore() datastore.IDataStore
 
+	// Get the broker that delivers publications to the subscriptions of this
+	// node.
+	GetBroker() *Broker
+
+	// Get the logger of this node, which adds the ID of the node to every
+	// message.
+	GetLogger() *logging.Logger
+
+	// Get the requests this node has sent and received since it started,
+	// how many of them timed out, and the average time to a response.
+	GetStats() NetworkStats
+
+	// Check if the dataobject with the given key was forgotten by its
+	// publisher, so that it must not be stored again.
+	IsForgotten(key string) bool
+
 	// Create a new network instance.
 	//
 	// Parameters:
@@ -53,12 +87,14 @@ type INetwork interface {
 		sender *routing.Contact,
 		target *routing.Contact,
 		bodyDigest string,
-		body string,
+		body []byte,
 		contacts []routing.Contact,
 	) *NetworkMessage
 
-	// Listen for incoming UDP network messages.
-	Listen()
+	// Listen for incoming UDP network messages, until StopListen is called.
+	//
+	// Returns an error if the port could not be bound, or nil once stopped.
+	Listen() error
 
 	// Stop listening for incoming UDP network messages.
 	StopListen()
@@ -67,7 +103,8 @@ type INetwork interface {
 	//
 	// If the contact responds, the response will be returned and `timeout` be false.
 	//
-	// Otherwise, after time to respond exceeds `network.NETWORK_REQUEST_TIMEOUT`,
+	// Otherwise, after time to respond exceeds the request timeout of the
+	// config,
 	// timeout occured and `timeout` will be true.
 	//
 	// Parameters:
@@ -83,16 +120,40 @@ type INetwork interface {
 }
 
 type Network struct {
-	me           *routing.Contact
+	// The contact of this node, a *routing.Contact. A detected external
+	// address is set by storing a new contact, so a contact that was read
+	// from it is never changed.
+	me           atomic.Value
 	routingtable routing.IRoutingTable
 	datastore    datastore.IDataStore
+	metadata     datastore.IDataStore
 
 	incomingData       chan []byte
 	messageCounter     *util.Counter
-	port               int
+	listenAddress      string
 	quitListenSig      chan struct{}
 	incomingDataLock   sync.Mutex
 	incomingDataSocket *net.UDPConn
+	contactListLock    sync.Mutex
+	broker             *Broker
+	publications       *publicationLimiter
+	counters           *rpcCounters
+	logger             *logging.Logger
+
+	// Number of contacts returned by FIND_NODE
+	k              int
+	requestTimeout time.Duration
+
+	// Address that last responded, of each contact with several addresses
+	addressLock        sync.Mutex
+	preferredAddresses map[string]string
+
+	// Addresses of this node before an external address was detected, and
+	// the votes of peers on that address. Set if the addresses are fixed by
+	// the config, and never detected.
+	localAddresses []string
+	observedVotes  *addressVotes
+	fixedAddress   bool
 }
 
 type NetworkMessage struct {
@@ -100,39 +161,118 @@ type NetworkMessage struct {
 	Sender     *routing.Contact
 	Target     *routing.Contact
 	BodyDigest string
-	Body       string
-	Contacts   []routing.Contact
+	// Values are kept as bytes, and sent base64 encoded, so that any value
+	// survives the trip
+	Body     []byte
+	Contacts []routing.Contact
+
+	// TTL of the dataobject to store, or the remaining TTL of a found
+	// dataobject. Zero if not set.
+	TTL time.Duration `json:",omitempty"`
+
+	// Public key of the publisher of the dataobject to store or that was
+	// found, or of the signer of a FORGET
+	PublicKey ed25519.PublicKey `json:",omitempty"`
+	Signature []byte            `json:",omitempty"`
+	// Time a STORE was signed, in Unix nanoseconds
+	SignedAt int64 `json:",omitempty"`
+
+	// Set on a STORE of a copy cached by a lookup, rather than a replica
+	Cache bool `json:",omitempty"`
+
+	// Set on a response to the address the request came from, as the
+	// responder saw it
+	ObservedAddress string `json:",omitempty"`
 }
 
 // Create a new network instance.
 //
 // Parameters:
 //
-//	port: The port to listen on.
+//	port: The port to listen on, on every interface.
 //	datastore: The datastore to use.
 //
 // Returns:
 //
 //	A new network instance and a contact that will be used when communicating
-//	with other nodes.
+//	with other nodes. The addresses of the contact are found with
+//	GetLocalIPs, unless others are set with Configure.
 func NewNetwork(port int, datastore datastore.IDataStore) (*Network, *routing.Contact) {
-	myAddress := fmt.Sprintf("%s:%d", GetOutboundIP(), port)
-	me := routing.NewContact(routing.NewRandomKademliaID(), myAddress)
+	listen := fmt.Sprintf(":%d", port)
+	addresses, err := AdvertisedAddresses(listen, "")
+	me := routing.NewContact(routing.NewRandomKademliaID(), addresses[0])
+	me.Addresses = addresses[1:]
+	config := config.Default()
+	metadata, _ := NewMetadataStore("", &util.TimeProvider{})
 
 	net := Network{
-		me:             &me,
-		routingtable:   routing.NewRoutingTable(me),
+		routingtable:   routing.NewRoutingTable(me, config.BucketSize),
 		datastore:      datastore,
+		metadata:       metadata,
 		incomingData:   make(chan []byte),
 		messageCounter: util.MakeCounter(),
-		port:           port,
+		listenAddress:  listen,
 		quitListenSig:  make(chan struct{}, 1),
+		broker:         NewBroker(),
+		publications:   newPublicationLimiter(),
+		counters:       newRPCCounters(),
+		k:              config.K,
+		requestTimeout: config.RequestTimeout,
+
+		preferredAddresses: make(map[string]string),
+		observedVotes:      newAddressVotes(),
+	}
+	net.me.Store(&me)
+	net.setLocalAddresses()
+	net.SetLogger(logging.Default())
+	if err != nil {
+		net.logger.Warn("Could not find local IP, advertising loopback", "address", me.Address, "err", err)
 	}
 	return &net, &me
 }
 
+// Set the parameters of this node from the config. Must be called before the
+// node listens, since the routing table is replaced to apply the bucket size.
+//
+// If the config sets the listen or advertise address, the node listens on the
+// listen address, and its contact address becomes the advertised address.
+// Returns an error if either is invalid.
+func (network *Network) Configure(config config.Config) error {
+	if config.Listen != "" || config.Advertise != "" {
+		listen := config.Listen
+		if listen == "" {
+			listen = fmt.Sprintf(":%d", config.Port)
+		}
+		addresses, err := AdvertisedAddresses(listen, config.Advertise)
+		if errors.Is(err, ErrNoLocalIP) {
+			network.logger.Warn("Could not find local IP, advertising loopback", "address", addresses[0], "err", err)
+		} else if err != nil {
+			return err
+		}
+		network.listenAddress = listen
+		me := network.GetMe()
+		me.Address = addresses[0]
+		me.Addresses = addresses[1:]
+		network.fixedAddress = config.Advertise != ""
+		network.setLocalAddresses()
+	}
+
+	network.routingtable = routing.NewRoutingTable(*network.GetMe(), config.BucketSize)
+	network.k = config.K
+	network.requestTimeout = config.RequestTimeout
+	return nil
+}
+
+// Set the logger of this node. The ID of the node is added to every message.
+func (network *Network) SetLogger(logger *logging.Logger) {
+	network.logger = logger.With("node", network.GetMe().ID.String())
+	network.broker.logger = network.logger
+}
+
+// Get the contact of this node, with the addresses it currently advertises.
+// The contact must not be changed once the node listens.
 func (network *Network) GetMe() *routing.Contact {
-	return network.me
+	return network.me.Load().(*routing.Contact)
 }
 
 func (network *Network) GetRoutingTable() routing.IRoutingTable {
@@ -143,12 +283,20 @@ func (network *Network) GetDatastore() datastore.IDataStore {
 	return network.datastore
 }
 
+func (network *Network) GetBroker() *Broker {
+	return network.broker
+}
+
+func (network *Network) GetLogger() *logging.Logger {
+	return network.logger
+}
+
 func (network *Network) NewNetworkMessage(
 	rpc int,
 	sender *routing.Contact,
 	target *routing.Contact,
 	bodyDigest string,
-	body string,
+	body []byte,
 	contacts []routing.Contact,
 ) *NetworkMessage {
 	return &NetworkMessage{
@@ -161,17 +309,17 @@ func (network *Network) NewNetworkMessage(
 	}
 }
 
-func (network *Network) Listen() {
-	addr := net.UDPAddr{
-		Port: network.port,
-		IP:   net.ParseIP(network.me.Address),
+func (network *Network) Listen() error {
+	addr, err := net.ResolveUDPAddr("udp", network.listenAddress)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", network.listenAddress, err)
 	}
 
-	socket, err := net.ListenUDP("udp", &addr)
+	socket, err := net.ListenUDP("udp", addr)
 	if err != nil {
-		log.Fatal(err)
-		return
+		return fmt.Errorf("could not listen on %s: %w", network.listenAddress, err)
 	}
+	network.logger.Info("Listening", "address", socket.LocalAddr().String())
 
 	network.incomingDataLock.Lock()
 	network.incomingDataSocket = socket
@@ -183,12 +331,12 @@ func (network *Network) Listen() {
 		buf := make([]byte, NETWORK_INCOMING_BUFFER)
 		select {
 		case <-network.quitListenSig:
-			return
+			return nil
 		default:
 		}
 		len, remote, udpError := socket.ReadFromUDP(buf)
 		if udpError != nil {
-			log.Println(udpError)
+			network.logger.Warn("UDP read error", "err", udpError)
 		}
 		go network.incomingDataHandler(remote, buf[:len])
 	}
@@ -201,72 +349,253 @@ func (network *Network) StopListen() {
 	network.incomingDataLock.Unlock()
 }
 
+// The addresses of the target are tried in turn, until one of them responds.
+// The target is removed from the routing table if none does.
 func (network *Network) SendMessageWithResponse(msg NetworkMessage) (response NetworkMessage, timeout bool) {
-	udpAddr, _ := net.ResolveUDPAddr("udp", msg.Target.Address)
-	res, err := network.sendRequest(udpAddr, msg, true)
-	if err == nil {
-		return *res, false
+	for _, address := range network.candidateAddresses(msg.Target) {
+		udpAddr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			network.logger.Warn("Could not resolve address", "rpc", RPCName(msg.RPC), "peer", address, "err", err)
+			continue
+		}
+		res, err := network.sendRequest(udpAddr, msg, true)
+		if err == nil {
+			network.setPreferredAddress(msg.Target, address)
+			return *res, false
+		}
+	}
+
+	network.setPreferredAddress(msg.Target, "")
+	if msg.Target.ID != nil {
+		network.routingtable.RemoveContact(msg.Target.ID)
 	}
 	return *new(NetworkMessage), true
 }
 
 func (network *Network) SendMessage(msg NetworkMessage) {
-	udpAddr, _ := net.ResolveUDPAddr("udp", msg.Target.Address)
+	addresses := network.candidateAddresses(msg.Target)
+	if len(addresses) == 0 {
+		network.logger.Warn("No address to send to", "rpc", RPCName(msg.RPC), "peer", msg.Target.String())
+		return
+	}
+	udpAddr, _ := net.ResolveUDPAddr("udp", addresses[0])
 	network.sendRequest(udpAddr, msg, false)
 }
 
 // Process incoming network data
 func (network *Network) incomingDataHandler(senderAddr *net.UDPAddr, data []byte) {
+	BytesReceived.Add(uint64(len(data)))
 	msg, err := deserializeMessage(data)
 	if err != nil {
-		log.Printf("Deserialize error: %s\n", err)
+		network.logger.Warn("Deserialize error", "peer", senderAddr.String(), "err", err)
+		DeserializeErrors.Inc()
 		return
 	}
 
-	log.Printf("Message (%d) from %s\n", msg.RPC, msg.Sender.String())
+	network.logger.Debug("Message received", "rpc", RPCName(msg.RPC), "peer", msg.Sender.String())
+	network.counters.countReceived(msg.RPC)
 
 	network.messageHandler(senderAddr, msg)
 }
 
 // Take actions on a network message
 func (network *Network) messageHandler(senderAddr *net.UDPAddr, msg *NetworkMessage) {
-	network.routingtable.AddContact(*msg.Sender)
+	if network.sanitizeContact(msg.Sender) {
+		network.routingtable.AddContact(*msg.Sender)
+	}
 
 	switch msg.RPC {
 	case MESSAGE_RPC_PING:
 		network.generateReturnMessage(msg)
 		network.sendResponse(senderAddr, *msg)
 	case MESSAGE_RPC_STORE:
-		ok := network.datastore.Set(msg.BodyDigest, []byte(msg.Body))
+		var err error
+		if util.Hash(msg.Body) != msg.BodyDigest {
+			// Storing the value would let the sender poison the key
+			network.logger.Warn("Possible poisoning attempt: value does not match hash", "rpc", "STORE", "peer", msg.Sender.String(), "key", msg.BodyDigest)
+			StorePoisoningAttempts.Inc()
+			err = ErrDigestMismatch
+		} else if msg.Cache {
+			// Whoever caches a copy cannot sign for its publisher, so none is
+			// recorded
+			if network.isForgottenFor(msg.BodyDigest, nil) {
+				err = ErrForgotten
+			} else {
+				err = network.datastore.SetCached(msg.BodyDigest, msg.Body, limitTTL(msg.TTL), nil)
+			}
+		} else {
+			// A replica is only stored if its publisher signed it recently
+			var publisher ed25519.PublicKey
+			publisher, err = verifyStore(msg, time.Now())
+			if err != nil {
+				network.logger.Warn("Refused unsigned store", "rpc", "STORE", "peer", msg.Sender.String(), "key", msg.BodyDigest, "err", err)
+			} else if network.isForgottenFor(msg.BodyDigest, publisher) {
+				err = ErrForgotten
+			} else {
+				err = network.datastore.SetPublished(msg.BodyDigest, msg.Body, limitTTL(msg.TTL), publisher)
+			}
+		}
 
-		msg.Body = strconv.FormatBool(ok)
+		// The body holds "true" on success, and the reason otherwise
+		if err == nil {
+			msg.Body = []byte(strconv.FormatBool(true))
+		} else {
+			network.logger.Info("Refused to store", "peer", msg.Sender.String(), "key", msg.BodyDigest, "err", err)
+			msg.Body = []byte(err.Error())
+		}
 		network.generateReturnMessage(msg)
 		network.sendResponse(senderAddr, *msg)
 	case MESSAGE_RPC_FIND_NODE:
-		contactId := routing.NewKademliaID(msg.Body)
-		nodes := network.routingtable.FindClosestContacts(contactId, 20) // TODO: Get count value (20) from some parameter
+		contactId := routing.NewKademliaID(string(msg.Body))
+		nodes := network.routingtable.FindClosestContacts(contactId, network.k)
 
 		msg.Contacts = nodes
 		network.generateReturnMessage(msg)
 		network.sendResponse(senderAddr, *msg)
 	case MESSAGE_RPC_FIND_VALUE:
+		// The TTL is read before the value, as reading the value refreshes it
+		ttl, _ := network.datastore.TTL(msg.BodyDigest)
 		value, exists := network.datastore.Get(msg.BodyDigest)
+		network.logger.Debug("Find value", "peer", msg.Sender.String(), "key", msg.BodyDigest, "found", exists, "bytes", len(value))
+
+		// A missing value is sent as null, and an empty one as "", so the two
+		// are told apart
+		msg.Body = nil
 		if exists {
-			log.Printf("Data: %v (%s) found on node %s\n", value, string(value), msg.Target.String())
+			msg.Body = append([]byte{}, value...)
+		}
+		msg.PublicKey, _ = network.datastore.Publisher(msg.BodyDigest)
+		msg.TTL = ttl
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
+	case MESSAGE_RPC_STORE_RECORD:
+		err := network.storeRecord(msg.BodyDigest, msg.Body, limitTTL(msg.TTL))
+
+		// The body holds "true" on success, and the reason otherwise
+		if err == nil {
+			msg.Body = []byte(strconv.FormatBool(true))
+		} else {
+			network.logger.Info("Refused to store record", "peer", msg.Sender.String(), "key", msg.BodyDigest, "err", err)
+			msg.Body = []byte(err.Error())
+		}
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
+	case MESSAGE_RPC_FIND_RECORD:
+		ttl, _ := network.metadata.TTL(RecordStoreKey(msg.BodyDigest))
+		value, _ := network.metadata.Get(RecordStoreKey(msg.BodyDigest))
+
+		msg.Body = value
+		msg.TTL = ttl
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
+	case MESSAGE_RPC_ADD_PROVIDER:
+		provider, err := boundSender(*msg.Sender, senderAddr)
+		if err == nil {
+			err = network.addProvider(msg.BodyDigest, provider, limitTTL(msg.TTL), time.Now())
+		}
+
+		// The body holds "true" on success, and the reason otherwise
+		if err == nil {
+			msg.Body = []byte(strconv.FormatBool(true))
+		} else {
+			network.logger.Info("Refused to add provider", "peer", msg.Sender.String(), "key", msg.BodyDigest, "err", err)
+			msg.Body = []byte(err.Error())
+		}
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
+	case MESSAGE_RPC_GET_PROVIDERS:
+		msg.Contacts = network.getProviders(msg.BodyDigest, time.Now())
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
+	case MESSAGE_RPC_SUBSCRIBE:
+		subscriber, err := boundSender(*msg.Sender, senderAddr)
+		if err == nil {
+			err = network.addSubscriber(msg.BodyDigest, subscriber, limitTTL(msg.TTL), time.Now())
+		}
+
+		// The body holds "true" on success, and the reason otherwise
+		if err == nil {
+			msg.Body = []byte(strconv.FormatBool(true))
 		} else {
-			log.Printf("Data not found on node %s\n", msg.Target.String())
+			network.logger.Info("Refused to add subscriber", "peer", msg.Sender.String(), "key", msg.BodyDigest, "err", err)
+			msg.Body = []byte(err.Error())
+		}
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
+	case MESSAGE_RPC_UNSUBSCRIBE:
+		// Only the subscriber itself can unsubscribe
+		removed := false
+		if subscriber, err := boundSender(*msg.Sender, senderAddr); err == nil {
+			removed, _ = network.removeContactEntry(SubscriberStoreKey(msg.BodyDigest), subscriber, time.Now())
 		}
 
-		msg.Body = string(value)
+		// The body holds whether the sender was subscribed
+		msg.Body = []byte(strconv.FormatBool(removed))
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
+	case MESSAGE_RPC_PUBLISH:
+		_, err := decodePublicationOf(msg.BodyDigest, msg.Body)
+		if err == nil && !network.publications.allow(msg.BodyDigest, time.Now()) {
+			err = ErrPublicationRateLimit
+		}
+
+		// The subscribers are reached after responding, so the publisher does
+		// not wait on them. The body holds "true" if the publication is fanned
+		// out, and the reason otherwise.
+		if err == nil {
+			go network.fanOut(msg.BodyDigest, msg.Body)
+			msg.Body = []byte(strconv.FormatBool(true))
+		} else {
+			network.logger.Info("Refused to publish", "peer", msg.Sender.String(), "key", msg.BodyDigest, "err", err)
+			msg.Body = []byte(err.Error())
+		}
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
+	case MESSAGE_RPC_DELIVER:
+		subscribed := false
+		if pub, err := decodePublicationOf(msg.BodyDigest, msg.Body); err == nil {
+			subscribed = network.broker.Deliver(pub, time.Now())
+		}
+
+		// The body holds whether this node is subscribed to the topic
+		msg.Body = []byte(strconv.FormatBool(subscribed))
 		network.generateReturnMessage(msg)
 		network.sendResponse(senderAddr, *msg)
 	case MESSAGE_RPC_DATA_REFRESH:
 		keyToRefresh := msg.BodyDigest
-		network.datastore.Refresh(keyToRefresh)
+		refreshed := network.datastore.Refresh(keyToRefresh)
+
+		// The body holds whether the dataobject was held
+		msg.Body = []byte(strconv.FormatBool(refreshed))
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
 	case MESSAGE_RPC_DATA_FORGET:
-		keyToForget := msg.BodyDigest
-		network.datastore.Remove(keyToForget)
+		removed, err := network.forget(msg)
+
+		// The body holds whether the dataobject was held, and the reason
+		// otherwise
+		if err == nil {
+			msg.Body = []byte(strconv.FormatBool(removed))
+		} else {
+			network.logger.Warn("Refused to forget", "peer", msg.Sender.String(), "key", msg.BodyDigest, "err", err)
+			if errors.Is(err, ErrStaleForget) {
+				StaleForgets.Inc()
+			} else {
+				UnauthorizedForgets.Inc()
+			}
+			msg.Body = []byte(err.Error())
+		}
+		network.generateReturnMessage(msg)
+		network.sendResponse(senderAddr, *msg)
+	}
+}
+
+// Limit a requested TTL to the longest TTL the network allows
+func limitTTL(ttl time.Duration) time.Duration {
+	if ttl > NETWORK_MAX_TTL {
+		return NETWORK_MAX_TTL
 	}
+	return ttl
 }
 
 // Deserialize a byte array to a networkMessage
@@ -289,59 +618,76 @@ func serializeMessage(msg NetworkMessage) []byte {
 func (network *Network) generateReturnMessage(msg *NetworkMessage) {
 	returnContact := *msg.Sender
 	msg.Target = &returnContact
-	msg.Sender = network.me
+	msg.Sender = network.GetMe()
 	msg.RPC = MESSAGE_RESPONSE
 }
 
 func (network *Network) sendResponse(addr *net.UDPAddr, msg NetworkMessage) {
+	msg.ObservedAddress = addr.String()
 	msg_bytes := serializeMessage(msg)
-	_, err := network.incomingDataSocket.WriteToUDP(msg_bytes, addr)
+	written, err := network.incomingDataSocket.WriteToUDP(msg_bytes, addr)
+	BytesSent.Add(uint64(written))
 	if err != nil {
-		log.Printf("Send response error: %v\n", err)
+		network.logger.Warn("Send response error", "rpc", RPCName(msg.RPC), "peer", addr.String(), "err", err)
 	}
 }
 
 func (network *Network) sendRequest(recipient *net.UDPAddr, msg NetworkMessage, waitResponse bool) (*NetworkMessage, error) {
-	log.Printf("Message sent to %s\n", recipient.String())
+	network.logger.Debug("Message sent", "rpc", RPCName(msg.RPC), "peer", recipient.String())
 	conn, err := net.Dial("udp", recipient.String())
 	if err != nil {
-		log.Printf("UDP connection error: %v", err)
+		network.logger.Warn("UDP connection error", "rpc", RPCName(msg.RPC), "peer", recipient.String(), "err", err)
 		return nil, err
 	}
 	defer conn.Close()
 
 	// Send message
 	bytes := serializeMessage(msg)
-	_, err = conn.Write(bytes)
+	written, err := conn.Write(bytes)
+	BytesSent.Add(uint64(written))
 	if err != nil {
-		log.Printf("Send request error: %v\n", err)
+		network.logger.Warn("Send request error", "rpc", RPCName(msg.RPC), "peer", recipient.String(), "err", err)
 		return nil, err
 	}
+	network.counters.countSent(msg.RPC)
+	sent := time.Now()
 	if !waitResponse {
 		return nil, nil
 	}
 
 	// Wait for response
-	conn.SetReadDeadline(time.Now().Add(NETWORK_REQUEST_TIMEOUT))
+	conn.SetReadDeadline(time.Now().Add(network.requestTimeout))
 	response_buffer := make([]byte, NETWORK_INCOMING_BUFFER)
 	len, err := conn.Read(response_buffer)
+	BytesReceived.Add(uint64(len))
 	if err != nil {
-		log.Printf("UDP read error: %v\n", err)
-		if msg.Target.ID != nil {
-			network.routingtable.RemoveContact(msg.Target.ID)
-		}
+		network.logger.Debug("No response", "rpc", RPCName(msg.RPC), "peer", recipient.String(), "err", err)
+		network.counters.countTimeout()
 		return nil, err
 	}
 
 	// Deserialize response
 	response, err := deserializeMessage(response_buffer[:len])
 	if err != nil {
-		log.Printf("Deserialize error: %s\n", err)
+		network.logger.Warn("Deserialize error", "rpc", RPCName(msg.RPC), "peer", recipient.String(), "err", err)
+		DeserializeErrors.Inc()
 		return nil, err
 	}
 
+	network.counters.countResponse(time.Since(sent))
+	network.observeAddress(recipient, response)
+
 	// Add contact to routingtable
-	network.routingtable.AddContact(*response.Sender)
+	if network.sanitizeContact(response.Sender) {
+		network.routingtable.AddContact(*response.Sender)
+	}
+	contacts := response.Contacts[:0]
+	for _, contact := range response.Contacts {
+		if network.sanitizeContact(&contact) {
+			contacts = append(contacts, contact)
+		}
+	}
+	response.Contacts = contacts
 
 	return response, nil
 }
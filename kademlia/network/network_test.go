@@ -29,7 +29,7 @@ func TestSendMessageWithResponse_OnTimeout_TargetNodeShouldBeRemoved(t *testing.
 	targetNode := routing.NewContact(routing.NewRandomKademliaID(), ":14041")
 	networkA, _ := CreateTestNetwork(14041)
 	networkA.GetRoutingTable().AddContact(targetNode)
-	msgToSend := *networkA.NewNetworkMessage(MESSAGE_RPC_PING, networkA.GetMe(), &targetNode, "", "", nil)
+	msgToSend := *networkA.NewNetworkMessage(MESSAGE_RPC_PING, networkA.GetMe(), &targetNode, "", nil, nil)
 
 	_, timeout := networkA.SendMessageWithResponse(msgToSend)
 	nodesInRoutingTable := networkA.GetRoutingTable().Nodes()
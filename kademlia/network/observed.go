@@ -0,0 +1,140 @@
+package network
+
+import (
+	"net"
+	"sync"
+)
+
+// Every response carries the address of the requester as the responder saw
+// it. A node behind NAT sees its external address in them, and advertises it
+// once most of the peers that responded agree on it. Peers vote by the IP
+// they responded from, and all peers in one /24 or /48 share a vote.
+//
+// Requests are sent from a new socket each, so the port a responder sees is
+// not the one the node listens on. Only the IP is voted on, and the node
+// keeps advertising the port it listens on, which the NAT must forward.
+
+const (
+	// Fewest votes that must agree on an observed IP before it is advertised
+	NETWORK_OBSERVED_ADDRESS_MIN_VOTES = 3
+
+	// Most votes that are kept. When full, the oldest vote is dropped.
+	NETWORK_OBSERVED_ADDRESS_MAX_VOTES = 20
+)
+
+// The latest IP each network of peers observed this node at
+type addressVotes struct {
+	lock sync.Mutex
+	// Observed IP by voter, and the voters from oldest to newest vote
+	votes  map[string]string
+	voters []string
+}
+
+func newAddressVotes() *addressVotes {
+	return &addressVotes{votes: make(map[string]string)}
+}
+
+// Get the voter a responder at `ip` votes as: the /24 of an IPv4 address, or
+// the /48 of an IPv6 address. A peer cannot pick the IP it responds from as
+// it picks its ID, and peers in one network, which one party may run, share
+// a single vote.
+func voter(ip net.IP) string {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// Count the IP `peer` observed this node at, replacing its earlier vote.
+// Returns the IP that more than half of the peers, and at least
+// NETWORK_OBSERVED_ADDRESS_MIN_VOTES of them, observed, or "" if none did.
+func (votes *addressVotes) vote(peer string, ip string) string {
+	votes.lock.Lock()
+	defer votes.lock.Unlock()
+
+	if _, voted := votes.votes[peer]; voted {
+		for i, voter := range votes.voters {
+			if voter == peer {
+				votes.voters = append(votes.voters[:i], votes.voters[i+1:]...)
+				break
+			}
+		}
+	} else if len(votes.voters) == NETWORK_OBSERVED_ADDRESS_MAX_VOTES {
+		delete(votes.votes, votes.voters[0])
+		votes.voters = votes.voters[1:]
+	}
+	votes.votes[peer] = ip
+	votes.voters = append(votes.voters, peer)
+
+	counts := make(map[string]int)
+	for _, observed := range votes.votes {
+		counts[observed]++
+	}
+	for observed, count := range counts {
+		if count >= NETWORK_OBSERVED_ADDRESS_MIN_VOTES && count*2 > len(votes.votes) {
+			return observed
+		}
+	}
+	return ""
+}
+
+// Count the address the peer at `responder` observed this node at in its
+// response, and advertise the IP most networks of peers observed as the
+// address of this node, with the addresses it found itself as its other
+// addresses. Does nothing if the addresses to advertise were set with
+// Configure.
+//
+// The new addresses are set by storing a new contact, which concurrent
+// readers of the old one do not see change.
+func (network *Network) observeAddress(responder *net.UDPAddr, response *NetworkMessage) {
+	if network.fixedAddress || responder == nil {
+		return
+	}
+	host, _, err := net.SplitHostPort(response.ObservedAddress)
+	if err != nil || net.ParseIP(host) == nil {
+		return
+	}
+
+	ip := network.observedVotes.vote(voter(responder.IP), host)
+	if ip == "" {
+		return
+	}
+
+	network.addressLock.Lock()
+	defer network.addressLock.Unlock()
+
+	_, port, err := net.SplitHostPort(network.localAddresses[0])
+	if err != nil {
+		return
+	}
+	external := net.JoinHostPort(ip, port)
+	current := network.GetMe()
+	if external == current.Address {
+		return
+	}
+
+	me := *current
+	me.Addresses = []string{}
+	for _, address := range network.localAddresses {
+		if address == external {
+			// The node is not behind NAT after all
+			me.Address = network.localAddresses[0]
+			me.Addresses = append(me.Addresses, network.localAddresses[1:]...)
+			network.me.Store(&me)
+			return
+		}
+		me.Addresses = append(me.Addresses, address)
+	}
+	network.logger.Info("Detected external address", "address", external, "local", network.localAddresses[0])
+	me.Address = external
+	network.me.Store(&me)
+}
+
+// Remember the addresses of this node as they were found or configured, to
+// be advertised besides a detected external address
+func (network *Network) setLocalAddresses() {
+	network.addressLock.Lock()
+	defer network.addressLock.Unlock()
+
+	network.localAddresses = network.GetMe().AllAddresses()
+}
@@ -0,0 +1,128 @@
+package network
+
+import (
+	"d7024e/kademlia/network/routing"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressVotes_ShouldNeedMajorityOfMinimumVotes(t *testing.T) {
+	votes := newAddressVotes()
+
+	assert.Equal(t, "", votes.vote("peerA", "203.0.113.7"))
+	assert.Equal(t, "", votes.vote("peerB", "203.0.113.7"))
+	assert.Equal(t, "", votes.vote("peerA", "203.0.113.7"), "a peer only has one vote")
+	assert.Equal(t, "", votes.vote("peerC", "10.0.0.2"))
+	assert.Equal(t, "203.0.113.7", votes.vote("peerD", "203.0.113.7"))
+	assert.Equal(t, "203.0.113.7", votes.vote("peerE", "10.0.0.2"), "3 of 5 votes is still a majority")
+	assert.Equal(t, "", votes.vote("peerF", "10.0.0.2"), "3 of 6 votes is not")
+}
+
+func TestAddressVotes_WhenFull_ShouldDropOldestVote(t *testing.T) {
+	votes := newAddressVotes()
+	for i := 0; i < NETWORK_OBSERVED_ADDRESS_MAX_VOTES; i++ {
+		votes.vote(string(rune('a'+i)), "10.0.0.2")
+	}
+
+	votes.vote("new", "203.0.113.7")
+
+	assert.Len(t, votes.votes, NETWORK_OBSERVED_ADDRESS_MAX_VOTES)
+	assert.NotContains(t, votes.votes, "a")
+}
+
+// Stands in for a NAT in front of the requester: relays requests to `target`
+// and rewrites the address the target observed in its responses.
+func runAddressRewriter(t *testing.T, listen string, target string, observed string) {
+	conn, err := net.ListenPacket("udp", listen)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, NETWORK_INCOMING_BUFFER)
+		for {
+			n, requester, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("udp", target)
+			if err != nil {
+				return
+			}
+			upstream.Write(buf[:n])
+			upstream.SetReadDeadline(time.Now().Add(time.Second))
+			n, err = upstream.Read(buf)
+			upstream.Close()
+			if err != nil {
+				continue
+			}
+			response, err := deserializeMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			response.ObservedAddress = observed
+			conn.WriteTo(serializeMessage(*response), requester)
+		}
+	}()
+}
+
+func TestVoter_ShouldBeNetworkOfResponder(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", voter(net.ParseIP("203.0.113.7")))
+	assert.Equal(t, "203.0.113.0/24", voter(net.ParseIP("203.0.113.8")))
+	assert.Equal(t, "2001:db8:1::/48", voter(net.ParseIP("2001:db8:1:2::7")))
+}
+
+func TestObserveAddress_WhenPeersAgree_ShouldAdvertiseExternalAddress(t *testing.T) {
+	networkA, me := CreateTestNetwork(14050)
+	// The peers are reached on loopback, which only a node on loopback sends
+	// to, each from a network of its own
+	me.Address = "127.0.0.1:14050"
+	me.Addresses = nil
+	networkA.setLocalAddresses()
+	local := me.AllAddresses()
+	peers := []string{"127.0.1.1:14051", "127.0.2.1:14052", "127.0.3.1:14053"}
+	for i, address := range peers {
+		peer, _ := CreateTestNetwork(14054 + i)
+		go peer.Listen()
+		defer peer.StopListen()
+		runAddressRewriter(t, address, peer.listenAddress, "203.0.113.7:61000")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	for _, address := range peers {
+		target := routing.NewContact(nil, address)
+		msg := *networkA.NewNetworkMessage(MESSAGE_RPC_PING, me, &target, "", nil, nil)
+		_, timeout := networkA.SendMessageWithResponse(msg)
+		assert.False(t, timeout)
+	}
+
+	_, port, _ := net.SplitHostPort(local[0])
+	assert.Equal(t, "203.0.113.7:"+port, networkA.GetMe().Address)
+	assert.Equal(t, local, networkA.GetMe().Addresses)
+	assert.Equal(t, local[0], me.Address, "a contact that was read does not change")
+}
+
+func TestObserveAddress_WhenPeersShareNetwork_ShouldCountOneVote(t *testing.T) {
+	network, me := CreateTestNetwork(14050)
+	address := me.Address
+
+	for _, responder := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		network.observeAddress(&net.UDPAddr{IP: net.ParseIP(responder), Port: 14051}, &NetworkMessage{ObservedAddress: "203.0.113.7:61000"})
+	}
+
+	assert.Equal(t, address, network.GetMe().Address)
+}
+
+func TestObserveAddress_WithAdvertise_ShouldKeepAddress(t *testing.T) {
+	network, me := CreateTestNetwork(14050)
+	network.fixedAddress = true
+	address := me.Address
+
+	for _, responder := range []string{"198.51.100.1", "198.51.101.1", "198.51.102.1"} {
+		network.observeAddress(&net.UDPAddr{IP: net.ParseIP(responder), Port: 14051}, &NetworkMessage{ObservedAddress: "203.0.113.7:61000"})
+	}
+
+	assert.Equal(t, address, network.GetMe().Address)
+}
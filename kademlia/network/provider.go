@@ -0,0 +1,37 @@
+package network
+
+import (
+	"d7024e/kademlia/network/routing"
+	"time"
+)
+
+const (
+	// Prefix of the key a provider list is kept under in the metadata store
+	NETWORK_PROVIDER_KEY_PREFIX = "providers:"
+
+	// Most providers kept for one key. When full, the provider that expires
+	// soonest makes room for a new one.
+	NETWORK_MAX_PROVIDERS = 20
+
+	// How long a provider is kept if the announcement does not say
+	NETWORK_DEFAULT_PROVIDER_TTL = time.Hour
+)
+
+// Get the metadata store key of the providers of the given key
+func ProviderStoreKey(key string) string {
+	return NETWORK_PROVIDER_KEY_PREFIX + key
+}
+
+// Add `contact` as a provider of `key` for `ttl`, or the default provider TTL
+// if zero. Announcing again only changes when the provider expires.
+func (network *Network) addProvider(key string, contact routing.Contact, ttl time.Duration, now time.Time) error {
+	if ttl <= 0 {
+		ttl = NETWORK_DEFAULT_PROVIDER_TTL
+	}
+	return network.addContactEntry(ProviderStoreKey(key), contact, ttl, NETWORK_MAX_PROVIDERS, now)
+}
+
+// Get the providers of `key` that have not expired
+func (network *Network) getProviders(key string, now time.Time) []routing.Contact {
+	return network.getContactEntries(ProviderStoreKey(key), now)
+}
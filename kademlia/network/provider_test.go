@@ -0,0 +1,74 @@
+package network
+
+import (
+	"d7024e/kademlia/network/routing"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddProvider_ShouldDropExpiredProviders(t *testing.T) {
+	network, _ := CreateTestNetwork(14041)
+	now := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	providerA := routing.NewContact(routing.NewRandomKademliaID(), "providerA")
+	providerB := routing.NewContact(routing.NewRandomKademliaID(), "providerB")
+
+	network.addProvider("key", providerA, time.Minute, now)
+	network.addProvider("key", providerB, time.Hour, now)
+	network.addProvider("key", providerA, time.Minute, now)
+
+	assert.Equal(t, 2, len(network.getProviders("key", now)))
+	assert.Equal(t, []routing.Contact{providerB}, network.getProviders("key", now.Add(2*time.Minute)))
+	assert.Empty(t, network.getProviders("other", now))
+}
+
+func TestAddProvider_WhenFull_ShouldDropProviderThatExpiresSoonest(t *testing.T) {
+	network, _ := CreateTestNetwork(14041)
+	now := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	soonest := routing.NewContact(routing.NewRandomKademliaID(), "soonest")
+
+	network.addProvider("key", soonest, time.Minute, now)
+	for i := 0; i < NETWORK_MAX_PROVIDERS; i++ {
+		provider := routing.NewContact(routing.NewRandomKademliaID(), fmt.Sprintf("provider%d", i))
+		network.addProvider("key", provider, time.Hour, now)
+	}
+	providers := network.getProviders("key", now)
+
+	assert.Equal(t, NETWORK_MAX_PROVIDERS, len(providers))
+	assert.NotContains(t, providers, soonest)
+}
+
+func TestBoundSender(t *testing.T) {
+	id := routing.NewRandomKademliaID()
+	var tests = []struct {
+		name      string
+		addresses []string
+		from      string
+		expected  []string
+	}{
+		{"contact address", []string{"10.0.0.1:14041"}, "10.0.0.1", []string{"10.0.0.1:14041"}},
+		{"other address", []string{"10.0.0.1:14041", "[2001:db8::1]:14041"}, "2001:db8::1", []string{"[2001:db8::1]:14041"}},
+		{"IPv4 in IPv6", []string{"10.0.0.1:14041"}, "::ffff:10.0.0.1", []string{"10.0.0.1:14041"}},
+		{"not advertised", []string{"10.0.0.1:14041"}, "10.0.0.2", nil},
+		{"host name", []string{"node:14041"}, "10.0.0.1", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sender := routing.NewContact(id, test.addresses[0])
+			sender.Addresses = test.addresses[1:]
+
+			actual, err := boundSender(sender, &net.UDPAddr{IP: net.ParseIP(test.from), Port: 50000})
+
+			if test.expected == nil {
+				assert.ErrorIs(t, err, ErrSenderNotBound)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, test.expected, actual.AllAddresses())
+			}
+		})
+	}
+}
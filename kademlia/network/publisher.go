@@ -0,0 +1,60 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	// How far the time a STORE was signed may be from the clock of the node
+	// that receives it. Older STOREs are refused, so a captured one cannot be
+	// replayed once the tombstone of the value expires.
+	NETWORK_STORE_MAX_AGE = time.Minute
+)
+
+var (
+	ErrUnsignedStore = errors.New("store is not signed by the publisher it names")
+	ErrStaleStore    = errors.New("store was signed too long ago")
+)
+
+// Sign a STORE message with the key of the publisher of the dataobject, so
+// the node that stores it can record the publisher.
+//
+// The public key, the time of signing and the signature of the key, TTL and
+// time are sent along with it. The TTL must be set before signing.
+func SignStore(msg *NetworkMessage, key ed25519.PrivateKey, now time.Time) {
+	msg.SignedAt = now.UnixNano()
+	msg.PublicKey = key.Public().(ed25519.PublicKey)
+	msg.Signature = ed25519.Sign(key, storePayload(msg.BodyDigest, msg.TTL, msg.SignedAt))
+}
+
+// Get the publisher of a STORE message, if it is recently signed by the key
+// it carries. Returns ErrUnsignedStore if the message names no publisher or
+// the signature does not match its key, and ErrStaleStore if it was signed
+// too long ago.
+func verifyStore(msg *NetworkMessage, now time.Time) (ed25519.PublicKey, error) {
+	if len(msg.PublicKey) != ed25519.PublicKeySize ||
+		!ed25519.Verify(msg.PublicKey, storePayload(msg.BodyDigest, msg.TTL, msg.SignedAt), msg.Signature) {
+		return nil, ErrUnsignedStore
+	}
+
+	signed := time.Unix(0, msg.SignedAt)
+	if age := now.Sub(signed); age > NETWORK_STORE_MAX_AGE || age < -NETWORK_STORE_MAX_AGE {
+		return nil, ErrStaleStore
+	}
+	return msg.PublicKey, nil
+}
+
+// The bytes signed to publish the dataobject with the given key and TTL at
+// the given time
+func storePayload(key string, ttl time.Duration, signed int64) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("kademlia-store:")
+	buf.WriteString(key)
+	binary.Write(&buf, binary.BigEndian, int64(ttl))
+	binary.Write(&buf, binary.BigEndian, signed)
+	return buf.Bytes()
+}
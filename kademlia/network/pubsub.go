@@ -0,0 +1,309 @@
+package network
+
+import (
+	"crypto/rand"
+	"d7024e/kademlia/network/routing"
+	"d7024e/logging"
+	"d7024e/util"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Subscribers of a topic are kept in the metadata store under the key of
+	// the topic with this prefix
+	NETWORK_SUBSCRIBER_KEY_PREFIX = "subscribers:"
+
+	// Most subscribers kept for one topic. When full, the subscriber that
+	// expires soonest makes room for a new one.
+	NETWORK_MAX_SUBSCRIBERS = 100
+
+	// How long a subscriber is kept if the subscription does not say.
+	// Subscribers renew their subscription well before it expires.
+	NETWORK_DEFAULT_SUBSCRIPTION_TTL = 10 * time.Minute
+
+	// Publications waiting to be read by a subscription. When full, new
+	// publications are dropped for that subscription.
+	NETWORK_SUBSCRIPTION_BUFFER = 64
+
+	// How long the ID of a delivered publication is remembered. Every node
+	// that keeps the subscribers of a topic fans a publication out, so the
+	// copies that arrive within this time are dropped.
+	NETWORK_PUBLICATION_DEDUP_WINDOW = time.Minute
+
+	// Largest data of a publication, in bytes
+	NETWORK_MAX_PUBLICATION_SIZE = 8 * 1024
+
+	// Most publications to one topic a node fans out per window. Others are
+	// refused until the window has passed.
+	NETWORK_MAX_PUBLICATIONS_PER_WINDOW = 60
+	NETWORK_PUBLICATION_RATE_WINDOW     = time.Minute
+)
+
+var (
+	ErrTopicMismatch        = errors.New("publication does not match topic")
+	ErrPublicationTooLarge  = errors.New("publication exceeds maximum size")
+	ErrPublicationRateLimit = errors.New("too many publications to topic")
+)
+
+// A message published to a topic
+type Publication struct {
+	// Random ID that tells copies of the same publication apart
+	ID    string
+	Topic string
+	Data  []byte
+}
+
+// Create a publication of `data` to `topic` with a new random ID
+func NewPublication(topic string, data []byte) Publication {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return Publication{ID: hex.EncodeToString(id), Topic: topic, Data: data}
+}
+
+// Encode a publication to be sent in the body of a network message
+func EncodePublication(pub Publication) []byte {
+	data, _ := json.Marshal(pub)
+	return data
+}
+
+// Decode a publication from the body of a network message
+func DecodePublication(data []byte) (Publication, error) {
+	var pub Publication
+	err := json.Unmarshal(data, &pub)
+	return pub, err
+}
+
+// Get the key of a topic, which decides the nodes that keep its subscribers
+func TopicKey(topic string) string {
+	return util.Hash([]byte(topic))
+}
+
+// Get the metadata store key of the subscribers of the topic with the given key
+func SubscriberStoreKey(key string) string {
+	return NETWORK_SUBSCRIBER_KEY_PREFIX + key
+}
+
+// A subscription of this node to a topic. Publications to the topic are
+// received on C until the subscription is closed.
+type Subscription struct {
+	Topic string
+	C     <-chan Publication
+	c     chan Publication
+}
+
+// Delivers publications that reach this node to its local subscriptions
+type Broker struct {
+	lock   sync.Mutex
+	topics map[string]*brokerTopic
+	seen   map[string]time.Time
+	logger *logging.Logger
+}
+
+type brokerTopic struct {
+	subscriptions []*Subscription
+	// Closed when the last subscription to the topic is closed
+	done chan struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		topics: make(map[string]*brokerTopic),
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Open a subscription to `topic`. `first` is true if no other subscription
+// to the topic was open, i.e. the node is not yet subscribed to it.
+func (broker *Broker) Subscribe(topic string) (sub *Subscription, first bool) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	c := make(chan Publication, NETWORK_SUBSCRIPTION_BUFFER)
+	sub = &Subscription{Topic: topic, C: c, c: c}
+
+	entry, exists := broker.topics[topic]
+	if !exists {
+		entry = &brokerTopic{done: make(chan struct{})}
+		broker.topics[topic] = entry
+	}
+	entry.subscriptions = append(entry.subscriptions, sub)
+	return sub, !exists
+}
+
+// Close a subscription, which closes its channel. `last` is true if it was
+// the last open subscription to its topic.
+func (broker *Broker) Unsubscribe(sub *Subscription) (last bool) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	entry, exists := broker.topics[sub.Topic]
+	if !exists {
+		return false
+	}
+	for i, open := range entry.subscriptions {
+		if open == sub {
+			entry.subscriptions = append(entry.subscriptions[:i], entry.subscriptions[i+1:]...)
+			close(sub.c)
+			break
+		}
+	}
+	if len(entry.subscriptions) > 0 {
+		return false
+	}
+	delete(broker.topics, sub.Topic)
+	close(entry.done)
+	return true
+}
+
+// Get a channel that is closed when the last subscription to `topic` is
+// closed. The channel is already closed if there is none.
+func (broker *Broker) Done(topic string) <-chan struct{} {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	if entry, exists := broker.topics[topic]; exists {
+		return entry.done
+	}
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// Check if any subscription to `topic` is open
+func (broker *Broker) IsSubscribed(topic string) bool {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	_, exists := broker.topics[topic]
+	return exists
+}
+
+// Deliver a publication to every open subscription to its topic, unless a
+// copy of it has been delivered already.
+//
+// Returns whether any subscription to the topic is open.
+func (broker *Broker) Deliver(pub Publication, now time.Time) bool {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	for id, delivered := range broker.seen {
+		if now.Sub(delivered) > NETWORK_PUBLICATION_DEDUP_WINDOW {
+			delete(broker.seen, id)
+		}
+	}
+
+	entry, exists := broker.topics[pub.Topic]
+	if !exists {
+		return false
+	}
+	if _, seen := broker.seen[pub.ID]; seen {
+		return true
+	}
+	broker.seen[pub.ID] = now
+
+	for _, sub := range entry.subscriptions {
+		select {
+		case sub.c <- pub:
+		default:
+			broker.logger.Warn("Dropped publication: subscription is full", "publication", pub.ID, "topic", pub.Topic)
+		}
+	}
+	return true
+}
+
+// Add `contact` as a subscriber of the topic with the given key for `ttl`, or
+// the default subscription TTL if zero
+func (network *Network) addSubscriber(key string, contact routing.Contact, ttl time.Duration, now time.Time) error {
+	if ttl <= 0 {
+		ttl = NETWORK_DEFAULT_SUBSCRIPTION_TTL
+	}
+	return network.addContactEntry(SubscriberStoreKey(key), contact, ttl, NETWORK_MAX_SUBSCRIBERS, now)
+}
+
+// Get the subscribers of the topic with the given key that have not expired
+func (network *Network) getSubscribers(key string, now time.Time) []routing.Contact {
+	return network.getContactEntries(SubscriberStoreKey(key), now)
+}
+
+// Send a publication to every subscriber of its topic. Subscribers that do
+// not respond, or are no longer subscribed, are dropped.
+func (network *Network) fanOut(key string, body []byte) {
+	subscribers := network.getSubscribers(key, time.Now())
+
+	var wg sync.WaitGroup
+	for i := range subscribers {
+		wg.Add(1)
+		go func(subscriber *routing.Contact) {
+			defer wg.Done()
+			msg := network.NewNetworkMessage(MESSAGE_RPC_DELIVER, network.GetMe(), subscriber, key, body, nil)
+
+			response, timeout := network.SendMessageWithResponse(*msg)
+
+			if subscribed, _ := strconv.ParseBool(string(response.Body)); timeout || !subscribed {
+				network.logger.Info("Dropped subscriber", "peer", subscriber.String(), "key", key)
+				network.removeContactEntry(SubscriberStoreKey(key), *subscriber, time.Now())
+			}
+		}(&subscribers[i])
+	}
+	wg.Wait()
+}
+
+// Check that a published message is a publication to the topic with the
+// given key, of at most the maximum size
+func decodePublicationOf(key string, body []byte) (Publication, error) {
+	pub, err := DecodePublication(body)
+	if err != nil {
+		return pub, err
+	}
+	if TopicKey(pub.Topic) != key {
+		return pub, ErrTopicMismatch
+	}
+	if len(pub.Data) > NETWORK_MAX_PUBLICATION_SIZE {
+		return pub, ErrPublicationTooLarge
+	}
+	return pub, nil
+}
+
+// Publications fanned out to each topic in its current window
+type publicationLimiter struct {
+	lock    sync.Mutex
+	windows map[string]*publicationWindow
+}
+
+type publicationWindow struct {
+	start time.Time
+	count int
+}
+
+func newPublicationLimiter() *publicationLimiter {
+	return &publicationLimiter{windows: make(map[string]*publicationWindow)}
+}
+
+// Count a publication to the topic with the given key. Returns false if the
+// topic has had its most publications in the current window.
+func (limiter *publicationLimiter) allow(key string, now time.Time) bool {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+
+	for topic, window := range limiter.windows {
+		if now.Sub(window.start) >= NETWORK_PUBLICATION_RATE_WINDOW {
+			delete(limiter.windows, topic)
+		}
+	}
+
+	window, exists := limiter.windows[key]
+	if !exists {
+		window = &publicationWindow{start: now}
+		limiter.windows[key] = window
+	}
+	if window.count == NETWORK_MAX_PUBLICATIONS_PER_WINDOW {
+		return false
+	}
+	window.count++
+	return true
+}
@@ -0,0 +1,78 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Deliver_ShouldDeliverToEverySubscriptionOnce(t *testing.T) {
+	broker := NewBroker()
+	now := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	subA, firstA := broker.Subscribe("news")
+	subB, firstB := broker.Subscribe("news")
+	pub := NewPublication("news", []byte("hello"))
+
+	delivered := broker.Deliver(pub, now)
+	deliveredCopy := broker.Deliver(pub, now)
+	deliveredOther := broker.Deliver(NewPublication("other", []byte("hello")), now)
+
+	assert.True(t, firstA)
+	assert.False(t, firstB)
+	assert.True(t, delivered)
+	assert.True(t, deliveredCopy)
+	assert.False(t, deliveredOther)
+	assert.Equal(t, 1, len(subA.C))
+	assert.Equal(t, 1, len(subB.C))
+	assert.Equal(t, pub, <-subA.C)
+}
+
+func TestBroker_Deliver_AfterDedupWindow_ShouldDeliverAgain(t *testing.T) {
+	broker := NewBroker()
+	now := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	sub, _ := broker.Subscribe("news")
+	pub := NewPublication("news", []byte("hello"))
+
+	broker.Deliver(pub, now)
+	broker.Deliver(pub, now.Add(NETWORK_PUBLICATION_DEDUP_WINDOW+time.Second))
+
+	assert.Equal(t, 2, len(sub.C))
+}
+
+func TestBroker_Unsubscribe_ShouldCloseTopicWithLastSubscription(t *testing.T) {
+	broker := NewBroker()
+	subA, _ := broker.Subscribe("news")
+	subB, _ := broker.Subscribe("news")
+	done := broker.Done("news")
+
+	lastA := broker.Unsubscribe(subA)
+	subscribed := broker.IsSubscribed("news")
+	lastB := broker.Unsubscribe(subB)
+	_, open := <-subB.C
+
+	assert.False(t, lastA)
+	assert.True(t, subscribed)
+	assert.True(t, lastB)
+	assert.False(t, open)
+	assert.False(t, broker.IsSubscribed("news"))
+	assert.False(t, broker.Deliver(NewPublication("news", []byte("hello")), time.Now()))
+	select {
+	case <-done:
+	default:
+		t.Fatal("done was not closed")
+	}
+}
+
+func TestPublicationLimiter_ShouldLimitEachTopicPerWindow(t *testing.T) {
+	limiter := newPublicationLimiter()
+	now := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < NETWORK_MAX_PUBLICATIONS_PER_WINDOW; i++ {
+		limiter.allow("news", now)
+	}
+
+	assert.False(t, limiter.allow("news", now.Add(time.Second)))
+	assert.True(t, limiter.allow("other", now.Add(time.Second)))
+	assert.True(t, limiter.allow("news", now.Add(NETWORK_PUBLICATION_RATE_WINDOW)))
+}
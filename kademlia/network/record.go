@@ -0,0 +1,40 @@
+package network
+
+import (
+	"bytes"
+	"d7024e/kademlia/datastore"
+	"d7024e/kademlia/record"
+	"errors"
+	"time"
+)
+
+// Records are kept in the metadata store under their key with this prefix
+const NETWORK_RECORD_KEY_PREFIX = "record:"
+
+var ErrStaleRecord = errors.New("record is not newer than the stored record")
+
+// Get the metadata store key of the record with the given key
+func RecordStoreKey(key string) string {
+	return NETWORK_RECORD_KEY_PREFIX + key
+}
+
+// Store a record if it is validly signed for `key` and newer than the record
+// already stored. Storing the same record again only refreshes its TTL.
+func (network *Network) storeRecord(key string, data []byte, ttl time.Duration) error {
+	newRecord, err := record.Decode(data)
+	if err != nil {
+		return err
+	}
+	if err := newRecord.VerifyKey(key); err != nil {
+		return err
+	}
+
+	err = network.metadata.Update(RecordStoreKey(key), data, ttl, func(current []byte) bool {
+		currentRecord, err := record.Decode(current)
+		return err != nil || newRecord.NewerThan(currentRecord) || bytes.Equal(current, data)
+	})
+	if errors.Is(err, datastore.ErrUpdateRejected) {
+		return ErrStaleRecord
+	}
+	return err
+}
@@ -8,11 +8,12 @@ import (
 // contains a List
 type bucket struct {
 	list *list.List
+	size int
 }
 
-// newBucket returns a new instance of a bucket
-func newBucket() *bucket {
-	bucket := &bucket{}
+// newBucket returns a new instance of a bucket that keeps at most size contacts
+func newBucket(size int) *bucket {
+	bucket := &bucket{size: size}
 	bucket.list = list.New()
 	return bucket
 }
@@ -30,7 +31,7 @@ func (bucket *bucket) AddContact(contact Contact) {
 	}
 
 	if element == nil {
-		if bucket.list.Len() < bucketSize {
+		if bucket.list.Len() < bucket.size {
 			bucket.list.PushFront(contact)
 		}
 	} else {
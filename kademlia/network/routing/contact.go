@@ -8,14 +8,28 @@ import (
 // Contact definition
 // stores the KademliaID, the ip address and the distance
 type Contact struct {
-	ID       *KademliaID
-	Address  string
-	Distance *KademliaID
+	ID      *KademliaID
+	Address string
+	// Other addresses the node can be reached at, e.g. of the other IP family
+	Addresses []string `json:",omitempty"`
+	Distance  *KademliaID
 }
 
 // NewContact returns a new instance of a Contact
 func NewContact(id *KademliaID, address string) Contact {
-	return Contact{id, address, nil}
+	return Contact{ID: id, Address: address}
+}
+
+// AllAddresses returns the address of the Contact followed by its other
+// addresses
+func (contact *Contact) AllAddresses() []string {
+	addresses := []string{contact.Address}
+	for _, address := range contact.Addresses {
+		if address != contact.Address {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
 }
 
 // CalcDistance calculates the distance to the target and
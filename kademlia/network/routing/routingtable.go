@@ -1,7 +1,5 @@
 package routing
 
-const bucketSize = 20
-
 type IRoutingTable interface {
 	// AddContact add a new contact to the correct Bucket. Contact will not be added if it is me
 	AddContact(contact Contact)
@@ -17,6 +15,9 @@ type IRoutingTable interface {
 
 	// Get all nodes in the RoutingTable
 	Nodes() []Contact
+
+	// Get the number of contacts in each Bucket, by Bucket index
+	BucketLengths() []int
 }
 
 // RoutingTable definition
@@ -26,11 +27,12 @@ type RoutingTable struct {
 	buckets [IDLength * 8]*bucket
 }
 
-// NewRoutingTable returns a new instance of a RoutingTable
-func NewRoutingTable(me Contact) *RoutingTable {
+// NewRoutingTable returns a new instance of a RoutingTable that keeps at most
+// bucketSize contacts in each Bucket
+func NewRoutingTable(me Contact, bucketSize int) *RoutingTable {
 	routingTable := &RoutingTable{}
 	for i := 0; i < IDLength*8; i++ {
-		routingTable.buckets[i] = newBucket()
+		routingTable.buckets[i] = newBucket(bucketSize)
 	}
 	routingTable.me = me
 	return routingTable
@@ -110,3 +112,11 @@ func (routingTable *RoutingTable) Nodes() []Contact {
 	}
 	return contacts
 }
+
+func (routingTable *RoutingTable) BucketLengths() []int {
+	lengths := make([]int, len(routingTable.buckets))
+	for i, bucket := range routingTable.buckets {
+		lengths[i] = bucket.Len()
+	}
+	return lengths
+}
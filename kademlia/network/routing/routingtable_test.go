@@ -8,7 +8,7 @@ import (
 )
 
 func TestRoutingTable(t *testing.T) {
-	rt := NewRoutingTable(NewContact(NewKademliaID("FFFFFFFF00000000000000000000000000000000"), "localhost:8000"))
+	rt := NewRoutingTable(NewContact(NewKademliaID("FFFFFFFF00000000000000000000000000000000"), "localhost:8000"), 20)
 
 	rt.AddContact(NewContact(NewKademliaID("FFFFFFFF00000000000000000000000000000000"), "localhost:8001"))
 	rt.AddContact(NewContact(NewKademliaID("1111111100000000000000000000000000000000"), "localhost:8002"))
@@ -28,7 +28,7 @@ func TestAddMeContact(t *testing.T) {
 	t.Run(testname, func(t *testing.T) {
 		expected := 0
 		me := NewContact(NewRandomKademliaID(), "")
-		rt := NewRoutingTable(me)
+		rt := NewRoutingTable(me, 20)
 
 		rt.AddContact(me)
 
@@ -48,7 +48,7 @@ func TestRemoveContact(t *testing.T) {
 	}
 	contactToRemove := NewContact(NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
 	contactsToAdd := append(expectedContacts, contactToRemove)
-	rt := NewRoutingTable(NewContact(NewKademliaID("ABC0000000000000000000000000000000000000"), "me"))
+	rt := NewRoutingTable(NewContact(NewKademliaID("ABC0000000000000000000000000000000000000"), "me"), 20)
 	for _, contact := range contactsToAdd {
 		rt.AddContact(contact)
 	}
@@ -70,7 +70,7 @@ func TestNodes(t *testing.T) {
 
 	expected := []Contact{nodeD, nodeC, nodeB, nodeA}
 
-	rt := NewRoutingTable(me)
+	rt := NewRoutingTable(me, 20)
 	rt.AddContact(nodeA)
 	rt.AddContact(nodeB)
 	rt.AddContact(nodeC)
@@ -87,8 +87,38 @@ func TestNodesWhenEmpty(t *testing.T) {
 
 	expectedNodesLen := 0
 
-	rt := NewRoutingTable(me)
+	rt := NewRoutingTable(me, 20)
 	actualNodesLen := len(rt.Nodes())
 
 	assert.Equal(t, expectedNodesLen, actualNodesLen)
 }
+
+func TestBucketLengths(t *testing.T) {
+	me := NewContact(NewKademliaID("0000000000000000000000000000000000000000"), "me")
+	nodeA := NewContact(NewKademliaID("8000000000000000000000000000000000000000"), "nodeA")
+	nodeB := NewContact(NewKademliaID("4000000000000000000000000000000000000000"), "nodeB")
+	nodeC := NewContact(NewKademliaID("6000000000000000000000000000000000000000"), "nodeC")
+
+	rt := NewRoutingTable(me, 20)
+	rt.AddContact(nodeA)
+	rt.AddContact(nodeB)
+	rt.AddContact(nodeC)
+	lengths := rt.BucketLengths()
+
+	assert.Equal(t, IDLength*8, len(lengths))
+	assert.Equal(t, 1, lengths[0])
+	assert.Equal(t, 2, lengths[1])
+	assert.Equal(t, 0, lengths[2])
+}
+
+func TestAddContact_WhenBucketIsFull_ShouldNotAdd(t *testing.T) {
+	me := NewContact(NewKademliaID("0000000000000000000000000000000000000000"), "me")
+	nodeA := NewContact(NewKademliaID("8000000000000000000000000000000000000000"), "nodeA")
+	nodeB := NewContact(NewKademliaID("C000000000000000000000000000000000000000"), "nodeB")
+
+	rt := NewRoutingTable(me, 1)
+	rt.AddContact(nodeA)
+	rt.AddContact(nodeB)
+
+	assert.Equal(t, []Contact{nodeA}, rt.Nodes())
+}
@@ -1,13 +1,44 @@
 package rpc
 
 import (
+	"crypto/ed25519"
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
+	"fmt"
+	"strconv"
+	"time"
 )
 
+// The outcome of forgetting a dataobject on one contact
+type ForgetResponse struct {
+	Contact routing.Contact
+	// Whether the contact held the dataobject and removed it
+	Removed bool
+	// Why the contact refused to forget the dataobject, or did not respond
+	Err error
+}
+
 // Sends a message to the specified contact, instructing it to forget a
 // dataobject with the hash value.
-func SendForgetDataMessage(net network.INetwork, node *routing.Contact, hash string) {
-	msg := net.NewNetworkMessage(network.MESSAGE_RPC_DATA_FORGET, net.GetMe(), node, hash, "", nil)
-	net.SendMessage(*msg)
+//
+// The message is signed with `key`, and the contact only forgets the
+// dataobject if it is the key of its publisher.
+//
+// Returns whether the contact held the dataobject and removed it. Otherwise,
+// an error with the reason the contact refused to forget it, or ErrTimeout if
+// it did not respond.
+func SendForgetDataMessage(net network.INetwork, node *routing.Contact, hash string, key ed25519.PrivateKey) (bool, error) {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_DATA_FORGET, net.GetMe(), node, hash, nil, nil)
+	network.SignForget(msg, key, time.Now())
+
+	response, timeout := net.SendMessageWithResponse(*msg)
+
+	if timeout {
+		net.GetLogger().Info("Forget timeout", "rpc", "DATA_FORGET", "peer", node.String())
+		return false, ErrTimeout
+	}
+	if removed, err := strconv.ParseBool(string(response.Body)); err == nil {
+		return removed, nil
+	}
+	return false, fmt.Errorf("forget refused: %s", response.Body)
 }
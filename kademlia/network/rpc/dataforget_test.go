@@ -1,8 +1,11 @@
 package rpc
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"d7024e/kademlia/datastore"
 	"d7024e/kademlia/network"
+	"d7024e/metrics"
 	"d7024e/util"
 	"testing"
 	"time"
@@ -13,6 +16,7 @@ import (
 func TestSendForgetDataMessage(t *testing.T) {
 	dataTTL := time.Hour
 	dataKey := "key"
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
 	timeprovider := new(util.TimeProvider)
 	datastore := datastore.NewDataStore(dataTTL, nil, timeprovider)
 	networkB, _ := network.NewNetwork(14048, datastore)
@@ -25,13 +29,202 @@ func TestSendForgetDataMessage(t *testing.T) {
 
 	time.Sleep(20 * time.Millisecond)
 
-	networkB.GetDatastore().Set(dataKey, []byte("test"))
+	networkB.GetDatastore().SetPublished(dataKey, []byte("test"), 0, publicKey)
 
-	SendForgetDataMessage(networkA, networkB.GetMe(), dataKey)
-
-	time.Sleep(20 * time.Millisecond)
+	removed, err := SendForgetDataMessage(networkA, networkB.GetMe(), dataKey, privateKey)
+	removedAgain, errAgain := SendForgetDataMessage(networkA, networkB.GetMe(), dataKey, privateKey)
 
 	_, actualExists := datastore.Get(dataKey)
 
 	assert.False(t, actualExists)
+	assert.True(t, removed)
+	assert.Nil(t, err)
+	assert.False(t, removedAgain)
+	assert.Nil(t, errAgain)
+}
+
+func TestSendForgetDataMessage_WhenRefused_ShouldReturnReason(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	networkB.GetDatastore().SetPublished("key", []byte("test"), 0, publicKey)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	removed, err := SendForgetDataMessage(networkA, networkB.GetMe(), "key", otherKey)
+
+	assert.False(t, removed)
+	assert.ErrorContains(t, err, network.ErrUnauthorizedForget.Error())
+}
+
+func TestSendForgetDataMessage_OnTimeout_ShouldReturnErrTimeout(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+
+	go networkA.Listen()
+	defer networkA.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	_, err := SendForgetDataMessage(networkA, networkB.GetMe(), "key", privateKey)
+
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+func TestSendForgetDataMessage_WhenNotSignedByPublisher_ShouldKeepData(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	signedWithOtherKey := func(msg *network.NetworkMessage) {
+		network.SignForget(msg, otherKey, time.Now())
+	}
+	signedLongAgo := func(msg *network.NetworkMessage) {
+		network.SignForget(msg, privateKey, time.Now().Add(-time.Hour))
+	}
+	unsigned := func(msg *network.NetworkMessage) {}
+	var tests = []struct {
+		name      string
+		publisher ed25519.PublicKey
+		sign      func(msg *network.NetworkMessage)
+		refused   *metrics.Counter
+	}{
+		{"other key", publicKey, signedWithOtherKey, network.UnauthorizedForgets},
+		{"signed long ago", publicKey, signedLongAgo, network.StaleForgets},
+		{"unsigned", publicKey, unsigned, network.UnauthorizedForgets},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			networkA, _ := network.CreateTestNetwork(14041)
+			networkB, _ := network.CreateTestNetwork(14048)
+			networkB.GetDatastore().SetPublished("key", []byte("test"), 0, test.publisher)
+			refusedBefore := test.refused.Value()
+
+			go networkA.Listen()
+			go networkB.Listen()
+			defer networkA.StopListen()
+			defer networkB.StopListen()
+			time.Sleep(20 * time.Millisecond)
+
+			msg := networkA.NewNetworkMessage(network.MESSAGE_RPC_DATA_FORGET, networkA.GetMe(), networkB.GetMe(), "key", nil, nil)
+			test.sign(msg)
+			networkA.SendMessage(*msg)
+			time.Sleep(20 * time.Millisecond)
+
+			_, exists := networkB.GetDatastore().Get("key")
+
+			assert.True(t, exists)
+			assert.Equal(t, refusedBefore+1, test.refused.Value())
+		})
+	}
+}
+
+func TestSendForgetDataMessage_WhenCopyHasNoPublisher_ShouldRemove(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	networkB.GetDatastore().SetCached("key", []byte("test"), 0, nil)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	removed, err := SendForgetDataMessage(networkA, networkB.GetMe(), "key", privateKey)
+
+	_, exists := networkB.GetDatastore().Get("key")
+	_, tombstone := networkB.GetMetadataStore().TTL(network.TombstoneStoreKey("key"))
+	_, weakTombstone := networkB.GetMetadataStore().TTL(network.WeakTombstoneStoreKey("key"))
+
+	assert.False(t, exists)
+	assert.True(t, removed)
+	assert.Nil(t, err)
+	assert.False(t, tombstone)
+	assert.True(t, weakTombstone)
+}
+
+func TestSendForgetDataMessage_ShouldRefuseEveryStore(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	value := []byte("test")
+	hash := util.Hash(value)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	SendStoreMessage(networkA, networkB.GetMe(), hash, value, 0, privateKey)
+	removed, _ := SendForgetDataMessage(networkA, networkB.GetMe(), hash, privateKey)
+
+	errPublisher := SendStoreMessage(networkA, networkB.GetMe(), hash, value, 0, privateKey)
+	errOther := SendStoreMessage(networkA, networkB.GetMe(), hash, value, 0, otherKey)
+	errCache := SendCacheMessage(networkA, networkB.GetMe(), hash, value, 0)
+	_, exists := networkB.GetDatastore().TTL(hash)
+
+	assert.True(t, removed)
+	assert.ErrorContains(t, errPublisher, network.ErrForgotten.Error())
+	assert.ErrorContains(t, errOther, network.ErrForgotten.Error())
+	assert.ErrorContains(t, errCache, network.ErrForgotten.Error())
+	assert.False(t, exists)
+}
+
+func TestSendForgetDataMessage_WhenNotHeld_ShouldRefuseCachedCopiesAndStoresOfSigner(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	value := []byte("test")
+	hash := util.Hash(value)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	removed, errForget := SendForgetDataMessage(networkA, networkB.GetMe(), hash, privateKey)
+	errCache := SendCacheMessage(networkA, networkB.GetMe(), hash, value, 0)
+	errSigner := SendStoreMessage(networkA, networkB.GetMe(), hash, value, 0, privateKey)
+	errOther := SendStoreMessage(networkA, networkB.GetMe(), hash, value, 0, otherKey)
+	_, exists := networkB.GetDatastore().TTL(hash)
+
+	assert.False(t, removed)
+	assert.Nil(t, errForget)
+	assert.True(t, networkB.IsForgotten(hash))
+	assert.ErrorContains(t, errCache, network.ErrForgotten.Error())
+	assert.ErrorContains(t, errSigner, network.ErrForgotten.Error())
+	assert.Nil(t, errOther)
+	assert.True(t, exists)
+}
+
+func TestSendForgetDataMessage_WhenNotHeld_ShouldKeepFirstTombstone(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	value := []byte("test")
+	hash := util.Hash(value)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	SendForgetDataMessage(networkA, networkB.GetMe(), hash, privateKey)
+	SendForgetDataMessage(networkA, networkB.GetMe(), hash, otherKey)
+	errSigner := SendStoreMessage(networkA, networkB.GetMe(), hash, value, 0, privateKey)
+	errOther := SendStoreMessage(networkA, networkB.GetMe(), hash, value, 0, otherKey)
+
+	assert.ErrorContains(t, errSigner, network.ErrForgotten.Error())
+	assert.Nil(t, errOther)
 }
@@ -3,11 +3,23 @@ package rpc
 import (
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
+	"strconv"
 )
 
 // Sends a message to the specified contact, instructing it to refresh a
 // dataobject with the hash value.
-func SendRefreshDataMessage(net network.INetwork, node *routing.Contact, hash string) {
-	msg := net.NewNetworkMessage(network.MESSAGE_RPC_DATA_REFRESH, net.GetMe(), node, hash, "", nil)
-	net.SendMessage(*msg)
+//
+// Returns whether the contact held the dataobject and refreshed it, or
+// ErrTimeout if it did not respond.
+func SendRefreshDataMessage(net network.INetwork, node *routing.Contact, hash string) (bool, error) {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_DATA_REFRESH, net.GetMe(), node, hash, nil, nil)
+
+	response, timeout := net.SendMessageWithResponse(*msg)
+
+	if timeout {
+		net.GetLogger().Info("Refresh timeout", "rpc", "DATA_REFRESH", "peer", node.String())
+		return false, ErrTimeout
+	}
+	refreshed, _ := strconv.ParseBool(string(response.Body))
+	return refreshed, nil
 }
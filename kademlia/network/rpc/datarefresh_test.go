@@ -15,7 +15,7 @@ func TestSendRefreshDataMessage(t *testing.T) {
 	refreshTime := startTime.Add(time.Minute)
 	dataTTL := time.Hour
 	dataKey := "key"
-	timeprovider := &util.FakeTimeProvider{InternalTime: startTime}
+	timeprovider := util.NewSyncTimeProvider(startTime)
 	datastore := datastore.NewDataStore(dataTTL, nil, timeprovider)
 	networkB, _ := network.NewNetwork(14048, datastore)
 	networkA, _ := network.CreateTestNetwork(14041)
@@ -27,15 +27,20 @@ func TestSendRefreshDataMessage(t *testing.T) {
 
 	time.Sleep(20 * time.Millisecond)
 
-	networkB.GetDatastore().Set(dataKey, []byte("test"))
-	timeprovider.InternalTime = refreshTime
+	networkB.GetDatastore().Set(dataKey, []byte("test"), 0)
+	timeprovider.Set(refreshTime)
 
-	SendRefreshDataMessage(networkA, networkB.GetMe(), "hash")
-	timeprovider.InternalTime = startTime.Add(dataTTL)
+	refreshed, err := SendRefreshDataMessage(networkA, networkB.GetMe(), dataKey)
+	refreshedMissing, errMissing := SendRefreshDataMessage(networkA, networkB.GetMe(), "missing")
+	timeprovider.Set(startTime.Add(dataTTL))
 
 	// Data should be in datastore.
 	// If refresh failed, data should not be in datastore
 	_, actualExists := datastore.Get(dataKey)
 
 	assert.True(t, actualExists)
+	assert.True(t, refreshed)
+	assert.Nil(t, err)
+	assert.False(t, refreshedMissing)
+	assert.Nil(t, errMissing)
 }
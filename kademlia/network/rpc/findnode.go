@@ -3,7 +3,6 @@ package rpc
 import (
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
-	"log"
 )
 
 // Send a message to the specified contact.
@@ -11,14 +10,22 @@ import (
 // If the contact responds, the returned contacts will added to the contacts channel.
 // Otherwise, an empty array will be added to the contacts channel.
 func SendFindContactMessage(net network.INetwork, contact *routing.Contact, id *routing.KademliaID, contacts chan []routing.Contact) {
-	msg := net.NewNetworkMessage(network.MESSAGE_RPC_FIND_NODE, net.GetMe(), contact, "", id.String(), nil)
+	found, _ := FindContact(net, contact, id)
+	contacts <- found
+}
+
+// Send a find node message to the specified contact and wait on the response.
+//
+// Returns the contacts the contact knows closest to `id`. If the contact does
+// not respond, an empty array is returned and `timeout` is true.
+func FindContact(net network.INetwork, contact *routing.Contact, id *routing.KademliaID) (contacts []routing.Contact, timeout bool) {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_FIND_NODE, net.GetMe(), contact, "", []byte(id.String()), nil)
 
 	response, timeout := net.SendMessageWithResponse(*msg)
 
 	if timeout {
-		contacts <- make([]routing.Contact, 0)
-		log.Printf("Find contact timeout: %s\n", contact.String())
-	} else {
-		contacts <- response.Contacts
+		net.GetLogger().Info("Find contact timeout", "rpc", "FIND_NODE", "peer", contact.String())
+		return make([]routing.Contact, 0), true
 	}
+	return response.Contacts, false
 }
@@ -27,7 +27,7 @@ func TestSendFindNodeMessage(t *testing.T) {
 			networkA, _ := network.CreateTestNetwork(14041)
 			networkB, _ := network.CreateTestNetwork(14048)
 			for i := 0; i < test.nContactsInRoutingTable; i++ {
-				networkB.GetRoutingTable().AddContact(routing.NewContact(routing.NewRandomKademliaID(), ""))
+				networkB.GetRoutingTable().AddContact(routing.NewContact(routing.NewRandomKademliaID(), fmt.Sprintf("10.0.0.%d:14041", i+1)))
 			}
 
 			go networkA.Listen()
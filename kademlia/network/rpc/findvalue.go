@@ -1,24 +1,35 @@
 package rpc
 
 import (
+	"crypto/ed25519"
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
-	"log"
+	"time"
 )
 
+// A value found by a lookup
+type LookupResponse struct {
+	// Nil if the contact did not respond or does not hold the value
+	Value []byte
+	// Time left until the value expires on the contact that sent it
+	TTL time.Duration
+	// Public key of the publisher of the value, if the contact knows it
+	Publisher ed25519.PublicKey
+}
+
 // Send Lookup command to find data
 //
 // If lookup is succesful, the found value will be added to the value channel.
-// Otherwise, a "::timeout::" string-value will be added to the value channel.
-func SendLookupMessage(net network.INetwork, contact *routing.Contact, hash string, value chan string) {
-	msg := net.NewNetworkMessage(network.MESSAGE_RPC_FIND_VALUE, net.GetMe(), contact, hash, "", nil)
+// Otherwise, a response with a nil value will be added to the value channel.
+func SendLookupMessage(net network.INetwork, contact *routing.Contact, hash string, value chan LookupResponse) {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_FIND_VALUE, net.GetMe(), contact, hash, nil, nil)
 
 	response, timeout := net.SendMessageWithResponse(*msg)
 
 	if timeout {
-		value <- network.NETWORK_REQUEST_TIMEOUT_STRING
-		log.Printf("Lookup timeout: %s\n", contact.String())
+		value <- LookupResponse{}
+		net.GetLogger().Info("Lookup timeout", "rpc", "FIND_VALUE", "peer", contact.String())
 	} else {
-		value <- response.Body
+		value <- LookupResponse{Value: response.Body, TTL: response.TTL, Publisher: response.PublicKey}
 	}
 }
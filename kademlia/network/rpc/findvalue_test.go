@@ -1,23 +1,27 @@
 package rpc
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"d7024e/kademlia/network"
 	"d7024e/util"
-	"strings"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLookupMessage(t *testing.T) {
 	testname := "Send lookup command"
 	t.Run(testname, func(t *testing.T) {
 		expected := "My Message"
-		valueChannel := make(chan string, 1)
+		valueChannel := make(chan LookupResponse, 1)
 		networkA, _ := network.CreateTestNetwork(14041)
 		networkB, _ := network.CreateTestNetwork(14048)
 		messageBytes := []byte(expected)
 		messageHash := util.Hash([]byte(messageBytes))
-		networkB.GetDatastore().Set(messageHash, messageBytes)
+		networkB.GetDatastore().Set(messageHash, messageBytes, 0)
 
 		go networkA.Listen()
 		go networkB.Listen()
@@ -26,9 +30,9 @@ func TestLookupMessage(t *testing.T) {
 		time.Sleep(20 * time.Millisecond)
 
 		go SendLookupMessage(networkA, networkB.GetMe(), messageHash, valueChannel)
-		actual := <-valueChannel
+		actual := (<-valueChannel).Value
 
-		if strings.Compare(actual, expected) != 0 {
+		if !bytes.Equal(actual, []byte(expected)) {
 			t.Errorf("Expected %v, got %v", expected, actual)
 		}
 	})
@@ -37,23 +41,22 @@ func TestLookupMessage(t *testing.T) {
 func TestLookupMessageTimeout(t *testing.T) {
 	testname := "Send lookup command and wait for timeout"
 	t.Run(testname, func(t *testing.T) {
-		expected := network.NETWORK_REQUEST_TIMEOUT_STRING
-		valueChannel := make(chan string, 1)
+		valueChannel := make(chan LookupResponse, 1)
 		networkA, _ := network.CreateTestNetwork(14041)
 		networkB, _ := network.CreateTestNetwork(14048)
 		messageBytes := []byte("Test")
 		messageHash := util.Hash([]byte(messageBytes))
-		networkB.GetDatastore().Set(messageHash, messageBytes)
+		networkB.GetDatastore().Set(messageHash, messageBytes, 0)
 
 		go networkA.Listen()
 		defer networkA.StopListen()
 		time.Sleep(20 * time.Millisecond)
 
 		go SendLookupMessage(networkA, networkB.GetMe(), messageHash, valueChannel)
-		actual := <-valueChannel
+		actual := (<-valueChannel).Value
 
-		if strings.Compare(actual, expected) != 0 {
-			t.Errorf("Expected %v, got %v", expected, actual)
+		if actual != nil {
+			t.Errorf("Expected nil, got %v", actual)
 		}
 	})
 }
@@ -62,7 +65,7 @@ func TestStoreBeforeLookupMessage(t *testing.T) {
 	testname := "Send store message before lookup"
 	t.Run(testname, func(t *testing.T) {
 		expected := "My Message"
-		valueChannel := make(chan string, 1)
+		valueChannel := make(chan LookupResponse, 1)
 		networkA, _ := network.CreateTestNetwork(14041)
 		networkB, _ := network.CreateTestNetwork(14048)
 		messageBytes := []byte(expected)
@@ -74,13 +77,13 @@ func TestStoreBeforeLookupMessage(t *testing.T) {
 		defer networkB.StopListen()
 		time.Sleep(20 * time.Millisecond)
 
-		storeOk := SendStoreMessage(networkA, networkB.GetMe(), messageHash, messageBytes)
-		if !storeOk {
+		storeErr := SendStoreMessage(networkA, networkB.GetMe(), messageHash, messageBytes, 0, publisherKey)
+		if storeErr != nil {
 			t.Errorf("Expected store to succeed")
 			return
 		}
 		go SendLookupMessage(networkA, networkB.GetMe(), messageHash, valueChannel)
-		actual := <-valueChannel
+		actual := (<-valueChannel).Value
 
 		a, oka := networkA.GetDatastore().Get(messageHash)
 		b, okb := networkB.GetDatastore().Get(messageHash)
@@ -89,8 +92,124 @@ func TestStoreBeforeLookupMessage(t *testing.T) {
 		_ = b
 		_ = okb
 
-		if strings.Compare(actual, expected) != 0 {
+		if !bytes.Equal(actual, []byte(expected)) {
 			t.Errorf("Expected %v, got %v", expected, actual)
 		}
 	})
 }
+
+func TestLookupMessage_ShouldReturnRemainingTTL(t *testing.T) {
+	valueChannel := make(chan LookupResponse, 1)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	messageBytes := []byte("My Message")
+	messageHash := util.Hash(messageBytes)
+	networkB.GetDatastore().Set(messageHash, messageBytes, time.Minute)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	go SendLookupMessage(networkA, networkB.GetMe(), messageHash, valueChannel)
+	actual := <-valueChannel
+
+	if actual.TTL <= 0 || actual.TTL > time.Minute {
+		t.Errorf("Expected TTL within %v, got %v", time.Minute, actual.TTL)
+	}
+}
+
+func TestLookupMessage_WithFullChunk_ShouldFitInMessage(t *testing.T) {
+	// The value grows by a third when base64 encoded in the message
+	expected := bytes.Repeat([]byte{0xff}, 4096)
+	valueChannel := make(chan LookupResponse, 1)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	messageHash := util.Hash(expected)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	storeErr := SendStoreMessage(networkA, networkB.GetMe(), messageHash, expected, 0, publisherKey)
+	go SendLookupMessage(networkA, networkB.GetMe(), messageHash, valueChannel)
+	actual := (<-valueChannel).Value
+
+	if storeErr != nil || !bytes.Equal(actual, expected) {
+		t.Errorf("Expected value of %d bytes, got %d bytes (%v)", len(expected), len(actual), storeErr)
+	}
+}
+
+func TestLookupMessage_WithEveryByteValue_ShouldReturnSameBytes(t *testing.T) {
+	expected := make([]byte, 256)
+	for i := range expected {
+		expected[i] = byte(i)
+	}
+	valueChannel := make(chan LookupResponse, 1)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	messageHash := util.Hash(expected)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	storeErr := SendStoreMessage(networkA, networkB.GetMe(), messageHash, expected, 0, publisherKey)
+	stored, _ := networkB.GetDatastore().Get(messageHash)
+	go SendLookupMessage(networkA, networkB.GetMe(), messageHash, valueChannel)
+	actual := (<-valueChannel).Value
+
+	assert.NoError(t, storeErr)
+	assert.Equal(t, expected, stored)
+	assert.Equal(t, expected, actual)
+}
+
+func TestLookupMessage_WithEmptyValue_ShouldNotBeNil(t *testing.T) {
+	valueChannel := make(chan LookupResponse, 1)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	messageHash := util.Hash([]byte{})
+	networkB.GetDatastore().Set(messageHash, []byte{}, 0)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	go SendLookupMessage(networkA, networkB.GetMe(), messageHash, valueChannel)
+	found := (<-valueChannel).Value
+	go SendLookupMessage(networkA, networkB.GetMe(), util.Hash([]byte("missing")), valueChannel)
+	missing := (<-valueChannel).Value
+
+	assert.Equal(t, []byte{}, found)
+	assert.Nil(t, missing)
+}
+
+func TestLookupMessage_ShouldReturnPublisherOfStore(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	valueChannel := make(chan LookupResponse, 1)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	messageBytes := []byte("My Message")
+	messageHash := util.Hash(messageBytes)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	SendStoreMessage(networkA, networkB.GetMe(), messageHash, messageBytes, 0, privateKey)
+	stored, _ := networkB.GetDatastore().Publisher(messageHash)
+	go SendLookupMessage(networkA, networkB.GetMe(), messageHash, valueChannel)
+	actual := (<-valueChannel).Publisher
+
+	assert.Equal(t, []byte(publicKey), stored)
+	assert.Equal(t, publicKey, actual)
+}
@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/network/routing"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Providers of a key found by a lookup
+type ProvidersResponse struct {
+	Contact *routing.Contact
+	// Empty if the contact did not respond, or knows no providers
+	Providers []routing.Contact
+}
+
+// Send an add provider command to announce that this node can serve the
+// content of `key`
+//
+// The contact keeps this node as a provider for `ttl`, or the default
+// provider TTL if zero.
+//
+// If the contact added the provider, nil is returned. Otherwise, an error
+// with the reason it refused, or ErrTimeout if it did not respond.
+func SendAddProviderMessage(net network.INetwork, contact *routing.Contact, key string, ttl time.Duration) error {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_ADD_PROVIDER, net.GetMe(), contact, key, nil, nil)
+	msg.TTL = ttl
+
+	response, timeout := net.SendMessageWithResponse(*msg)
+
+	if timeout {
+		net.GetLogger().Info("Add provider timeout", "rpc", "ADD_PROVIDER", "peer", contact.String())
+		return ErrTimeout
+	}
+	if succes, _ := strconv.ParseBool(string(response.Body)); succes {
+		return nil
+	}
+	return fmt.Errorf("add provider refused: %s", response.Body)
+}
+
+// Send a get providers command to find the providers of `key` known by the
+// contact
+//
+// The response is added to the providers channel.
+func SendGetProvidersMessage(net network.INetwork, contact *routing.Contact, key string, providers chan ProvidersResponse) {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_GET_PROVIDERS, net.GetMe(), contact, key, nil, nil)
+
+	response, timeout := net.SendMessageWithResponse(*msg)
+
+	if timeout {
+		net.GetLogger().Info("Get providers timeout", "rpc", "GET_PROVIDERS", "peer", contact.String())
+		providers <- ProvidersResponse{Contact: contact}
+		return
+	}
+	providers <- ProvidersResponse{Contact: contact, Providers: response.Contacts}
+}
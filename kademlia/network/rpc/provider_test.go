@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/network/routing"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddProviderMessage_ShouldBeReturnedByGetProviders(t *testing.T) {
+	providers := make(chan ProvidersResponse, 2)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	err := SendAddProviderMessage(networkA, networkB.GetMe(), "key", time.Minute)
+	go SendGetProvidersMessage(networkA, networkB.GetMe(), "key", providers)
+	found := <-providers
+	go SendGetProvidersMessage(networkA, networkB.GetMe(), "other", providers)
+	missing := <-providers
+	_, valueExists := networkB.GetDatastore().Get("key")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(found.Providers))
+	assert.Equal(t, networkA.GetMe().ID, found.Providers[0].ID)
+	assert.Equal(t, networkA.GetMe().Address, found.Providers[0].Address)
+	assert.Empty(t, missing.Providers)
+	assert.False(t, valueExists)
+}
+
+func TestAddProviderMessage_WhenSenderIsNotAtItsAddress_ShouldRefuse(t *testing.T) {
+	providers := make(chan ProvidersResponse, 1)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	victim := routing.NewContact(routing.NewRandomKademliaID(), "192.0.2.1:14041")
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	msg := networkA.NewNetworkMessage(network.MESSAGE_RPC_ADD_PROVIDER, &victim, networkB.GetMe(), "key", nil, nil)
+	response, _ := networkA.SendMessageWithResponse(*msg)
+	go SendGetProvidersMessage(networkA, networkB.GetMe(), "key", providers)
+	found := <-providers
+
+	assert.Equal(t, network.ErrSenderNotBound.Error(), string(response.Body))
+	assert.Empty(t, found.Providers)
+}
+
+func TestAddProviderMessage_OnTimeout_ShouldReturnErrTimeout(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+
+	go networkA.Listen()
+	defer networkA.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	err := SendAddProviderMessage(networkA, networkB.GetMe(), "key", 0)
+
+	assert.ErrorIs(t, err, ErrTimeout)
+}
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/network/routing"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Send a subscribe command to subscribe this node to the topic with the
+// given key
+//
+// The contact keeps this node as a subscriber for `ttl`, or the default
+// subscription TTL if zero.
+//
+// If the contact added the subscriber, nil is returned. Otherwise, an error
+// with the reason it refused, or ErrTimeout if it did not respond.
+func SendSubscribeMessage(net network.INetwork, contact *routing.Contact, key string, ttl time.Duration) error {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_SUBSCRIBE, net.GetMe(), contact, key, nil, nil)
+	msg.TTL = ttl
+
+	response, timeout := net.SendMessageWithResponse(*msg)
+
+	if timeout {
+		net.GetLogger().Info("Subscribe timeout", "rpc", "SUBSCRIBE", "peer", contact.String())
+		return ErrTimeout
+	}
+	if succes, _ := strconv.ParseBool(string(response.Body)); succes {
+		return nil
+	}
+	return fmt.Errorf("subscribe refused: %s", response.Body)
+}
+
+// Send an unsubscribe command to unsubscribe this node from the topic with
+// the given key
+//
+// Returns whether the contact kept this node as a subscriber, or ErrTimeout
+// if it did not respond.
+func SendUnsubscribeMessage(net network.INetwork, contact *routing.Contact, key string) (bool, error) {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_UNSUBSCRIBE, net.GetMe(), contact, key, nil, nil)
+
+	response, timeout := net.SendMessageWithResponse(*msg)
+
+	if timeout {
+		net.GetLogger().Info("Unsubscribe timeout", "rpc", "UNSUBSCRIBE", "peer", contact.String())
+		return false, ErrTimeout
+	}
+	removed, _ := strconv.ParseBool(string(response.Body))
+	return removed, nil
+}
+
+// Send a publish command to have the contact fan a publication out to the
+// subscribers of its topic
+//
+// If the contact accepted the publication, nil is returned. Otherwise, an
+// error with the reason it refused, or ErrTimeout if it did not respond.
+func SendPublishMessage(net network.INetwork, contact *routing.Contact, pub network.Publication) error {
+	key := network.TopicKey(pub.Topic)
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_PUBLISH, net.GetMe(), contact, key, network.EncodePublication(pub), nil)
+
+	response, timeout := net.SendMessageWithResponse(*msg)
+
+	if timeout {
+		net.GetLogger().Info("Publish timeout", "rpc", "PUBLISH", "peer", contact.String())
+		return ErrTimeout
+	}
+	if succes, _ := strconv.ParseBool(string(response.Body)); succes {
+		return nil
+	}
+	return fmt.Errorf("publish refused: %s", response.Body)
+}
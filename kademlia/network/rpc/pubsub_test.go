@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/network/routing"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishMessage_ShouldBeDeliveredToSubscriberOnce(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	key := network.TopicKey("news")
+	pub := network.NewPublication("news", []byte("hello"))
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	sub, _ := networkA.GetBroker().Subscribe("news")
+	errSubscribe := SendSubscribeMessage(networkA, networkB.GetMe(), key, time.Minute)
+	errPublish := SendPublishMessage(networkA, networkB.GetMe(), pub)
+	errCopy := SendPublishMessage(networkA, networkB.GetMe(), pub)
+
+	assert.Nil(t, errSubscribe)
+	assert.Nil(t, errPublish)
+	assert.Nil(t, errCopy)
+	select {
+	case received := <-sub.C:
+		assert.Equal(t, pub, received)
+	case <-time.After(time.Second):
+		t.Fatal("publication was not delivered")
+	}
+	select {
+	case <-sub.C:
+		t.Fatal("copy of publication was delivered")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPublishMessage_WhenSubscriberIsGone_ShouldDropSubscriber(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	key := network.TopicKey("news")
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	SendSubscribeMessage(networkA, networkB.GetMe(), key, time.Minute)
+	_, subscribed := networkB.GetMetadataStore().Get(network.SubscriberStoreKey(key))
+	SendPublishMessage(networkA, networkB.GetMe(), network.NewPublication("news", []byte("hello")))
+
+	assert.True(t, subscribed)
+	assert.Eventually(t, func() bool {
+		_, subscribed := networkB.GetMetadataStore().Get(network.SubscriberStoreKey(key))
+		return !subscribed
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestUnsubscribeMessage_ShouldRemoveSubscriber(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	key := network.TopicKey("news")
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	SendSubscribeMessage(networkA, networkB.GetMe(), key, time.Minute)
+	removed, err := SendUnsubscribeMessage(networkA, networkB.GetMe(), key)
+	removedAgain, _ := SendUnsubscribeMessage(networkA, networkB.GetMe(), key)
+
+	assert.Nil(t, err)
+	assert.True(t, removed)
+	assert.False(t, removedAgain)
+}
+
+func TestPublishMessage_WithWrongTopic_ShouldBeRefused(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	msg := networkA.NewNetworkMessage(network.MESSAGE_RPC_PUBLISH, networkA.GetMe(), networkB.GetMe(), network.TopicKey("other"),
+		network.EncodePublication(network.NewPublication("news", []byte("hello"))), nil)
+	response, timeout := networkA.SendMessageWithResponse(*msg)
+
+	assert.False(t, timeout)
+	assert.Equal(t, network.ErrTopicMismatch.Error(), string(response.Body))
+}
+
+func TestPublishMessage_WhenTooLarge_ShouldBeRefused(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	pub := network.NewPublication("news", make([]byte, network.NETWORK_MAX_PUBLICATION_SIZE+1))
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	err := SendPublishMessage(networkA, networkB.GetMe(), pub)
+
+	assert.ErrorContains(t, err, network.ErrPublicationTooLarge.Error())
+}
+
+func TestSubscribeMessage_WhenSenderIsNotAtItsAddress_ShouldRefuse(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	key := network.TopicKey("news")
+	victim := routing.NewContact(routing.NewRandomKademliaID(), "192.0.2.1:14041")
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	msg := networkA.NewNetworkMessage(network.MESSAGE_RPC_SUBSCRIBE, &victim, networkB.GetMe(), key, nil, nil)
+	response, _ := networkA.SendMessageWithResponse(*msg)
+	_, subscribed := networkB.GetMetadataStore().Get(network.SubscriberStoreKey(key))
+
+	assert.Equal(t, network.ErrSenderNotBound.Error(), string(response.Body))
+	assert.False(t, subscribed)
+}
+
+func TestUnsubscribeMessage_WhenSentForOtherSubscriber_ShouldKeepSubscriber(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	key := network.TopicKey("news")
+	impostor := routing.NewContact(networkA.GetMe().ID, "192.0.2.1:14041")
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	SendSubscribeMessage(networkA, networkB.GetMe(), key, time.Minute)
+	msg := networkA.NewNetworkMessage(network.MESSAGE_RPC_UNSUBSCRIBE, &impostor, networkB.GetMe(), key, nil, nil)
+	response, _ := networkA.SendMessageWithResponse(*msg)
+	_, subscribed := networkB.GetMetadataStore().Get(network.SubscriberStoreKey(key))
+
+	assert.Equal(t, "false", string(response.Body))
+	assert.True(t, subscribed)
+}
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/record"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// A record found by a lookup
+type RecordResponse struct {
+	Contact *routing.Contact
+	// Nil if the contact did not respond, or had no valid record
+	Record *record.Record
+	// Time left until the record expires on the contact that sent it
+	TTL time.Duration
+}
+
+// Send a store record command to store a signed record
+//
+// The record is kept for `ttl`, or the default TTL of the contact if zero.
+//
+// If store is succesful, nil is returned. Otherwise, an error with the reason
+// the contact refused the record, or ErrTimeout if it did not respond.
+func SendStoreRecordMessage(net network.INetwork, contact *routing.Contact, rec *record.Record, ttl time.Duration) error {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_STORE_RECORD, net.GetMe(), contact, rec.Key(), record.Encode(rec), nil)
+	msg.TTL = ttl
+
+	response, timeout := net.SendMessageWithResponse(*msg)
+
+	if timeout {
+		net.GetLogger().Info("Store record timeout", "rpc", "STORE_RECORD", "peer", contact.String())
+		return ErrTimeout
+	}
+	if succes, _ := strconv.ParseBool(string(response.Body)); succes {
+		return nil
+	}
+	return fmt.Errorf("store refused: %s", response.Body)
+}
+
+// Send a find record command to find the record stored under `key`
+//
+// The response is added to the records channel. Records that are not validly
+// signed for `key` are discarded.
+func SendFindRecordMessage(net network.INetwork, contact *routing.Contact, key string, records chan RecordResponse) {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_FIND_RECORD, net.GetMe(), contact, key, nil, nil)
+
+	response, timeout := net.SendMessageWithResponse(*msg)
+
+	if timeout {
+		net.GetLogger().Info("Find record timeout", "rpc", "FIND_RECORD", "peer", contact.String())
+		records <- RecordResponse{Contact: contact}
+		return
+	}
+	if len(response.Body) == 0 {
+		records <- RecordResponse{Contact: contact}
+		return
+	}
+
+	rec, err := record.Decode(response.Body)
+	if err == nil {
+		err = rec.VerifyKey(key)
+	}
+	if err != nil {
+		net.GetLogger().Warn("Invalid record", "rpc", "FIND_RECORD", "peer", contact.String(), "key", key, "err", err)
+		records <- RecordResponse{Contact: contact}
+		return
+	}
+	records <- RecordResponse{Contact: contact, Record: rec, TTL: response.TTL}
+}
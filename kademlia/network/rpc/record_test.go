@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/record"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreRecordMessage_ShouldOnlyAcceptNewerRecords(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	first := record.New(privateKey, 1, []byte("first"))
+	second := record.New(privateKey, 2, []byte("second"))
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	errFirst := SendStoreRecordMessage(networkA, networkB.GetMe(), first, 0)
+	errSecond := SendStoreRecordMessage(networkA, networkB.GetMe(), second, 0)
+	errRepublish := SendStoreRecordMessage(networkA, networkB.GetMe(), second, 0)
+	errStale := SendStoreRecordMessage(networkA, networkB.GetMe(), first, 0)
+	stored, _ := networkB.GetMetadataStore().Get(network.RecordStoreKey(first.Key()))
+	dataobjects := networkB.GetDatastore().Stats().Entries
+
+	assert.Nil(t, errFirst)
+	assert.Nil(t, errSecond)
+	assert.Nil(t, errRepublish)
+	assert.NotNil(t, errStale)
+	assert.Contains(t, errStale.Error(), network.ErrStaleRecord.Error())
+	assert.Equal(t, record.Encode(second), stored)
+	assert.Zero(t, dataobjects)
+}
+
+func TestStoreRecordMessage_WithInvalidSignature_ShouldRefuse(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	forged := record.New(privateKey, 1, []byte("value"))
+	forged.Sequence = 100
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	actual := SendStoreRecordMessage(networkA, networkB.GetMe(), forged, 0)
+	_, exists := networkB.GetMetadataStore().Get(network.RecordStoreKey(forged.Key()))
+
+	assert.NotNil(t, actual)
+	assert.Contains(t, actual.Error(), record.ErrInvalidSignature.Error())
+	assert.False(t, exists)
+}
+
+func TestFindRecordMessage(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherPrivateKey, _ := ed25519.GenerateKey(rand.Reader)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	expected := record.New(privateKey, 1, []byte("value"))
+	misplaced := record.New(otherPrivateKey, 1, []byte("value"))
+	networkB.GetMetadataStore().Set(network.RecordStoreKey(expected.Key()), record.Encode(expected), time.Minute)
+	networkB.GetMetadataStore().Set(network.RecordStoreKey(expected.Key()+"0"), record.Encode(misplaced), 0)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	records := make(chan RecordResponse, 3)
+	go SendFindRecordMessage(networkA, networkB.GetMe(), expected.Key(), records)
+	found := <-records
+	go SendFindRecordMessage(networkA, networkB.GetMe(), expected.Key()+"0", records)
+	invalid := <-records
+	go SendFindRecordMessage(networkA, networkB.GetMe(), misplaced.Key(), records)
+	missing := <-records
+
+	assert.Equal(t, expected, found.Record)
+	assert.Greater(t, found.TTL, time.Duration(0))
+	assert.Nil(t, invalid.Record)
+	assert.Nil(t, missing.Record)
+}
@@ -1,26 +1,59 @@
 package rpc
 
 import (
+	"crypto/ed25519"
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
-	"log"
+	"errors"
+	"fmt"
 	"strconv"
+	"time"
 )
 
+var ErrTimeout = errors.New("request timed out")
+
 // Send a store command to store data
 //
-// If store is succesful, success will be true. Otherwise, false.
-func SendStoreMessage(net network.INetwork, contact *routing.Contact, hash string, data []byte) (succes bool) {
+// The data is kept for `ttl`, or the default TTL of the contact if zero. The
+// contact may shorten TTLs longer than `network.NETWORK_MAX_TTL`. The message
+// is signed with `key`, and the contact records its public key as the
+// publisher of the data, whose key must sign a later forget. Data without a
+// publisher can only be sent with SendCacheMessage.
+//
+// If store is succesful, nil is returned. Otherwise, an error with the reason
+// the contact refused the data, network.ErrUnsignedStore if `key` is nil, or
+// ErrTimeout if the contact did not respond.
+func SendStoreMessage(net network.INetwork, contact *routing.Contact, hash string, data []byte, ttl time.Duration, key ed25519.PrivateKey) error {
+	if key == nil {
+		return network.ErrUnsignedStore
+	}
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_STORE, net.GetMe(), contact, hash, data, nil)
+	msg.TTL = ttl
+	network.SignStore(msg, key, time.Now())
+	return sendStore(net, contact, msg)
+}
+
+// Send a store command to cache data found by a lookup
+//
+// The same as SendStoreMessage, except that the contact keeps the data as a
+// cached copy rather than a replica, without a publisher.
+func SendCacheMessage(net network.INetwork, contact *routing.Contact, hash string, data []byte, ttl time.Duration) error {
+	msg := net.NewNetworkMessage(network.MESSAGE_RPC_STORE, net.GetMe(), contact, hash, data, nil)
+	msg.TTL = ttl
+	msg.Cache = true
+	return sendStore(net, contact, msg)
+}
 
-	msg := net.NewNetworkMessage(network.MESSAGE_RPC_STORE, net.GetMe(), contact, hash, string(data), nil)
+func sendStore(net network.INetwork, contact *routing.Contact, msg *network.NetworkMessage) error {
 
 	response, timeout := net.SendMessageWithResponse(*msg)
 
 	if timeout {
-		log.Printf("Store timeout: %s\n", contact.String())
-		return false
-	} else {
-		succes, _ := strconv.ParseBool(response.Body)
-		return succes
+		net.GetLogger().Info("Store timeout", "rpc", "STORE", "peer", contact.String())
+		return ErrTimeout
+	}
+	if succes, _ := strconv.ParseBool(string(response.Body)); succes {
+		return nil
 	}
+	return fmt.Errorf("store refused: %s", response.Body)
 }
@@ -1,13 +1,26 @@
 package rpc
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"d7024e/kademlia/datastore"
 	"d7024e/kademlia/network"
 	"d7024e/util"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
+// Key the tests publish dataobjects with
+var publisherKey = newPublisherKey()
+
+func newPublisherKey() ed25519.PrivateKey {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	return key
+}
+
 func TestStoreMessage(t *testing.T) {
 	testname := "Send store command"
 	t.Run(testname, func(t *testing.T) {
@@ -23,7 +36,7 @@ func TestStoreMessage(t *testing.T) {
 		defer networkB.StopListen()
 		time.Sleep(20 * time.Millisecond)
 
-		SendStoreMessage(networkA, networkB.GetMe(), messageHash, messageBytes)
+		SendStoreMessage(networkA, networkB.GetMe(), messageHash, messageBytes, 0, publisherKey)
 		storedMessage, _ := networkB.GetDatastore().Get(messageHash)
 		actual := string(storedMessage)
 
@@ -36,7 +49,7 @@ func TestStoreMessage(t *testing.T) {
 func TestStoreMessageTimeout(t *testing.T) {
 	testname := "Send store command and wait for timeout"
 	t.Run(testname, func(t *testing.T) {
-		expected := false
+		expected := ErrTimeout
 		networkA, _ := network.CreateTestNetwork(14041)
 		networkB, _ := network.CreateTestNetwork(14048)
 		messageBytes := []byte("My Message")
@@ -46,10 +59,224 @@ func TestStoreMessageTimeout(t *testing.T) {
 		defer networkA.StopListen()
 		time.Sleep(20 * time.Millisecond)
 
-		actual := SendStoreMessage(networkA, networkB.GetMe(), messageHash, messageBytes)
+		actual := SendStoreMessage(networkA, networkB.GetMe(), messageHash, messageBytes, 0, publisherKey)
 
 		if actual != expected {
 			t.Errorf("Expected %v, got %v", expected, actual)
 		}
 	})
 }
+
+func TestStoreMessage_WhenRefused_ShouldReturnReason(t *testing.T) {
+	timeprovider := new(util.TimeProvider)
+	store := datastore.NewDataStore(time.Hour, nil, timeprovider)
+	store.SetCapacity(datastore.Capacity{MaxValueSize: 4})
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.NewNetwork(14048, store)
+	messageBytes := []byte("My Message")
+	messageHash := util.Hash(messageBytes)
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	actual := SendStoreMessage(networkA, networkB.GetMe(), messageHash, messageBytes, 0, publisherKey)
+
+	assert.NotNil(t, actual)
+	assert.Contains(t, actual.Error(), datastore.ErrValueTooLarge.Error())
+}
+
+func TestStoreMessage_WithTTL_ShouldLimitTTL(t *testing.T) {
+	var tests = []struct {
+		ttl         time.Duration
+		expectedTTL time.Duration
+	}{
+		{time.Minute, time.Minute},
+		{network.NETWORK_MAX_TTL + time.Hour, network.NETWORK_MAX_TTL},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ttl.String(), func(t *testing.T) {
+			startTime := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+			timeprovider := &util.FakeTimeProvider{InternalTime: startTime}
+			store := datastore.NewDataStore(time.Hour, nil, timeprovider)
+			networkA, _ := network.CreateTestNetwork(14041)
+			networkB, _ := network.NewNetwork(14048, store)
+			messageBytes := []byte("My Message")
+			messageHash := util.Hash(messageBytes)
+
+			go networkA.Listen()
+			go networkB.Listen()
+			defer networkA.StopListen()
+			defer networkB.StopListen()
+			time.Sleep(20 * time.Millisecond)
+
+			SendStoreMessage(networkA, networkB.GetMe(), messageHash, messageBytes, test.ttl, publisherKey)
+			actual, _ := store.TTL(messageHash)
+
+			assert.Equal(t, test.expectedTTL, actual)
+		})
+	}
+}
+
+func TestStoreMessage_WithMismatchingHash_ShouldRefuse(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	messageHash := util.Hash([]byte("My Message"))
+	attemptsBefore := network.StorePoisoningAttempts.Value()
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	actual := SendStoreMessage(networkA, networkB.GetMe(), messageHash, []byte("Poisoned"), 0, publisherKey)
+	_, exists := networkB.GetDatastore().Get(messageHash)
+
+	assert.NotNil(t, actual)
+	assert.Contains(t, actual.Error(), network.ErrDigestMismatch.Error())
+	assert.False(t, exists)
+	assert.Equal(t, attemptsBefore+1, network.StorePoisoningAttempts.Value())
+}
+
+func TestCacheMessage_ShouldStoreCachedCopy(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	cached := []byte("cached")
+	replica := []byte("replica")
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	errCache := SendCacheMessage(networkA, networkB.GetMe(), util.Hash(cached), cached, 0)
+	errStore := SendStoreMessage(networkA, networkB.GetMe(), util.Hash(replica), replica, 0, publisherKey)
+	entries := networkB.GetDatastore().Entries("", 0)
+
+	assert.Nil(t, errCache)
+	assert.Nil(t, errStore)
+	assert.Equal(t, 2, len(entries))
+	for _, entry := range entries {
+		assert.Equal(t, entry.Key == util.Hash(cached), entry.Cached)
+	}
+}
+
+func TestStoreMessage_WhenSigned_ShouldRecordPublisher(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	value := []byte("My Message")
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	err := SendStoreMessage(networkA, networkB.GetMe(), util.Hash(value), value, time.Minute, privateKey)
+	publisher, _ := networkB.GetDatastore().Publisher(util.Hash(value))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(publicKey), publisher)
+}
+
+func TestStoreMessage_WhenPublisherDidNotSign_ShouldRefuse(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	var tests = []struct {
+		name string
+		sign func(msg *network.NetworkMessage)
+	}{
+		{"no publisher", func(msg *network.NetworkMessage) {}},
+		{"unsigned", func(msg *network.NetworkMessage) { msg.PublicKey = publicKey }},
+		{"other TTL", func(msg *network.NetworkMessage) {
+			network.SignStore(msg, privateKey, time.Now())
+			msg.TTL = network.NETWORK_MAX_TTL
+		}},
+		{"other time", func(msg *network.NetworkMessage) {
+			network.SignStore(msg, privateKey, time.Now())
+			msg.SignedAt++
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			networkA, _ := network.CreateTestNetwork(14041)
+			networkB, _ := network.CreateTestNetwork(14048)
+			value := []byte("My Message")
+
+			go networkA.Listen()
+			go networkB.Listen()
+			defer networkA.StopListen()
+			defer networkB.StopListen()
+			time.Sleep(20 * time.Millisecond)
+
+			msg := networkA.NewNetworkMessage(network.MESSAGE_RPC_STORE, networkA.GetMe(), networkB.GetMe(), util.Hash(value), value, nil)
+			msg.TTL = time.Minute
+			test.sign(msg)
+			err := sendStore(networkA, networkB.GetMe(), msg)
+			_, exists := networkB.GetDatastore().Get(util.Hash(value))
+
+			assert.ErrorContains(t, err, network.ErrUnsignedStore.Error())
+			assert.False(t, exists)
+		})
+	}
+}
+
+func TestCacheMessage_ShouldNotRecordPublisher(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	value := []byte("My Message")
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	msg := networkA.NewNetworkMessage(network.MESSAGE_RPC_STORE, networkA.GetMe(), networkB.GetMe(), util.Hash(value), value, nil)
+	msg.Cache = true
+	msg.PublicKey = publicKey
+	err := sendStore(networkA, networkB.GetMe(), msg)
+	publisher, exists := networkB.GetDatastore().Publisher(util.Hash(value))
+
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Nil(t, publisher)
+}
+
+func TestStoreMessage_WhenSignedTooLongAgo_ShouldRefuse(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+	value := []byte("My Message")
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	msg := networkA.NewNetworkMessage(network.MESSAGE_RPC_STORE, networkA.GetMe(), networkB.GetMe(), util.Hash(value), value, nil)
+	msg.TTL = time.Minute
+	network.SignStore(msg, publisherKey, time.Now().Add(-2*network.NETWORK_STORE_MAX_AGE))
+	err := sendStore(networkA, networkB.GetMe(), msg)
+	_, exists := networkB.GetDatastore().Get(util.Hash(value))
+
+	assert.ErrorContains(t, err, network.ErrStaleStore.Error())
+	assert.False(t, exists)
+}
+
+func TestStoreMessage_WithoutKey_ShouldNotSend(t *testing.T) {
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.CreateTestNetwork(14048)
+
+	err := SendStoreMessage(networkA, networkB.GetMe(), util.Hash([]byte("value")), []byte("value"), 0, nil)
+
+	assert.ErrorIs(t, err, network.ErrUnsignedStore)
+	assert.Zero(t, networkA.GetStats().Sent[network.RPCName(network.MESSAGE_RPC_STORE)])
+}
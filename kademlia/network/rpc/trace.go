@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"d7024e/kademlia/network/routing"
+	"time"
+)
+
+// A contact as shown in a lookup trace
+type TracedContact struct {
+	ID      string
+	Address string
+}
+
+func NewTracedContact(contact routing.Contact) TracedContact {
+	return TracedContact{ID: contact.ID.String(), Address: contact.Address}
+}
+
+// Convert contacts to how they are shown in a lookup trace
+func NewTracedContacts(contacts []routing.Contact) []TracedContact {
+	traced := make([]TracedContact, len(contacts))
+	for i, contact := range contacts {
+		traced[i] = NewTracedContact(contact)
+	}
+	return traced
+}
+
+// A find node message sent by a traced lookup, and its response
+type TraceStep struct {
+	// Index of the step whose response returned the contact, or -1 if the
+	// contact came from the routing table of this node
+	Parent int
+	// 1 for contacts from the routing table, and one more than the round of
+	// the parent step otherwise
+	Round   int
+	Contact TracedContact
+	// Time until the response, or until the request timed out
+	RTT      time.Duration
+	Timeout  bool
+	Returned []TracedContact
+	// Whether the closest contact returned is closer to the target than the
+	// contact that was asked
+	Closer bool
+}
+
+// Every find node message a lookup sent, in the order the responses arrived
+type LookupTrace struct {
+	Target   string
+	Steps    []TraceStep
+	Closest  []TracedContact
+	Duration time.Duration
+}
@@ -0,0 +1,139 @@
+package network
+
+import (
+	"d7024e/kademlia/datastore"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Names of the RPCs, as shown in the status of a node
+var rpcNames = map[int]string{
+	MESSAGE_RPC_PING:          "PING",
+	MESSAGE_RPC_STORE:         "STORE",
+	MESSAGE_RPC_FIND_NODE:     "FIND_NODE",
+	MESSAGE_RPC_FIND_VALUE:    "FIND_VALUE",
+	MESSAGE_RPC_DATA_REFRESH:  "DATA_REFRESH",
+	MESSAGE_RPC_DATA_FORGET:   "DATA_FORGET",
+	MESSAGE_RPC_STORE_RECORD:  "STORE_RECORD",
+	MESSAGE_RPC_FIND_RECORD:   "FIND_RECORD",
+	MESSAGE_RPC_ADD_PROVIDER:  "ADD_PROVIDER",
+	MESSAGE_RESPONSE:          "RESPONSE",
+	MESSAGE_RPC_GET_PROVIDERS: "GET_PROVIDERS",
+	MESSAGE_RPC_SUBSCRIBE:     "SUBSCRIBE",
+	MESSAGE_RPC_UNSUBSCRIBE:   "UNSUBSCRIBE",
+	MESSAGE_RPC_PUBLISH:       "PUBLISH",
+	MESSAGE_RPC_DELIVER:       "DELIVER",
+}
+
+// Get the name of an RPC
+func RPCName(rpc int) string {
+	if name, exists := rpcNames[rpc]; exists {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", rpc)
+}
+
+// Status of a node, as reported by `stat`
+type NodeStatus struct {
+	ID      string
+	Address string
+	// Other addresses the node advertises, e.g. of the other IP family
+	Addresses []string `json:",omitempty"`
+	Uptime    time.Duration
+	JoinState string
+	// Contacts in the routing table, and in each bucket that is not empty
+	Contacts int
+	Buckets  []BucketFill
+	Store    datastore.Stats
+	Network  NetworkStats
+}
+
+// Contacts in a bucket of the routing table
+type BucketFill struct {
+	Index    int
+	Contacts int
+}
+
+// Traffic of a node since it started
+type NetworkStats struct {
+	// Requests sent and received, by RPC name
+	Sent     map[string]uint64
+	Received map[string]uint64
+	// Requests that got no response in time
+	Timeouts uint64
+	// Average time from sending a request to receiving its response
+	AverageRTT time.Duration
+}
+
+// Counts the traffic of a node. Every count is also added to the metrics of
+// the node.
+type rpcCounters struct {
+	lock     sync.Mutex
+	sent     map[int]uint64
+	received map[int]uint64
+	timeouts uint64
+	rttTotal time.Duration
+	rttCount uint64
+}
+
+func newRPCCounters() *rpcCounters {
+	return &rpcCounters{
+		sent:     make(map[int]uint64),
+		received: make(map[int]uint64),
+	}
+}
+
+func (counters *rpcCounters) countSent(rpc int) {
+	counters.lock.Lock()
+	defer counters.lock.Unlock()
+	counters.sent[rpc]++
+	RPCsSent.WithLabelValues(RPCName(rpc)).Inc()
+}
+
+func (counters *rpcCounters) countReceived(rpc int) {
+	counters.lock.Lock()
+	defer counters.lock.Unlock()
+	counters.received[rpc]++
+	RPCsReceived.WithLabelValues(RPCName(rpc)).Inc()
+}
+
+func (counters *rpcCounters) countTimeout() {
+	counters.lock.Lock()
+	defer counters.lock.Unlock()
+	counters.timeouts++
+	RPCTimeouts.Inc()
+}
+
+func (counters *rpcCounters) countResponse(rtt time.Duration) {
+	counters.lock.Lock()
+	defer counters.lock.Unlock()
+	counters.rttTotal += rtt
+	counters.rttCount++
+	RPCRoundTrip.Observe(rtt.Seconds())
+}
+
+func (counters *rpcCounters) stats() NetworkStats {
+	counters.lock.Lock()
+	defer counters.lock.Unlock()
+
+	stats := NetworkStats{
+		Sent:     make(map[string]uint64, len(counters.sent)),
+		Received: make(map[string]uint64, len(counters.received)),
+		Timeouts: counters.timeouts,
+	}
+	for rpc, count := range counters.sent {
+		stats.Sent[RPCName(rpc)] = count
+	}
+	for rpc, count := range counters.received {
+		stats.Received[RPCName(rpc)] = count
+	}
+	if counters.rttCount > 0 {
+		stats.AverageRTT = counters.rttTotal / time.Duration(counters.rttCount)
+	}
+	return stats
+}
+
+func (network *Network) GetStats() NetworkStats {
+	return network.counters.stats()
+}
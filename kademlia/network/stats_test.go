@@ -0,0 +1,34 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRPCCounters_ShouldCountByName(t *testing.T) {
+	counters := newRPCCounters()
+	counters.countSent(MESSAGE_RPC_PING)
+	counters.countSent(MESSAGE_RPC_PING)
+	counters.countSent(MESSAGE_RPC_FIND_NODE)
+	counters.countReceived(MESSAGE_RPC_STORE)
+	counters.countReceived(99)
+	counters.countTimeout()
+	counters.countResponse(2 * time.Millisecond)
+	counters.countResponse(4 * time.Millisecond)
+
+	stats := counters.stats()
+
+	assert.Equal(t, map[string]uint64{"PING": 2, "FIND_NODE": 1}, stats.Sent)
+	assert.Equal(t, map[string]uint64{"STORE": 1, "UNKNOWN(99)": 1}, stats.Received)
+	assert.Equal(t, uint64(1), stats.Timeouts)
+	assert.Equal(t, 3*time.Millisecond, stats.AverageRTT)
+}
+
+func TestRPCCounters_WithoutResponses_ShouldHaveNoRTT(t *testing.T) {
+	stats := newRPCCounters().stats()
+
+	assert.Equal(t, time.Duration(0), stats.AverageRTT)
+	assert.Empty(t, stats.Sent)
+}
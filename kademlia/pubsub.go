@@ -0,0 +1,125 @@
+package kademlia
+
+import (
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
+	"errors"
+	"time"
+)
+
+// Subscribe this node to `topic`
+//
+// The subscriber is kept by the closest nodes of the key of the topic, and
+// renewed until the last subscription to the topic is closed with
+// Unsubscribe. Returns an error if no node added the subscriber.
+func (kademlia *Kademlia) Subscribe(topic string) (*network.Subscription, error) {
+	broker := kademlia.network.GetBroker()
+	sub, first := broker.Subscribe(topic)
+	if !first {
+		return sub, nil
+	}
+
+	if err := kademlia.announceSubscription(topic); err != nil {
+		broker.Unsubscribe(sub)
+		return nil, err
+	}
+	go kademlia.renewSubscription(topic, broker.Done(topic))
+	return sub, nil
+}
+
+// Close a subscription. When it is the last subscription to its topic, this
+// node is unsubscribed from the topic at the closest nodes of its key.
+func (kademlia *Kademlia) Unsubscribe(sub *network.Subscription) error {
+	if !kademlia.network.GetBroker().Unsubscribe(sub) {
+		return nil
+	}
+
+	key := network.TopicKey(sub.Topic)
+	contacts := kademlia.LookupContact(routing.NewKademliaID(key))
+	if len(contacts) == 0 {
+		return errors.New("no suitable contacts found for unsubscribing")
+	}
+	for i := range contacts {
+		go rpc.SendUnsubscribeMessage(kademlia.network, &contacts[i], key)
+	}
+	return nil
+}
+
+// Publish `data` to the subscribers of `topic`
+//
+// The publication is sent to the closest nodes of the key of the topic, which
+// fan it out to the subscribers. Returns an error if no node accepted it, or
+// network.ErrPublicationTooLarge if `data` is larger than a node accepts.
+func (kademlia *Kademlia) Publish(topic string, data []byte) error {
+	if len(data) > network.NETWORK_MAX_PUBLICATION_SIZE {
+		return network.ErrPublicationTooLarge
+	}
+	pub := network.NewPublication(topic, data)
+	contacts := kademlia.LookupContact(routing.NewKademliaID(network.TopicKey(topic)))
+	if len(contacts) == 0 {
+		return errors.New("no suitable contacts found for publishing")
+	}
+
+	var lastErr error
+	accepted := 0
+	for _, contact := range contacts {
+		err := rpc.SendPublishMessage(kademlia.network, &contact, pub)
+		if err != nil {
+			kademlia.logger.Info("Could not publish", "rpc", "PUBLISH", "peer", contact.String(), "topic", topic, "err", err)
+			lastErr = err
+		} else {
+			accepted++
+		}
+	}
+
+	if accepted == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// Add this node as a subscriber of `topic` at the closest nodes of its key
+func (kademlia *Kademlia) announceSubscription(topic string) error {
+	key := network.TopicKey(topic)
+	contacts := kademlia.LookupContact(routing.NewKademliaID(key))
+	if len(contacts) == 0 {
+		return errors.New("no suitable contacts found for subscribing")
+	}
+
+	var lastErr error
+	accepted := 0
+	for _, contact := range contacts {
+		err := rpc.SendSubscribeMessage(kademlia.network, &contact, key, network.NETWORK_DEFAULT_SUBSCRIPTION_TTL)
+		if err != nil {
+			kademlia.logger.Info("Could not subscribe", "rpc", "SUBSCRIBE", "peer", contact.String(), "topic", topic, "err", err)
+			lastErr = err
+		} else {
+			accepted++
+		}
+	}
+
+	if accepted == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// Announce the subscription to `topic` again, well before the subscriber
+// expires, until `done` is closed. The closest nodes may also have changed
+// since the last announcement.
+func (kademlia *Kademlia) renewSubscription(topic string, done <-chan struct{}) {
+	ticker := time.NewTicker(network.NETWORK_DEFAULT_SUBSCRIPTION_TTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := kademlia.announceSubscription(topic); err != nil {
+				kademlia.logger.Warn("Could not renew subscription", "topic", topic, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package record
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+)
+
+var ErrInvalidKeyFile = errors.New("invalid key file")
+
+// Generate a new key pair and write its private key to `path`, readable only
+// by the owner.
+//
+// Returns the private key.
+func GenerateKeyFile(path string) (ed25519.PrivateKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := hex.EncodeToString(privateKey.Seed())
+	if err := os.WriteFile(path, []byte(seed+"\n"), 0o600); err != nil {
+		return nil, err
+	}
+	return privateKey, nil
+}
+
+// Read a private key written by GenerateKeyFile.
+func ReadKeyFile(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, ErrInvalidKeyFile
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,106 @@
+package record
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"d7024e/util"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrInvalidPublicKey = errors.New("invalid public key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrKeyMismatch      = errors.New("record does not belong to key")
+)
+
+// Prefix of the signed bytes, so a record signature can never be mistaken
+// for a signature over anything else.
+const signaturePrefix = "kademlia-record:"
+
+// A mutable value published under the hash of a public key. Only the holder
+// of the matching private key can sign new versions of the record, and a
+// higher sequence number marks a newer version.
+type Record struct {
+	PublicKey ed25519.PublicKey
+	Sequence  uint64
+	Value     []byte
+	Signature []byte
+}
+
+// Create and sign a new version of a record.
+//
+// Parameters:
+//
+//	`privateKey` - The key to sign the record with.
+//	`sequence` - Must be higher than the sequence of any earlier version.
+//	`value` - The value of the record.
+func New(privateKey ed25519.PrivateKey, sequence uint64, value []byte) *Record {
+	record := &Record{
+		PublicKey: privateKey.Public().(ed25519.PublicKey),
+		Sequence:  sequence,
+		Value:     value,
+	}
+	record.Signature = ed25519.Sign(privateKey, record.signedBytes())
+	return record
+}
+
+// Get the key records signed by a public key are stored under.
+func Key(publicKey ed25519.PublicKey) string {
+	return util.Hash(publicKey)
+}
+
+// Get the key the record is stored under.
+func (record *Record) Key() string {
+	return Key(record.PublicKey)
+}
+
+// Check that the record is signed by its public key.
+func (record *Record) Verify() error {
+	if len(record.PublicKey) != ed25519.PublicKeySize {
+		return ErrInvalidPublicKey
+	}
+	if !ed25519.Verify(record.PublicKey, record.signedBytes(), record.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Check that the record is signed by its public key and stored under the
+// key of that public key.
+func (record *Record) VerifyKey(key string) error {
+	if record.Key() != key {
+		return ErrKeyMismatch
+	}
+	return record.Verify()
+}
+
+// Check if the record is a newer version than `other`. Both records are
+// assumed to be verified and to share the same key.
+func (record *Record) NewerThan(other *Record) bool {
+	return other == nil || record.Sequence > other.Sequence
+}
+
+func (record *Record) signedBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(signaturePrefix)
+	binary.Write(&buf, binary.BigEndian, record.Sequence)
+	buf.Write(record.Value)
+	return buf.Bytes()
+}
+
+// Serialize a record, e.g. to send it over the network.
+func Encode(record *Record) []byte {
+	data, _ := json.Marshal(record)
+	return data
+}
+
+// Deserialize a record. The record is not verified.
+func Decode(data []byte) (*Record, error) {
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,87 @@
+package record
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_ShouldCreateVerifiableRecord(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	record := New(privateKey, 1, []byte("value"))
+
+	assert.Nil(t, record.Verify())
+	assert.Nil(t, record.VerifyKey(Key(publicKey)))
+	assert.Equal(t, Key(publicKey), record.Key())
+}
+
+func TestVerify_WithTamperedRecord_ShouldFail(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherPublicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	var tests = []struct {
+		name     string
+		tamper   func(record *Record)
+		expected error
+	}{
+		{"value", func(record *Record) { record.Value = []byte("other") }, ErrInvalidSignature},
+		{"sequence", func(record *Record) { record.Sequence++ }, ErrInvalidSignature},
+		{"public key", func(record *Record) { record.PublicKey = otherPublicKey }, ErrInvalidSignature},
+		{"short public key", func(record *Record) { record.PublicKey = record.PublicKey[:4] }, ErrInvalidPublicKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			record := New(privateKey, 1, []byte("value"))
+			test.tamper(record)
+
+			assert.ErrorIs(t, record.Verify(), test.expected)
+		})
+	}
+}
+
+func TestVerifyKey_WithOtherKey_ShouldFail(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherPublicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	record := New(privateKey, 1, []byte("value"))
+
+	assert.ErrorIs(t, record.VerifyKey(Key(otherPublicKey)), ErrKeyMismatch)
+}
+
+func TestNewerThan(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	older := New(privateKey, 1, []byte("old"))
+	newer := New(privateKey, 2, []byte("new"))
+
+	assert.True(t, newer.NewerThan(older))
+	assert.True(t, newer.NewerThan(nil))
+	assert.False(t, older.NewerThan(newer))
+	assert.False(t, newer.NewerThan(newer))
+}
+
+func TestEncode(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	expected := New(privateKey, 7, []byte{0, 1, 255})
+
+	actual, err := Decode(Encode(expected))
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+	assert.Nil(t, actual.Verify())
+}
+
+func TestKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.key")
+
+	expected, errGenerate := GenerateKeyFile(path)
+	actual, errRead := ReadKeyFile(path)
+
+	assert.Nil(t, errGenerate)
+	assert.Nil(t, errRead)
+	assert.Equal(t, expected, actual)
+}
@@ -0,0 +1,112 @@
+package kademlia
+
+import (
+	"sync"
+	"time"
+)
+
+// A node remembers the dataobjects it published, and stores each again at the
+// closest nodes of its hash at half its TTL, well before the replicas expire.
+// A node that still holds the replica refreshes it with the TTL. The closest
+// nodes may also have changed since, or evicted their replica. A dataobject is
+// no longer republished once it is forgotten with ForgetData.
+
+// How often the node looks for dataobjects that are due to be republished
+const KADEMLIA_REPUBLISH_CHECK_INTERVAL = time.Minute
+
+// A dataobject this node published
+type publishedObject struct {
+	key   string
+	value []byte
+	// TTL it is stored with, zero for the default TTL of each node
+	ttl time.Duration
+	// How long after it is stored it is stored again
+	interval time.Duration
+	// When it is stored again next
+	due time.Time
+}
+
+// The dataobjects this node published, by key
+type publishedObjects struct {
+	lock    sync.Mutex
+	objects map[string]*publishedObject
+}
+
+func newPublishedObjects() *publishedObjects {
+	return &publishedObjects{objects: make(map[string]*publishedObject)}
+}
+
+// Remember that a dataobject was stored at `now`, replacing what was
+// remembered of it before. It is stored again at half of `lifetime`, how long
+// the nodes keep it.
+func (published *publishedObjects) add(key string, value []byte, ttl time.Duration, lifetime time.Duration, now time.Time) {
+	published.lock.Lock()
+	defer published.lock.Unlock()
+
+	published.objects[key] = &publishedObject{key: key, value: value, ttl: ttl, interval: lifetime / 2, due: now.Add(lifetime / 2)}
+}
+
+// Forget a dataobject, so it is not stored again
+func (published *publishedObjects) remove(key string) {
+	published.lock.Lock()
+	defer published.lock.Unlock()
+
+	delete(published.objects, key)
+}
+
+// Get the dataobjects that are due to be stored again at `now`, and schedule
+// the next time for each of them
+func (published *publishedObjects) due(now time.Time) []publishedObject {
+	published.lock.Lock()
+	defer published.lock.Unlock()
+
+	due := []publishedObject{}
+	for _, object := range published.objects {
+		if now.Before(object.due) {
+			continue
+		}
+		object.due = now.Add(object.interval)
+		due = append(due, *object)
+	}
+	return due
+}
+
+// Start republishing the dataobjects this node published. Does nothing if it
+// already started.
+func (kademlia *Kademlia) StartRepublishing() {
+	kademlia.republishing.Do(func() {
+		go kademlia.republishPublished()
+	})
+}
+
+// Store the dataobjects this node published again when they are due, until
+// the node stops
+func (kademlia *Kademlia) republishPublished() {
+	ticker := time.NewTicker(KADEMLIA_REPUBLISH_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		kademlia.republishDue(now)
+	}
+}
+
+// Remember a dataobject this node published, to be republished
+func (kademlia *Kademlia) rememberPublished(key string, value []byte, ttl time.Duration) {
+	lifetime := ttl
+	if lifetime == 0 {
+		lifetime = kademlia.ttl
+	}
+	kademlia.published.add(key, value, ttl, lifetime, time.Now())
+}
+
+// Store the dataobjects this node published that are due at `now` again
+func (kademlia *Kademlia) republishDue(now time.Time) {
+	for _, object := range kademlia.published.due(now) {
+		stored, err := kademlia.Republish(object.key, object.value, object.ttl, kademlia.GetPublicKey())
+		if err != nil {
+			kademlia.logger.Warn("Could not republish dataobject", "key", object.key, "err", err)
+			continue
+		}
+		kademlia.logger.Info("Republished dataobject", "key", object.key, "nodes", stored)
+	}
+}
@@ -0,0 +1,114 @@
+package kademlia
+
+import (
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/datastore"
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/network/routing"
+	"d7024e/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPublishedObjects_ShouldBeDueAtHalfTheirLifetime(t *testing.T) {
+	published := newPublishedObjects()
+	now := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	published.add("key1", []byte("value1"), 0, time.Hour, now)
+
+	assert.Empty(t, published.due(now.Add(29*time.Minute)))
+	due := published.due(now.Add(30 * time.Minute))
+	assert.Len(t, due, 1)
+	assert.Equal(t, "key1", due[0].key)
+	assert.Equal(t, time.Duration(0), due[0].ttl)
+	assert.Empty(t, published.due(now.Add(59*time.Minute)))
+	assert.Len(t, published.due(now.Add(60*time.Minute)), 1)
+}
+
+func TestPublishedObjects_WhenRemoved_ShouldNotBeDue(t *testing.T) {
+	published := newPublishedObjects()
+	now := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	published.add("key1", []byte("value1"), time.Minute, time.Minute, now)
+
+	published.remove("key1")
+
+	assert.Empty(t, published.due(now.Add(time.Hour)))
+}
+
+func TestRepublishDue_ShouldStoreWhatThisNodePublished(t *testing.T) {
+	data := []byte("value")
+	hash := util.Hash(data)
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000F"), "nodeA")
+
+	// Create network messages
+	findNode_Request := network.NetworkMessage{BodyDigest: "1"}
+	findNode_Response := network.NetworkMessage{BodyDigest: "2", Contacts: []routing.Contact{}}
+	store_Request := network.NetworkMessage{BodyDigest: "3", TTL: time.Hour}
+	store_Response := network.NetworkMessage{BodyDigest: "4", Body: []byte("true")}
+
+	// Setup mocks
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", mock.Anything, mock.Anything).Return([]routing.Contact{nodeA})
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_FIND_NODE, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&findNode_Request)
+	networkMock.On("NewNetworkMessage", network.MESSAGE_RPC_STORE, mock.Anything, mock.Anything, hash, data, mock.Anything).Return(&store_Request)
+	networkMock.On("SendMessageWithResponse", findNode_Request).Return(findNode_Response, false)
+	isStore := mock.MatchedBy(func(msg network.NetworkMessage) bool { return msg.BodyDigest == store_Request.BodyDigest })
+	networkMock.On("SendMessageWithResponse", isStore).Return(store_Response, false).Twice()
+
+	// Run test
+	kademlia := NewKademlia(&me, networkMock, nil)
+	_, err := kademlia.Store(data, time.Hour)
+	kademlia.republishDue(time.Now().Add(time.Hour))
+
+	assert.Nil(t, err)
+	networkMock.AssertNumberOfCalls(t, "SendMessageWithResponse", 4)
+	assert.Equal(t, kademlia.GetPublicKey(), store_Request.PublicKey)
+}
+
+func TestRepublishDue_ShouldRefreshReplicaAtHolder(t *testing.T) {
+	data := []byte("value")
+	hash := util.Hash(data)
+	startTime := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeprovider := util.NewSyncTimeProvider(startTime)
+	networkA, _ := network.CreateTestNetwork(14041)
+	networkB, _ := network.NewNetwork(14048, datastore.NewDataStore(time.Hour, nil, timeprovider))
+	networkA.GetRoutingTable().AddContact(*networkB.GetMe())
+
+	go networkA.Listen()
+	go networkB.Listen()
+	defer networkA.StopListen()
+	defer networkB.StopListen()
+	time.Sleep(20 * time.Millisecond)
+
+	// Run test
+	kademlia := NewKademlia(networkA.GetMe(), networkA, networkA.GetDatastore())
+	_, err := kademlia.Store(data, time.Hour)
+	timeprovider.Set(startTime.Add(45 * time.Minute))
+	kademlia.republishDue(time.Now().Add(30 * time.Minute))
+	ttl, exists := networkB.GetDatastore().TTL(hash)
+
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, time.Hour, ttl)
+}
+
+func TestRepublishDue_WhenForgotten_ShouldNotStore(t *testing.T) {
+	data := []byte("value")
+	hash := util.Hash(data)
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+
+	// Run test
+	networkMock := new(mocks.NetworkMockObject)
+	kademlia := NewKademlia(&me, networkMock, nil)
+	kademlia.rememberPublished(hash, data, 0)
+	kademlia.ForgetData(hash, []routing.Contact{})
+	kademlia.republishDue(time.Now().Add(kademlia.ttl))
+
+	networkMock.AssertNotCalled(t, "SendMessageWithResponse", mock.Anything)
+}
@@ -0,0 +1,39 @@
+package kademlia
+
+import (
+	"d7024e/kademlia/network"
+	"time"
+)
+
+// States of a node joining the network
+const (
+	JOIN_STATE_NOT_JOINED = "not joined"
+	JOIN_STATE_JOINING    = "joining"
+	JOIN_STATE_JOINED     = "joined"
+	JOIN_STATE_FAILED     = "failed"
+)
+
+func (kademlia *Kademlia) Status() network.NodeStatus {
+	// The network holds the addresses the node currently advertises
+	me := kademlia.network.GetMe()
+	status := network.NodeStatus{
+		ID:        me.ID.String(),
+		Address:   me.Address,
+		Addresses: me.Addresses,
+		Uptime:    time.Since(kademlia.started),
+		JoinState: JOIN_STATE_NOT_JOINED,
+		Buckets:   []network.BucketFill{},
+		Store:     kademlia.dataStore.Stats(),
+		Network:   kademlia.network.GetStats(),
+	}
+	if state, ok := kademlia.joinState.Load().(string); ok {
+		status.JoinState = state
+	}
+	for index, contacts := range kademlia.network.GetRoutingTable().BucketLengths() {
+		if contacts > 0 {
+			status.Contacts += contacts
+			status.Buckets = append(status.Buckets, network.BucketFill{Index: index, Contacts: contacts})
+		}
+	}
+	return status
+}
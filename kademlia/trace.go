@@ -0,0 +1,57 @@
+package kademlia
+
+import (
+	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
+	"sync"
+	"time"
+)
+
+// Parent of the traced steps that ask contacts from the routing table
+const TRACE_NO_PARENT = -1
+
+// Records the find node messages of a lookup. A nil tracer records nothing.
+type lookupTracer struct {
+	lock  sync.Mutex
+	steps []rpc.TraceStep
+}
+
+// Record a find node message sent to `contact`, and the contacts it returned.
+// Returns the index of the step, to be the parent of the steps that ask the
+// returned contacts.
+func (tracer *lookupTracer) record(parent int, contact routing.Contact, targetID *routing.KademliaID, rtt time.Duration, timeout bool, returned []routing.Contact) int {
+	if tracer == nil {
+		return TRACE_NO_PARENT
+	}
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+
+	round := 1
+	if parent != TRACE_NO_PARENT {
+		round = tracer.steps[parent].Round + 1
+	}
+	tracer.steps = append(tracer.steps, rpc.TraceStep{
+		Parent:   parent,
+		Round:    round,
+		Contact:  rpc.NewTracedContact(contact),
+		RTT:      rtt,
+		Timeout:  timeout,
+		Returned: rpc.NewTracedContacts(returned),
+		Closer:   len(returned) > 0 && returned[0].ID.CalcDistance(targetID).Less(contact.ID.CalcDistance(targetID)),
+	})
+	return len(tracer.steps) - 1
+}
+
+func (kademlia *Kademlia) TraceLookup(targetID *routing.KademliaID) rpc.LookupTrace {
+	started := time.Now()
+	tracer := &lookupTracer{steps: []rpc.TraceStep{}}
+	contacts, hops := kademlia.lookupContact(targetID, tracer)
+	observeLookup(LOOKUP_KIND_NODE, hops, started, len(contacts) > 0)
+
+	return rpc.LookupTrace{
+		Target:   targetID.String(),
+		Steps:    tracer.steps,
+		Closest:  rpc.NewTracedContacts(contacts),
+		Duration: time.Since(started),
+	}
+}
@@ -0,0 +1,63 @@
+package kademlia
+
+import (
+	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTraceLookup_ShouldRecordEveryStep(t *testing.T) {
+	/*
+		Routes:
+		- node A -> [node B]
+		- node B -> []
+	*/
+	targetId := routing.NewKademliaID("0000000000000000000000000000000000000000")
+	me := routing.NewContact(routing.NewKademliaID("0000000000000000000000000000000000000000"), "node0")
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000001f"), "nodeA")
+	nodeB := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000f"), "nodeB")
+
+	nodeA_Request := network.NetworkMessage{BodyDigest: "2"}
+	nodeA_Response := network.NetworkMessage{BodyDigest: "3", Contacts: []routing.Contact{nodeB}}
+	nodeB_Request := network.NetworkMessage{BodyDigest: "4"}
+	nodeB_Response := network.NetworkMessage{BodyDigest: "5", Contacts: []routing.Contact{}}
+
+	networkMock := new(mocks.NetworkMockObject)
+	routingMock := new(mocks.RoutingTableMockObject)
+	networkMock.On("GetMe").Return(&me)
+	networkMock.On("GetRoutingTable").Return(routingMock)
+	routingMock.On("FindClosestContacts", targetId, mock.Anything).Return([]routing.Contact{nodeA})
+	networkMock.On("NewNetworkMessage", mock.Anything, mock.Anything, &nodeA, mock.Anything, mock.Anything, mock.Anything).Return(&nodeA_Request)
+	networkMock.On("NewNetworkMessage", mock.Anything, mock.Anything, &nodeB, mock.Anything, mock.Anything, mock.Anything).Return(&nodeB_Request)
+	networkMock.On("SendMessageWithResponse", nodeA_Request).Return(nodeA_Response, false)
+	networkMock.On("SendMessageWithResponse", nodeB_Request).Return(nodeB_Response, false)
+
+	kademlia := NewKademlia(&me, networkMock, nil)
+	trace := kademlia.TraceLookup(targetId)
+
+	assert.Equal(t, targetId.String(), trace.Target)
+	assert.Equal(t, 2, len(trace.Steps))
+	assert.Equal(t, TRACE_NO_PARENT, trace.Steps[0].Parent)
+	assert.Equal(t, 1, trace.Steps[0].Round)
+	assert.Equal(t, rpc.NewTracedContact(nodeA), trace.Steps[0].Contact)
+	assert.Equal(t, []rpc.TracedContact{rpc.NewTracedContact(nodeB)}, trace.Steps[0].Returned)
+	assert.True(t, trace.Steps[0].Closer)
+	assert.Equal(t, 0, trace.Steps[1].Parent)
+	assert.Equal(t, 2, trace.Steps[1].Round)
+	assert.False(t, trace.Steps[1].Closer)
+	assert.Equal(t, []rpc.TracedContact{rpc.NewTracedContact(nodeB), rpc.NewTracedContact(nodeA)}, trace.Closest)
+}
+
+func TestLookupTracer_WhenNil_ShouldRecordNothing(t *testing.T) {
+	var tracer *lookupTracer
+	contact := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000001f"), "nodeA")
+
+	step := tracer.record(TRACE_NO_PARENT, contact, contact.ID, 0, true, nil)
+
+	assert.Equal(t, TRACE_NO_PARENT, step)
+}
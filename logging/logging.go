@@ -0,0 +1,226 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A logger writes leveled messages with key-value fields, e.g.
+//
+//	logger.Warn("Store timeout", "peer", contact.Address)
+//
+// as text or as one JSON object per line. Loggers made with With share the
+// output of their parent and add fields to every message.
+//
+// A nil *Logger discards every message, so components that are not given a
+// logger stay silent.
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (level Level) String() string {
+	if level < LevelDebug || level > LevelError {
+		return "level(" + strconv.Itoa(int(level)) + ")"
+	}
+	return levelNames[level]
+}
+
+// Parse a level from its name: debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+}
+
+type Format int
+
+const (
+	FormatText Format = iota
+	FormatJSON
+)
+
+// Parse a format from its name: text or json
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return 0, fmt.Errorf("unknown log format %q, expected text or json", name)
+}
+
+type Logger struct {
+	output *output
+	level  Level
+	// Key-value pairs added to every message
+	fields []interface{}
+}
+
+// Loggers made with With write to the same output under the same lock
+type output struct {
+	lock   sync.Mutex
+	w      io.Writer
+	format Format
+	now    func() time.Time
+}
+
+// Create a logger that writes messages of `level` and above to `w`
+func New(w io.Writer, level Level, format Format) *Logger {
+	return &Logger{output: &output{w: w, format: format, now: time.Now}, level: level}
+}
+
+var (
+	defaultLock   sync.Mutex
+	defaultLogger *Logger
+)
+
+// Get the logger that components use unless they are given another one. It
+// is nil, and discards every message, until SetDefault is called.
+func Default() *Logger {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	return defaultLogger
+}
+
+// Set the logger that components use unless they are given another one.
+// Components created before keep the logger they had.
+func SetDefault(logger *Logger) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultLogger = logger
+}
+
+// Create a logger that adds the given key-value pairs to every message
+func (logger *Logger) With(keyvals ...interface{}) *Logger {
+	if logger == nil {
+		return nil
+	}
+	fields := make([]interface{}, 0, len(logger.fields)+len(keyvals))
+	fields = append(append(fields, logger.fields...), keyvals...)
+	return &Logger{output: logger.output, level: logger.level, fields: fields}
+}
+
+// Check if messages of `level` are written
+func (logger *Logger) Enabled(level Level) bool {
+	return logger != nil && level >= logger.level
+}
+
+func (logger *Logger) Debug(msg string, keyvals ...interface{}) {
+	logger.log(LevelDebug, msg, keyvals)
+}
+
+func (logger *Logger) Info(msg string, keyvals ...interface{}) {
+	logger.log(LevelInfo, msg, keyvals)
+}
+
+func (logger *Logger) Warn(msg string, keyvals ...interface{}) {
+	logger.log(LevelWarn, msg, keyvals)
+}
+
+func (logger *Logger) Error(msg string, keyvals ...interface{}) {
+	logger.log(LevelError, msg, keyvals)
+}
+
+func (logger *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !logger.Enabled(level) {
+		return
+	}
+	out := logger.output
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	now := out.now()
+	fields := append(append([]interface{}{}, logger.fields...), keyvals...)
+	if out.format == FormatJSON {
+		writeJSON(out.w, now, level, msg, fields)
+	} else {
+		writeText(out.w, now, level, msg, fields)
+	}
+}
+
+// Write a message as `15:04:05.000 WARN message key=value`
+func writeText(w io.Writer, now time.Time, level Level, msg string, fields []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(now.Format("15:04:05.000"))
+	sb.WriteString(" ")
+	sb.WriteString(strings.ToUpper(level.String()))
+	sb.WriteString(" ")
+	sb.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		key, value := field(fields, i)
+		sb.WriteString(" ")
+		sb.WriteString(key)
+		sb.WriteString("=")
+		sb.WriteString(quoteIfNeeded(fmt.Sprint(value)))
+	}
+	sb.WriteString("\n")
+	io.WriteString(w, sb.String())
+}
+
+// Write a message as one JSON object, with the fields after time, level and
+// msg in the order they were given
+func writeJSON(w io.Writer, now time.Time, level Level, msg string, fields []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(`{"time":`)
+	writeJSONValue(&sb, now.Format(time.RFC3339Nano))
+	sb.WriteString(`,"level":`)
+	writeJSONValue(&sb, level.String())
+	sb.WriteString(`,"msg":`)
+	writeJSONValue(&sb, msg)
+	for i := 0; i < len(fields); i += 2 {
+		key, value := field(fields, i)
+		sb.WriteString(",")
+		writeJSONValue(&sb, key)
+		sb.WriteString(":")
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		} else if stringer, ok := value.(fmt.Stringer); ok {
+			value = stringer.String()
+		}
+		writeJSONValue(&sb, value)
+	}
+	sb.WriteString("}\n")
+	io.WriteString(w, sb.String())
+}
+
+func writeJSONValue(sb *strings.Builder, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	sb.Write(data)
+}
+
+// Get the key-value pair at index i. A key without a value gets the value
+// "!MISSING".
+func field(fields []interface{}, i int) (string, interface{}) {
+	key := fmt.Sprint(fields[i])
+	if i+1 >= len(fields) {
+		return key, "!MISSING"
+	}
+	return key, fields[i+1]
+}
+
+func quoteIfNeeded(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
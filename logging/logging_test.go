@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(level Level, format Format) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := New(&buf, level, format)
+	logger.output.now = func() time.Time { return time.Date(1970, 1, 1, 12, 30, 0, 0, time.UTC) }
+	return logger, &buf
+}
+
+func TestLogger_WithText_ShouldWriteFields(t *testing.T) {
+	logger, buf := newTestLogger(LevelInfo, FormatText)
+
+	logger.With("node", "abc").Warn("Store timeout", "peer", "10.0.0.1:4000", "reason", "no response")
+
+	assert.Equal(t, "12:30:00.000 WARN Store timeout node=abc peer=10.0.0.1:4000 reason=\"no response\"\n", buf.String())
+}
+
+func TestLogger_WithJSON_ShouldWriteObject(t *testing.T) {
+	logger, buf := newTestLogger(LevelDebug, FormatJSON)
+
+	logger.Error("Journal write error", "err", errors.New("disk full"), "records", 3, "odd")
+
+	assert.Equal(t, `{"time":"1970-01-01T12:30:00Z","level":"error","msg":"Journal write error","err":"disk full","records":3,"odd":"!MISSING"}`+"\n", buf.String())
+}
+
+func TestLogger_BelowLevel_ShouldDiscard(t *testing.T) {
+	logger, buf := newTestLogger(LevelWarn, FormatText)
+
+	logger.Info("Joined network")
+	logger.Debug("Message received")
+
+	assert.Empty(t, buf.String())
+	assert.False(t, logger.Enabled(LevelInfo))
+	assert.True(t, logger.Enabled(LevelError))
+}
+
+func TestLogger_WhenNil_ShouldDiscard(t *testing.T) {
+	var logger *Logger
+
+	logger.With("node", "abc").Error("Journal write error")
+
+	assert.False(t, logger.Enabled(LevelError))
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("WARN")
+	_, unknownErr := ParseLevel("loud")
+
+	assert.Nil(t, err)
+	assert.Equal(t, LevelWarn, level)
+	assert.NotNil(t, unknownErr)
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("json")
+	_, unknownErr := ParseFormat("xml")
+
+	assert.Nil(t, err)
+	assert.Equal(t, FormatJSON, format)
+	assert.NotNil(t, unknownErr)
+}
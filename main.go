@@ -1,55 +1,154 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"d7024e/cli"
+	"d7024e/config"
 	"d7024e/kademlia"
 	"d7024e/kademlia/datastore"
 	"d7024e/kademlia/network"
 	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/record"
+	"d7024e/logging"
 	"d7024e/rest"
 	"d7024e/util"
 	"flag"
-	"io"
-	"log"
+	"fmt"
 	"os"
-	"strconv"
+	"path/filepath"
 	"time"
 )
 
-func init() {
-	log.SetFlags(log.Ltime | log.Lshortfile)
-}
+const (
+	// File in the data directory that holds the key the node publishes data
+	// with
+	IDENTITY_FILENAME = "node.key"
+
+	// Directory in the data directory that holds the metadata store
+	METADATA_DIRNAME = "metadata"
+)
 
 func main() {
-	port, bootstrapAddress, verbose := retriveProgramParameters()
-	if !*verbose {
-		log.SetOutput(io.Discard)
+	config, err := config.Load(flag.CommandLine, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		fatal(err)
 	}
+	logging.SetDefault(createLogger(config))
 
 	timeprovider := &util.TimeProvider{}
-	datastore := datastore.NewDataStore(time.Hour, nil, timeprovider)
-	bootstrap := routing.NewContact(nil, *bootstrapAddress)
-	network, me := network.NewNetwork(*port, datastore)
+	datastore := createDataStore(config, timeprovider)
+	metadata := createMetadataStore(config, timeprovider)
+	bootstrap := routing.NewContact(nil, config.BootstrapNode)
+	network, me := network.NewNetwork(config.Port, datastore)
+	network.SetMetadataStore(metadata)
+	if err := network.Configure(config); err != nil {
+		fatal(err)
+	}
+	setDataStoreCapacity(datastore, config, me.ID)
 	context := kademlia.NewKademlia(me, network, datastore)
+	context.Configure(config)
+	if config.DataDir != "" {
+		context.SetIdentity(loadIdentity(config.DataDir))
+	}
+	if err := context.AddExpiryActions(config.ExpiryActions); err != nil {
+		fatal(err)
+	}
 	cli := cli.NewCli(os.Stdout, os.Stdin, context)
 
-	go network.Listen() // TODO: Notify it is actually listening
+	go func() { // TODO: Notify it is actually listening
+		if err := network.Listen(); err != nil {
+			fatal(err)
+		}
+	}()
 	time.Sleep(1 * time.Second)
 	go context.JoinNetwork(&bootstrap, 60)
-	go rest.Restful(context)
+	go func() {
+		if err := rest.Restful(context, config.RESTAddress); err != nil {
+			fatal(fmt.Errorf("REST API stopped: %w", err))
+		}
+	}()
 	cli.Open(true)
 }
 
-func retriveProgramParameters() (port *int, bootstrapNode *string, verbose *bool) {
-	env_port, _ := strconv.Atoi(os.Getenv("KADEMLIA_PORT"))
-	env_verbose, _ := strconv.ParseBool(os.Getenv("KADEMLIA_VERBOSE"))
-	env_bootstrapNode := os.Getenv("KADEMLIA_BOOTSTRAP_NODE")
+// Create the logger of the node as given by the config. Without verbose,
+// nothing is logged.
+func createLogger(config config.Config) *logging.Logger {
+	if !config.Verbose {
+		return nil
+	}
+	// The level and format are checked when the config is loaded
+	level, _ := logging.ParseLevel(config.LogLevel)
+	format, _ := logging.ParseFormat(config.LogFormat)
+	return logging.New(os.Stderr, level, format)
+}
+
+// Log an error that the node cannot recover from, and exit. The error is
+// printed even if logging is off.
+func fatal(err error) {
+	if logger := logging.Default(); logger.Enabled(logging.LevelError) {
+		logger.Error(err.Error())
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(1)
+}
+
+// Create an in-memory datastore, or one persisted to the data directory if it
+// is set, and add its size to the metrics of the node.
+func createDataStore(config config.Config, timeprovider util.ITimeProvider) *datastore.DataStore {
+	var store *datastore.DataStore
+	if config.DataDir == "" {
+		store = datastore.NewDataStore(config.TTL, nil, timeprovider)
+	} else {
+		var err error
+		store, err = datastore.NewDiskDataStore(config.DataDir, config.TTL, nil, timeprovider)
+		if err != nil {
+			fatal(fmt.Errorf("could not open datastore in %s: %w", config.DataDir, err))
+		}
+	}
+	datastore.RegisterMetrics(store)
+	return store
+}
 
-	port = flag.Int("p", env_port, "Portnumber")
-	verbose = flag.Bool("v", env_verbose, "Indicates if a log should be created")
-	bootstrapNode = flag.String("b", env_bootstrapNode, "Adress of bootstrap node")
+// Create the store the node keeps providers, subscribers, records and
+// tombstones in, persisted next to the datastore if the data directory is set
+func createMetadataStore(config config.Config, timeprovider util.ITimeProvider) *datastore.DataStore {
+	dir := ""
+	if config.DataDir != "" {
+		dir = filepath.Join(config.DataDir, METADATA_DIRNAME)
+	}
+	store, err := network.NewMetadataStore(dir, timeprovider)
+	if err != nil {
+		fatal(fmt.Errorf("could not open metadata store in %s: %w", dir, err))
+	}
+	return store
+}
 
-	flag.Parse()
+// Read the key the node publishes data with from `dataDir`, or generate it on
+// the first start.
+func loadIdentity(dataDir string) ed25519.PrivateKey {
+	path := filepath.Join(dataDir, IDENTITY_FILENAME)
+	key, err := record.ReadKeyFile(path)
+	if os.IsNotExist(err) {
+		key, err = record.GenerateKeyFile(path)
+	}
+	if err != nil {
+		fatal(fmt.Errorf("could not load identity from %s: %w", path, err))
+	}
+	return key
+}
+
+// Limit how much the datastore may hold, as given by the config.
+func setDataStoreCapacity(store *datastore.DataStore, config config.Config, me *routing.KademliaID) {
+	policy, err := datastore.ParseEvictionPolicy(config.EvictionPolicy, me)
+	if err != nil {
+		fatal(err)
+	}
 
-	return port, bootstrapNode, verbose
+	store.SetCapacity(datastore.Capacity{
+		MaxBytes:     config.MaxBytes,
+		MaxEntries:   config.MaxEntries,
+		MaxValueSize: config.MaxValueSize,
+		Policy:       policy,
+	})
 }
@@ -0,0 +1,24 @@
+package metrics
+
+// A gauge whose value is read from a function when the metrics are exported,
+// e.g. the number of entries in the datastore.
+type GaugeFunc struct {
+	fn func() float64
+}
+
+// Get the current value of the gauge
+func (g *GaugeFunc) Value() float64 {
+	return g.fn()
+}
+
+// A counter whose value is read from a function when the metrics are
+// exported, e.g. the dataobjects that expired from the datastore, which
+// counts them itself. The function must never return less than before.
+type CounterFunc struct {
+	fn func() float64
+}
+
+// Get the current value of the counter
+func (c *CounterFunc) Value() float64 {
+	return c.fn()
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Bucket upper bounds for durations in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counts observations in buckets by their value, e.g. the duration of
+// lookups. Safe for concurrent use.
+type Histogram struct {
+	lock sync.Mutex
+	// Upper bounds of the buckets, in increasing order
+	bounds []float64
+	// Observations in each bucket, and above the last bound
+	counts []uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	sorted := append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+	return &Histogram{bounds: sorted, counts: make([]uint64, len(sorted)+1)}
+}
+
+// Add an observation to the histogram
+func (h *Histogram) Observe(value float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	i := sort.SearchFloat64s(h.bounds, value)
+	h.counts[i]++
+	h.sum += value
+}
+
+// A snapshot of a histogram, with the number of observations less than or
+// equal to each upper bound
+type HistogramSnapshot struct {
+	// Upper bounds, ending with +Inf
+	Bounds     []float64
+	Cumulative []uint64
+	Sum        float64
+	Count      uint64
+}
+
+// Get a snapshot of the histogram
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	snapshot := HistogramSnapshot{
+		Bounds:     append(append([]float64(nil), h.bounds...), math.Inf(1)),
+		Cumulative: make([]uint64, len(h.counts)),
+		Sum:        h.sum,
+	}
+	for i, count := range h.counts {
+		snapshot.Count += count
+		snapshot.Cumulative[i] = snapshot.Count
+	}
+	return snapshot
+}
+
+// A set of histograms that share a name and buckets, partitioned by the
+// values of one or more labels.
+type HistogramVec struct {
+	labels     []string
+	bounds     []float64
+	lock       sync.RWMutex
+	histograms map[string]*labelledHistogram
+}
+
+type labelledHistogram struct {
+	*Histogram
+	labelValues []string
+}
+
+// Get the histogram for the given label values, creating it if needed. The
+// values must be given in the same order as the labels of the HistogramVec.
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	key := strings.Join(values, "\x00")
+
+	v.lock.RLock()
+	histogram, exists := v.histograms[key]
+	v.lock.RUnlock()
+	if exists {
+		return histogram.Histogram
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if histogram, exists = v.histograms[key]; !exists {
+		histogram = &labelledHistogram{newHistogram(v.bounds), append([]string(nil), values...)}
+		v.histograms[key] = histogram
+	}
+	return histogram.Histogram
+}
+
+// Call fn for each histogram in the HistogramVec, ordered by label values.
+func (v *HistogramVec) Each(fn func(labelValues []string, snapshot HistogramSnapshot)) {
+	v.lock.RLock()
+	keys := make([]string, 0, len(v.histograms))
+	for key := range v.histograms {
+		keys = append(keys, key)
+	}
+	v.lock.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v.lock.RLock()
+		histogram := v.histograms[key]
+		v.lock.RUnlock()
+		fn(histogram.labelValues, histogram.Snapshot())
+	}
+}
+
+// Get the names of the labels of the HistogramVec
+func (v *HistogramVec) Labels() []string {
+	return v.labels
+}
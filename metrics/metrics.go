@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A counter that only ever increases. Safe for concurrent use.
+type Counter struct {
+	value uint64
+}
+
+// Increase counter by 1
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Increase counter by n
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Get the current value of the counter
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// A set of counters that share a name, partitioned by the values of one or
+// more labels, e.g. the type of RPC.
+type CounterVec struct {
+	labels   []string
+	lock     sync.RWMutex
+	counters map[string]*labelledCounter
+}
+
+type labelledCounter struct {
+	Counter
+	labelValues []string
+}
+
+// Get the counter for the given label values, creating it if needed. The
+// values must be given in the same order as the labels of the CounterVec.
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	key := strings.Join(values, "\x00")
+
+	v.lock.RLock()
+	counter, exists := v.counters[key]
+	v.lock.RUnlock()
+	if exists {
+		return &counter.Counter
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if counter, exists = v.counters[key]; !exists {
+		counter = &labelledCounter{labelValues: append([]string(nil), values...)}
+		v.counters[key] = counter
+	}
+	return &counter.Counter
+}
+
+// Get the sum of all counters in the CounterVec
+func (v *CounterVec) Total() uint64 {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	var total uint64
+	for _, counter := range v.counters {
+		total += counter.Value()
+	}
+	return total
+}
+
+// Call fn for each counter in the CounterVec, ordered by label values.
+func (v *CounterVec) Each(fn func(labelValues []string, value uint64)) {
+	v.lock.RLock()
+	keys := make([]string, 0, len(v.counters))
+	for key := range v.counters {
+		keys = append(keys, key)
+	}
+	v.lock.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v.lock.RLock()
+		counter := v.counters[key]
+		v.lock.RUnlock()
+		fn(counter.labelValues, counter.Value())
+	}
+}
+
+// Get the names of the labels of the CounterVec
+func (v *CounterVec) Labels() []string {
+	return v.labels
+}
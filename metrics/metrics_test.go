@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	counter := new(Counter)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			counter.Inc()
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	counter.Add(5)
+
+	assert.Equal(t, uint64(105), counter.Value())
+}
+
+func TestCounterVec(t *testing.T) {
+	registry := NewRegistry()
+	vec := registry.NewCounterVec("test_total", "Test", "rpc")
+
+	vec.WithLabelValues("store").Inc()
+	vec.WithLabelValues("store").Inc()
+	vec.WithLabelValues("ping").Inc()
+
+	var actualLabels []string
+	var actualValues []uint64
+	vec.Each(func(labelValues []string, value uint64) {
+		actualLabels = append(actualLabels, labelValues[0])
+		actualValues = append(actualValues, value)
+	})
+
+	assert.Equal(t, uint64(3), vec.Total())
+	assert.Equal(t, []string{"ping", "store"}, actualLabels)
+	assert.Equal(t, []uint64{1, 2}, actualValues)
+}
+
+func TestRegistry_WithExistingName_ShouldReturnExisting(t *testing.T) {
+	registry := NewRegistry()
+
+	first := registry.NewCounter("test_total", "Test")
+	second := registry.NewCounter("test_total", "Test")
+
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, len(registry.All()))
+	assert.Panics(t, func() { registry.NewCounterVec("test_total", "Test", "rpc") })
+}
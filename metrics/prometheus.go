@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Content type of the Prometheus text format written by WritePrometheus
+const PROMETHEUS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// Write all metrics of the default registry in the Prometheus text format
+func WritePrometheus(w io.Writer) error {
+	return Default.WritePrometheus(w)
+}
+
+// Write all registered metrics in the Prometheus text format, ordered by
+// name, after calling the functions added with OnScrape
+func (registry *Registry) WritePrometheus(w io.Writer) error {
+	registry.scrape()
+	out := bufio.NewWriter(w)
+	for _, metric := range registry.All() {
+		writeMetric(out, metric)
+	}
+	return out.Flush()
+}
+
+func writeMetric(out *bufio.Writer, metric *Metric) {
+	fmt.Fprintf(out, "# HELP %s %s\n", metric.Name, escapeHelp(metric.Help))
+
+	switch value := metric.Value.(type) {
+	case *Counter:
+		fmt.Fprintf(out, "# TYPE %s counter\n", metric.Name)
+		fmt.Fprintf(out, "%s %d\n", metric.Name, value.Value())
+	case *CounterVec:
+		fmt.Fprintf(out, "# TYPE %s counter\n", metric.Name)
+		value.Each(func(labelValues []string, count uint64) {
+			fmt.Fprintf(out, "%s%s %d\n", metric.Name, formatLabels(value.Labels(), labelValues), count)
+		})
+	case *GaugeFunc:
+		fmt.Fprintf(out, "# TYPE %s gauge\n", metric.Name)
+		fmt.Fprintf(out, "%s %s\n", metric.Name, formatFloat(value.Value()))
+	case *CounterFunc:
+		fmt.Fprintf(out, "# TYPE %s counter\n", metric.Name)
+		fmt.Fprintf(out, "%s %s\n", metric.Name, formatFloat(value.Value()))
+	case *Histogram:
+		fmt.Fprintf(out, "# TYPE %s histogram\n", metric.Name)
+		writeHistogram(out, metric.Name, nil, nil, value.Snapshot())
+	case *HistogramVec:
+		fmt.Fprintf(out, "# TYPE %s histogram\n", metric.Name)
+		value.Each(func(labelValues []string, snapshot HistogramSnapshot) {
+			writeHistogram(out, metric.Name, value.Labels(), labelValues, snapshot)
+		})
+	}
+}
+
+func writeHistogram(out *bufio.Writer, name string, labels, labelValues []string, snapshot HistogramSnapshot) {
+	bucketLabels := append(append([]string(nil), labels...), "le")
+	for i, bound := range snapshot.Bounds {
+		bucketValues := append(append([]string(nil), labelValues...), formatFloat(bound))
+		fmt.Fprintf(out, "%s_bucket%s %d\n", name, formatLabels(bucketLabels, bucketValues), snapshot.Cumulative[i])
+	}
+	fmt.Fprintf(out, "%s_sum%s %s\n", name, formatLabels(labels, labelValues), formatFloat(snapshot.Sum))
+	fmt.Fprintf(out, "%s_count%s %d\n", name, formatLabels(labels, labelValues), snapshot.Count)
+}
+
+// Format labels as {name="value",...}, or nothing if there are none
+func formatLabels(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", label, escapeLabelValue(value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrometheus_ShouldWriteEveryType(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("test_total", "A counter.").Add(3)
+	vec := registry.NewCounterVec("test_rpc_total", "A counter\nvector.", "rpc")
+	vec.WithLabelValues(`say "hi"`).Inc()
+	registry.NewGaugeFunc("test_entries", "A gauge.", func() float64 { return 1.5 })
+	registry.NewCounterFunc("test_expired_total", "A counter function.", func() float64 { return 2 })
+	histogram := registry.NewHistogram("test_seconds", "A histogram.", []float64{1, 0.5})
+	histogram.Observe(0.2)
+	histogram.Observe(0.7)
+	histogram.Observe(3)
+	registry.NewHistogramVec("test_hops", "A histogram vector.", []float64{1}, "kind").WithLabelValues("node").Observe(1)
+
+	var buf bytes.Buffer
+	err := registry.WritePrometheus(&buf)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `# HELP test_entries A gauge.
+# TYPE test_entries gauge
+test_entries 1.5
+# HELP test_expired_total A counter function.
+# TYPE test_expired_total counter
+test_expired_total 2
+# HELP test_hops A histogram vector.
+# TYPE test_hops histogram
+test_hops_bucket{kind="node",le="1"} 1
+test_hops_bucket{kind="node",le="+Inf"} 1
+test_hops_sum{kind="node"} 1
+test_hops_count{kind="node"} 1
+# HELP test_rpc_total A counter\nvector.
+# TYPE test_rpc_total counter
+test_rpc_total{rpc="say \"hi\""} 1
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.5"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 3.9
+test_seconds_count 3
+# HELP test_total A counter.
+# TYPE test_total counter
+test_total 3
+`, buf.String())
+}
+
+func TestWritePrometheus_ShouldScrapeOnceBeforeReadingValues(t *testing.T) {
+	registry := NewRegistry()
+	scrapes := 0
+	registry.OnScrape(func() { scrapes++ })
+	registry.NewGaugeFunc("test_entries", "A gauge.", func() float64 { return float64(scrapes) })
+	registry.NewGaugeFunc("test_bytes", "A gauge.", func() float64 { return float64(scrapes) })
+
+	var buf bytes.Buffer
+	err := registry.WritePrometheus(&buf)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, scrapes)
+	assert.Contains(t, buf.String(), "test_entries 1\n")
+	assert.Contains(t, buf.String(), "test_bytes 1\n")
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Metrics of a node are registered in a registry under a unique name, so they
+// can be listed and exported. Names follow the Prometheus conventions, e.g.
+// "kademlia_rpc_sent_total".
+type Registry struct {
+	lock    sync.Mutex
+	metrics map[string]*Metric
+	// Called before each export, in the order they were added
+	scrapeHooks []func()
+}
+
+// A registered metric
+type Metric struct {
+	Name string
+	Help string
+	// One of *Counter, *CounterVec, *GaugeFunc, *CounterFunc, *Histogram or
+	// *HistogramVec
+	Value interface{}
+}
+
+// The registry used by all packages of the node
+var Default = NewRegistry()
+
+// Create a new, empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*Metric)}
+}
+
+// Create a counter in the default registry. If a counter with the same name
+// is already registered, that counter is returned.
+func NewCounter(name, help string) *Counter {
+	return Default.NewCounter(name, help)
+}
+
+// Create a counter vector in the default registry. If a counter vector with
+// the same name is already registered, that counter vector is returned.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// Create a gauge in the default registry that reads its value from fn. If a
+// gauge with the same name is already registered, that gauge is returned and
+// keeps its function.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, fn)
+}
+
+// Create a counter in the default registry that reads its value from fn. If
+// a counter with the same name is already registered, that counter is
+// returned and keeps its function.
+func NewCounterFunc(name, help string, fn func() float64) *CounterFunc {
+	return Default.NewCounterFunc(name, help, fn)
+}
+
+// Call fn before the metrics of the default registry are exported, e.g. to
+// read what several functions of gauges and counters report in one go.
+func OnScrape(fn func()) {
+	Default.OnScrape(fn)
+}
+
+// Create a histogram in the default registry with the given bucket upper
+// bounds. If a histogram with the same name is already registered, that
+// histogram is returned.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return Default.NewHistogram(name, help, buckets)
+}
+
+// Create a histogram vector in the default registry with the given bucket
+// upper bounds. If a histogram vector with the same name is already
+// registered, that histogram vector is returned.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+func (registry *Registry) NewCounter(name, help string) *Counter {
+	return registry.register(name, help, func() interface{} { return new(Counter) }).(*Counter)
+}
+
+func (registry *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return registry.register(name, help, func() interface{} {
+		return &CounterVec{labels: labels, counters: make(map[string]*labelledCounter)}
+	}).(*CounterVec)
+}
+
+func (registry *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return registry.register(name, help, func() interface{} { return &GaugeFunc{fn} }).(*GaugeFunc)
+}
+
+func (registry *Registry) NewCounterFunc(name, help string, fn func() float64) *CounterFunc {
+	return registry.register(name, help, func() interface{} { return &CounterFunc{fn} }).(*CounterFunc)
+}
+
+func (registry *Registry) OnScrape(fn func()) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.scrapeHooks = append(registry.scrapeHooks, fn)
+}
+
+// Call the functions added with OnScrape
+func (registry *Registry) scrape() {
+	registry.lock.Lock()
+	hooks := registry.scrapeHooks
+	registry.lock.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+func (registry *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	return registry.register(name, help, func() interface{} { return newHistogram(buckets) }).(*Histogram)
+}
+
+func (registry *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return registry.register(name, help, func() interface{} {
+		return &HistogramVec{labels: labels, bounds: buckets, histograms: make(map[string]*labelledHistogram)}
+	}).(*HistogramVec)
+}
+
+// Get a metric by name, or nil if no metric is registered with the name.
+func (registry *Registry) Get(name string) *Metric {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	return registry.metrics[name]
+}
+
+// Get all registered metrics, ordered by name.
+func (registry *Registry) All() []*Metric {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	all := make([]*Metric, 0, len(registry.metrics))
+	for _, metric := range registry.metrics {
+		all = append(all, metric)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+func (registry *Registry) register(name, help string, create func() interface{}) interface{} {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if metric, exists := registry.metrics[name]; exists {
+		existingType := fmt.Sprintf("%T", metric.Value)
+		if newType := fmt.Sprintf("%T", create()); existingType != newType {
+			panic(fmt.Sprintf("metric %s registered as %s, not %s", name, existingType, newType))
+		}
+		return metric.Value
+	}
+
+	metric := &Metric{Name: name, Help: help, Value: create()}
+	registry.metrics[name] = metric
+	return metric.Value
+}
@@ -3,22 +3,36 @@ package rest
 import (
 	"d7024e/cli/commands"
 	"d7024e/kademlia"
+	"d7024e/kademlia/datastore"
+	"d7024e/kademlia/encryption"
+	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/record"
+	"d7024e/metrics"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var context kademlia.IKademlia
 
 type Data struct {
-	Data     string
+	// The posted message, not echoed for raw values
+	Data     string `json:",omitempty"`
 	Location string
 }
 
 // Replies to successful put (store) requests with appropriate HTTP headers and json data and denies failed lookups
+//
+// The value is either the form field "message", or the raw body of a request
+// with Content-Type application/octet-stream. The options ttl, encrypt and
+// key are form fields, or query parameters for a raw value.
 func putHandle(w http.ResponseWriter, r *http.Request) {
 	//help from stackoverflow.com/questions/46579429/golang-cant-get-body-from-request-getbody'
 	if r.Method != "POST" {
@@ -28,56 +42,560 @@ func putHandle(w http.ResponseWriter, r *http.Request) {
 	}
 	b, err := io.ReadAll(r.Body)
 	if err == nil {
-		str := string(b[:])
-		split := strings.Split(str, "=")
-		data := split[1]
-		res, errPut := commands.PutObjectInStore(context, data)
+		var reply Data
+		var data []byte
+		var options url.Values
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "application/octet-stream" {
+			data, options = b, r.URL.Query()
+		} else {
+			options, _ = url.ParseQuery(string(b))
+			reply.Data = options.Get("message")
+			data = []byte(reply.Data)
+		}
+
+		ttl, encrypt, key, errOptions := parseStoreOptions(options)
+		if errOptions != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, errOptions.Error())
+			return
+		}
+		res, errPut := commands.PutValueInStore(context, data, ttl, encrypt, key)
 
 		w.Header().Set("Content-Type", "application/json")
 		if errPut != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 		} else {
 			w.WriteHeader(http.StatusCreated)
-			reply := Data{data, "/objects/" + res}
+			reply.Location = "/objects/" + res
 			json.NewEncoder(w).Encode(reply)
 		}
 	} else {
-		fmt.Fprintf(w, err.Error())
+		fmt.Fprint(w, err.Error())
 	}
 
 }
 
+// Parse the ttl, encrypt and key options of a put request. A key implies
+// encrypt.
+func parseStoreOptions(options url.Values) (ttl time.Duration, encrypt bool, key []byte, err error) {
+	if value := options.Get("ttl"); value != "" {
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return 0, false, nil, fmt.Errorf("invalid TTL %q", value)
+		}
+	}
+	if value := options.Get("key"); value != "" {
+		key, err = encryption.ParseKey(value)
+		return ttl, true, key, err
+	}
+	encrypt, _ = strconv.ParseBool(options.Get("encrypt"))
+	return ttl, encrypt, nil, nil
+}
+
 // Replies to get (lookup) requests with json data of the lookup target
 func getHandle(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		w.WriteHeader(http.StatusMethodNotAllowed)
-		w.Write([]byte("Invalid HTTP request, try /objects for POST"))
+		w.Write([]byte("Invalid HTTP request, try /objects for POST or /objects/{hash} for DELETE"))
 		return
 	}
 	hash := strings.Split(r.URL.Path, "/")[2]
-	str, err := commands.GetObjectByHash(context, hash)
+	asFile, _ := strconv.ParseBool(r.URL.Query().Get("file"))
+	value, ttl, err := commands.FetchObjectByHash(context, hash, asFile)
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, err.Error())
+		fmt.Fprint(w, err.Error())
 	} else {
+		// Remaining TTL in seconds
+		w.Header().Set("X-Kademlia-TTL", strconv.Itoa(int(ttl.Seconds())))
+		w.WriteHeader(http.StatusOK)
+		w.Write(value)
+	}
+
+}
+
+type ForgetSummary struct {
+	Hash string
+	// Number of nodes that held the dataobject and removed it
+	Removed int
+	Nodes   []ForgetNode
+}
+
+type ForgetNode struct {
+	ID      string
+	Address string
+	Removed bool
+	Error   string `json:",omitempty"`
+}
+
+// Replies to forget (delete) requests with how many of the closest nodes
+// removed the dataobject
+func forgetHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /objects/{hash} for DELETE"))
+		return
+	}
+	hash := strings.Split(r.URL.Path, "/")[2]
+	responses, err := commands.ForgetObjectByHash(context, hash)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+
+	summary := ForgetSummary{Hash: hash, Removed: commands.CountRemoved(responses), Nodes: []ForgetNode{}}
+	for _, response := range responses {
+		node := ForgetNode{ID: response.Contact.ID.String(), Address: response.Contact.Address, Removed: response.Removed}
+		if response.Err != nil {
+			node.Error = response.Err.Error()
+		}
+		summary.Nodes = append(summary.Nodes, node)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summary)
+}
+
+// Directs requests for a single object by their method
+func objectHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		forgetHandle(w, r)
+	} else {
+		getHandle(w, r)
+	}
+}
+
+type RecordLocation struct {
+	Key      string
+	Sequence uint64
+	Location string
+}
+
+// Publishes a signed record, given as JSON, and replies with its location
+func recordPutHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /records/{key} for GET"))
+		return
+	}
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	rec, err := record.Decode(b)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	var ttl time.Duration
+	if query := r.URL.Query().Get("ttl"); query != "" {
+		ttl, err = time.ParseDuration(query)
+		if err != nil || ttl <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "invalid TTL %q", query)
+			return
+		}
+	}
+
+	if err := context.PublishRecord(rec, ttl); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(RecordLocation{rec.Key(), rec.Sequence, "/records/" + rec.Key()})
+}
+
+// Replies with the newest version of a record as JSON
+func recordGetHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /records for POST"))
+		return
+	}
+	key := strings.Split(r.URL.Path, "/")[2]
+	rec, err := commands.FetchRecordByKey(context, key)
+
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, err.Error())
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(record.Encode(rec))
+	}
+}
+
+type Provider struct {
+	ID      string
+	Address string
+}
+
+// Parse the TTL of a provider announced through the REST API, and check that
+// its key is a valid ID. The TTL is zero if the options do not set one.
+func parseProviderOptions(key string, options url.Values) (ttl time.Duration, err error) {
+	if routing.NewKademliaID(key) == nil {
+		return 0, fmt.Errorf("invalid key %q, expected %d hex digits", key, routing.IDLength*2)
+	}
+	if value := options.Get("ttl"); value != "" {
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return 0, fmt.Errorf("invalid TTL %q", value)
+		}
+	}
+	return ttl, nil
+}
+
+// Announces this node as a provider of a key on POST, and replies with the
+// providers of a key as JSON on GET
+func providerHandle(w http.ResponseWriter, r *http.Request) {
+	key := strings.Split(r.URL.Path, "/")[2]
+	switch r.Method {
+	case "POST":
+		ttl, err := parseProviderOptions(key, r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if err := context.AnnounceProvider(key, ttl); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "GET":
+		contacts, err := commands.FetchProvidersByKey(context, key)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		providers := make([]Provider, len(contacts))
+		for i, contact := range contacts {
+			providers[i] = Provider{contact.ID.String(), contact.Address}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(providers)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /providers/{key} for GET or POST"))
+	}
+}
+
+// Publishes the body of a POST to a topic, and streams the publications to
+// a topic to a GET as server-sent events. The node is subscribed to the topic
+// while the stream is open.
+func topicHandle(w http.ResponseWriter, r *http.Request) {
+	topic := strings.TrimPrefix(r.URL.Path, "/topics/")
+	switch r.Method {
+	case "POST":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if err := context.Publish(topic, data); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "GET":
+		streamTopic(w, r, topic)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /topics/{topic} for GET or POST"))
+	}
+}
+
+// Stream the publications to a topic as server-sent events until the client
+// goes away
+func streamTopic(w http.ResponseWriter, r *http.Request, topic string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "streaming is not supported")
+		return
+	}
+
+	sub, err := context.Subscribe(topic)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	defer context.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case pub, open := <-sub.C:
+			if !open {
+				return
+			}
+			writeServerSentEvent(w, pub.ID, pub.Data)
+			flusher.Flush()
+		}
+	}
+}
+
+const (
+	// Number of dataobjects listed per page, unless the limit parameter is
+	// given, and the most that may be asked for
+	STORE_DEFAULT_PAGE_SIZE = 100
+	STORE_MAX_PAGE_SIZE     = 1000
+)
+
+type StoreListing struct {
+	Stats   datastore.Stats
+	Entries []datastore.Entry
+	// The after parameter of the next page. Empty on the last page.
+	Next string `json:",omitempty"`
+}
+
+// Lists the dataobjects this node holds in key order, one page at a time,
+// along with totals over all of them
+func storeHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /store?after={key}&limit={count} for GET"))
+		return
+	}
+
+	limit := STORE_DEFAULT_PAGE_SIZE
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > STORE_MAX_PAGE_SIZE {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "invalid limit %q, expected 1 to %d", value, STORE_MAX_PAGE_SIZE)
+			return
+		}
+		limit = parsed
+	}
+
+	store := context.GetDataStore()
+	listing := StoreListing{
+		Stats:   store.Stats(),
+		Entries: store.Entries(r.URL.Query().Get("after"), limit),
+	}
+	if len(listing.Entries) == limit {
+		listing.Next = listing.Entries[limit-1].Key
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(listing)
+}
+
+// Returns the status of this node as JSON
+func statusHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /status for GET"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(context.Status())
+}
+
+// Looks up the closest nodes of the ID in the path, or of this node, and
+// returns every RPC the lookup sent as JSON
+func lookupHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /lookup/{id} for GET"))
+		return
+	}
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/lookup"), "/")
+	targetID, err := commands.ParseTargetID(context, id)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(context.TraceLookup(targetID))
+}
+
+// Returns the metrics of this node in the Prometheus text format
+func metricsHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /metrics for GET"))
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.PROMETHEUS_CONTENT_TYPE)
+	w.WriteHeader(http.StatusOK)
+	if err := metrics.WritePrometheus(w); err != nil {
+		context.GetLogger().Warn("Could not write metrics", "err", err)
+	}
+}
+
+// Largest compressed snapshot that can be posted, in bytes
+const SNAPSHOT_MAX_BYTES = 256 << 20
+
+// Downloads a snapshot of the datastore of this node with GET, and imports a
+// snapshot posted as the body of a POST
+func snapshotHandle(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", `attachment; filename="kademlia-snapshot.gz"`)
+		w.WriteHeader(http.StatusOK)
+		if _, err := commands.ExportSnapshot(context, w); err != nil {
+			context.GetLogger().Warn("Could not export snapshot", "err", err)
+		}
+	case "POST":
+		r.Body = http.MaxBytesReader(w, r.Body, SNAPSHOT_MAX_BYTES)
+		summary, err := commands.ImportSnapshot(context, r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			fmt.Fprintf(w, "snapshot exceeds %d bytes", SNAPSHOT_MAX_BYTES)
+			return
+		}
+		if errors.Is(err, datastore.ErrSnapshotTooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			fmt.Fprintf(w, "imported %d dataobjects before the snapshot exceeded %d bytes decompressed", summary.Restored, datastore.SNAPSHOT_MAX_SIZE)
+			return
+		}
+		if err != nil && summary.Entries == 0 {
+			// The snapshot could not be read
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "imported %d of %d dataobjects, and republished %d: %v", summary.Restored, summary.Entries, summary.Republished, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, str)
+		json.NewEncoder(w).Encode(summary)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /snapshot for GET or POST"))
+	}
+}
+
+// Most expirations waiting to be sent to a stream. When full, new
+// expirations are dropped for that stream.
+const EXPIRATIONS_BUFFER = 64
+
+// Streams the dataobjects that expire on this node as server-sent events,
+// each an ExpiryEvent as JSON
+func expirationsHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Invalid HTTP request, try /expirations for GET"))
+		return
 	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "streaming is not supported")
+		return
+	}
+
+	events := make(chan datastore.ExpiryEvent, EXPIRATIONS_BUFFER)
+	remove := context.GetDataStore().OnExpiry(func(event datastore.ExpiryEvent) {
+		select {
+		case events <- event:
+		default:
+			context.GetLogger().Warn("Dropped expiration: stream is full", "key", event.Key)
+		}
+	})
+	defer remove()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
 
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			data, _ := json.Marshal(event)
+			writeServerSentEvent(w, event.Key, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// Write a server-sent event. Every line of the data is sent as its own data
+// field.
+func writeServerSentEvent(w io.Writer, id string, data []byte) {
+	fmt.Fprintf(w, "id: %s\n", id)
+	for _, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
 }
 
 // Homepage guide
 func homePage(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintln(w, "Example of post: /objects")
-	fmt.Fprintln(w, "Example of get: /objects/{hash}")
+	fmt.Fprintln(w, "Example of post: /objects (form fields: message, and optionally ttl, e.g. 30m)")
+	fmt.Fprintln(w, "Example of raw post: /objects?ttl=30m (Content-Type: application/octet-stream, body: the value)")
+	fmt.Fprintln(w, "Example of encrypted post: /objects (form fields: message, and encrypt=true or key={hex key})")
+	fmt.Fprintln(w, "Example of get: /objects/{hash} or /objects/{capability}")
+	fmt.Fprintln(w, "Example of delete: /objects/{hash}")
+	fmt.Fprintln(w, "Example of record post: /records?ttl=30m (body: signed record as JSON)")
+	fmt.Fprintln(w, "Example of record get: /records/{key}")
+	fmt.Fprintln(w, "Example of provider announcement: POST /providers/{key}?ttl=30m")
+	fmt.Fprintln(w, "Example of provider get: /providers/{key}")
+	fmt.Fprintln(w, "Example of traced lookup: /lookup/{id} or /lookup for this node")
+	fmt.Fprintln(w, "Example of status: /status")
+	fmt.Fprintln(w, "Example of metrics: /metrics (Prometheus text format)")
+	fmt.Fprintln(w, "Example of store listing: /store?after={key}&limit=100")
+	fmt.Fprintln(w, "Example of snapshot export: /snapshot")
+	fmt.Fprintln(w, "Example of snapshot import: POST /snapshot (body: a snapshot from export)")
+	fmt.Fprintln(w, "Example of expirations: /expirations (server-sent events)")
+	fmt.Fprintln(w, "Example of publish: POST /topics/{topic} (body: the message)")
+	fmt.Fprintln(w, "Example of subscribe: /topics/{topic} (server-sent events, unsubscribed when closed)")
 }
 
-// Directs webpages to corresponding handlers and starts listener
-func Restful(kademlia kademlia.IKademlia) {
+// Directs webpages to corresponding handlers and starts listener on
+// `address`. Only returns if the listener fails.
+func Restful(kademlia kademlia.IKademlia, address string) error {
 	context = kademlia
 	http.HandleFunc("/", homePage)
 	http.HandleFunc("/objects", putHandle)
-	http.HandleFunc("/objects/", getHandle)
-	log.Fatal(http.ListenAndServe(":8081", nil))
+	http.HandleFunc("/objects/", objectHandle)
+	http.HandleFunc("/records", recordPutHandle)
+	http.HandleFunc("/records/", recordGetHandle)
+	http.HandleFunc("/providers/", providerHandle)
+	http.HandleFunc("/topics/", topicHandle)
+	http.HandleFunc("/lookup", lookupHandle)
+	http.HandleFunc("/lookup/", lookupHandle)
+	http.HandleFunc("/status", statusHandle)
+	http.HandleFunc("/metrics", metricsHandle)
+	http.HandleFunc("/store", storeHandle)
+	http.HandleFunc("/snapshot", snapshotHandle)
+	http.HandleFunc("/expirations", expirationsHandle)
+	return http.ListenAndServe(address, nil)
 }
@@ -1,14 +1,31 @@
 package rest
 
 import (
+	"bytes"
+	gocontext "context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"d7024e/cli/commands"
 	mocks "d7024e/internal/test/mock"
+	"d7024e/kademlia/datastore"
+	"d7024e/kademlia/encryption"
+	"d7024e/kademlia/files"
+	"d7024e/kademlia/network"
+	"d7024e/kademlia/network/routing"
+	"d7024e/kademlia/network/rpc"
+	"d7024e/kademlia/record"
+	"d7024e/metrics"
+	"d7024e/util"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -22,7 +39,7 @@ func TestPutHandle_ShoudlReturnSucces_WhenRequestIsCorrect(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	kademliaMock := new(mocks.KademliaMockObject)
-	kademliaMock.On("Store", mock.Anything).Return(expectedHash, nil)
+	kademliaMock.On("Store", mock.Anything, mock.Anything).Return(expectedHash, nil)
 
 	context = kademliaMock
 	putHandle(w, req)
@@ -38,13 +55,122 @@ func TestPutHandle_ShoudlReturnSucces_WhenRequestIsCorrect(t *testing.T) {
 	assert.Contains(t, string(resBody), expectedHash)
 }
 
+func TestPutHandle_WithTTL_ShouldStoreWithTTL(t *testing.T) {
+	reqBody := strings.NewReader("message=my message&ttl=30m")
+	req := httptest.NewRequest(http.MethodPost, "/objects", reqBody)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Store", []byte("my message"), 30*time.Minute).Return("myhash", nil)
+
+	context = kademliaMock
+	putHandle(w, req)
+
+	res := w.Result()
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPutHandle_WithEncrypt_ShouldReturnCapability(t *testing.T) {
+	key, ciphertext, _ := encryption.EncryptConvergent([]byte("my message"))
+	reqBody := strings.NewReader("message=my message&encrypt=true")
+	req := httptest.NewRequest(http.MethodPost, "/objects", reqBody)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Store", ciphertext, time.Duration(0)).Return("myhash", nil)
+
+	context = kademliaMock
+	putHandle(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	resBody, _ := io.ReadAll(res.Body)
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Contains(t, string(resBody), "/objects/"+encryption.Capability{Hash: "myhash", Key: key}.String())
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestGetHandle_WithCapability_ShouldReturnDecryptedObject(t *testing.T) {
+	key, ciphertext, _ := encryption.EncryptConvergent([]byte("my message"))
+	capability := encryption.Capability{Hash: "myhash", Key: key}
+	req := httptest.NewRequest(http.MethodGet, "/objects/"+capability.String(), nil)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupData", "myhash").Return(ciphertext, nil, time.Minute)
+
+	context = kademliaMock
+	getHandle(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	resBody, _ := io.ReadAll(res.Body)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "my message", string(resBody))
+}
+
+func TestPutHandle_WithRawBody_ShouldStoreEveryByteValue(t *testing.T) {
+	value := make([]byte, 256)
+	for i := range value {
+		value[i] = byte(i)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/objects?ttl=30m", bytes.NewReader(value))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Store", value, 30*time.Minute).Return("myhash", nil)
+
+	context = kademliaMock
+	putHandle(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	resBody, _ := io.ReadAll(res.Body)
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Contains(t, string(resBody), "/objects/myhash")
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPutHandle_WithMessage_ShouldStoreMessageAsIs(t *testing.T) {
+	message := `"-ttl %d"`
+	reqBody := strings.NewReader("message=" + url.QueryEscape(message))
+	req := httptest.NewRequest(http.MethodPost, "/objects", reqBody)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Store", []byte(message), time.Duration(0)).Return("myhash", nil)
+
+	context = kademliaMock
+	putHandle(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestPutHandle_WithInvalidOptions_ShouldReturnBadRequest(t *testing.T) {
+	for _, body := range []string{"message=a&ttl=soon", "message=a&key=nothex"} {
+		req := httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		context = new(mocks.KademliaMockObject)
+		putHandle(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, body)
+	}
+}
+
 func TestPutHandle_ShouldReturnError_WhenPutObjectInStoreFails(t *testing.T) {
 	reqBody := strings.NewReader("message=")
 	req := httptest.NewRequest(http.MethodPost, "/objects", reqBody)
 	w := httptest.NewRecorder()
 
 	kademliaMock := new(mocks.KademliaMockObject)
-	kademliaMock.On("Store", mock.Anything).Return("", errors.New(""))
+	kademliaMock.On("Store", mock.Anything, mock.Anything).Return("", errors.New(""))
 
 	context = kademliaMock
 	putHandle(w, req)
@@ -71,7 +197,7 @@ func TestGetHandle_ShouldReturnSuccess_WhenObjectIsFound(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	kademliaMock := new(mocks.KademliaMockObject)
-	kademliaMock.On("LookupData", mock.Anything).Return([]byte(expectedData), nil)
+	kademliaMock.On("LookupData", mock.Anything).Return([]byte(expectedData), nil, time.Minute)
 
 	context = kademliaMock
 	getHandle(w, req)
@@ -82,6 +208,54 @@ func TestGetHandle_ShouldReturnSuccess_WhenObjectIsFound(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, res.StatusCode)
 	assert.Equal(t, expectedData, string(resBody))
+	assert.Equal(t, "60", res.Header.Get("X-Kademlia-TTL"))
+}
+
+func TestGetHandle_ShouldReturnEveryByteValue(t *testing.T) {
+	value := append([]byte("%s%d%%"), 0)
+	for i := 0; i < 256; i++ {
+		value = append(value, byte(i))
+	}
+	req := httptest.NewRequest(http.MethodGet, "/objects/myhash", nil)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupData", "myhash").Return(value, nil, time.Minute)
+
+	context = kademliaMock
+	getHandle(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	resBody, _ := io.ReadAll(res.Body)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, value, resBody)
+}
+
+func TestGetHandle_WithFile_ShouldReturnReassembledFile(t *testing.T) {
+	content := []byte("my notes")
+	manifest := files.EncodeManifest(&files.Manifest{
+		Format: files.MANIFEST_FORMAT,
+		Size:   int64(len(content)),
+		Chunks: []string{util.Hash(content)},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/objects/"+util.Hash(manifest)+"?file=true", nil)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupData", util.Hash(manifest)).Return(manifest, nil, time.Minute)
+	kademliaMock.On("LookupData", util.Hash(content)).Return(content, nil, time.Minute)
+
+	context = kademliaMock
+	getHandle(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	resBody, _ := io.ReadAll(res.Body)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, content, resBody)
 }
 
 func TestGetHandle_ShouldReturnError_WhenObjectLookupFailed(t *testing.T) {
@@ -91,7 +265,7 @@ func TestGetHandle_ShouldReturnError_WhenObjectLookupFailed(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	kademliaMock := new(mocks.KademliaMockObject)
-	kademliaMock.On("LookupData", mock.Anything).Return(nil, nil)
+	kademliaMock.On("LookupData", mock.Anything).Return(nil, nil, time.Duration(0))
 
 	context = kademliaMock
 	getHandle(w, req)
@@ -112,6 +286,384 @@ func TestGetHandle_ShouldReturnError_WhenHttpMethodIsNotGet(t *testing.T) {
 	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
 }
 
+func TestForgetHandle_ShouldReturnNumberOfNodesThatRemovedObject(t *testing.T) {
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000A"), "nodeA")
+	nodeB := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000B"), "nodeB")
+	req := httptest.NewRequest(http.MethodDelete, "/objects/4a1e8f3f8b6a2e6cba3a9d1c0c0e23a7d2b1f9e5", nil)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupContact", mock.Anything).Return(nil)
+	kademliaMock.On("ForgetData", "4a1e8f3f8b6a2e6cba3a9d1c0c0e23a7d2b1f9e5").Return([]rpc.ForgetResponse{
+		{Contact: nodeA, Removed: true},
+		{Contact: nodeB, Err: rpc.ErrTimeout},
+	}, nil)
+
+	context = kademliaMock
+	objectHandle(w, req)
+
+	res := w.Result()
+	var summary ForgetSummary
+	json.NewDecoder(res.Body).Decode(&summary)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, 1, summary.Removed)
+	assert.Equal(t, 2, len(summary.Nodes))
+	assert.Equal(t, rpc.ErrTimeout.Error(), summary.Nodes[1].Error)
+}
+
+func TestForgetHandle_WithInvalidHash_ShouldReturnBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/objects/myhash", nil)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+
+	context = kademliaMock
+	objectHandle(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	kademliaMock.AssertNotCalled(t, "LookupContact", mock.Anything)
+}
+
+func TestProviderHandle_WithPost_ShouldAnnounceWithTTL(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/providers/4a1e8f3f8b6a2e6cba3a9d1c0c0e23a7d2b1f9e5?ttl=30m", nil)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("AnnounceProvider", "4a1e8f3f8b6a2e6cba3a9d1c0c0e23a7d2b1f9e5", 30*time.Minute).Return(nil)
+
+	context = kademliaMock
+	providerHandle(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestProviderHandle_WithPostOfInvalidOptions_ShouldReturnBadRequest(t *testing.T) {
+	var tests = []struct {
+		name string
+		url  string
+	}{
+		{"invalid key", "/providers/mykey"},
+		{"flag in key", "/providers/-ttl"},
+		{"invalid ttl", "/providers/4a1e8f3f8b6a2e6cba3a9d1c0c0e23a7d2b1f9e5?ttl=soon"},
+		{"flag in ttl", "/providers/4a1e8f3f8b6a2e6cba3a9d1c0c0e23a7d2b1f9e5?ttl=1m%20--"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.url, nil)
+			w := httptest.NewRecorder()
+
+			kademliaMock := new(mocks.KademliaMockObject)
+
+			context = kademliaMock
+			providerHandle(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+			kademliaMock.AssertNotCalled(t, "AnnounceProvider", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestProviderHandle_WithGet_ShouldReturnProviders(t *testing.T) {
+	nodeA := routing.NewContact(routing.NewKademliaID("000000000000000000000000000000000000000A"), "nodeA")
+	req := httptest.NewRequest(http.MethodGet, "/providers/mykey", nil)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("FindProviders", "mykey").Return([]routing.Contact{nodeA})
+
+	context = kademliaMock
+	providerHandle(w, req)
+
+	res := w.Result()
+	var providers []Provider
+	json.NewDecoder(res.Body).Decode(&providers)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, []Provider{{nodeA.ID.String(), "nodeA"}}, providers)
+}
+
+func TestProviderHandle_WithoutProviders_ShouldReturnNotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/providers/mykey", nil)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("FindProviders", "mykey").Return(nil)
+
+	context = kademliaMock
+	providerHandle(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestTopicHandle_WithPost_ShouldPublishBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/topics/news", strings.NewReader("hello"))
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Publish", "news", []byte("hello")).Return(nil)
+
+	context = kademliaMock
+	topicHandle(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestTopicHandle_WithGet_ShouldStreamPublications(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/topics/news", nil)
+	w := httptest.NewRecorder()
+	broker := network.NewBroker()
+	sub, _ := broker.Subscribe("news")
+	pub := network.NewPublication("news", []byte("hello\nworld"))
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Subscribe", "news").Return(sub, nil)
+	kademliaMock.On("Unsubscribe", sub).Return(nil).Once()
+
+	// The stream ends once the publication is sent, as the subscription is
+	// closed
+	broker.Deliver(pub, time.Now())
+	broker.Unsubscribe(sub)
+	context = kademliaMock
+	topicHandle(w, req)
+
+	res := w.Result()
+	body, _ := io.ReadAll(res.Body)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	assert.Equal(t, "id: "+pub.ID+"\ndata: hello\ndata: world\n\n", string(body))
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestTopicHandle_WhenSubscribeFails_ShouldReturnError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/topics/news", nil)
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Subscribe", "news").Return(nil, errors.New("no suitable contacts"))
+
+	context = kademliaMock
+	topicHandle(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+// Records a response, and calls `flushed` with the number of times it was
+// flushed
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+	flushed func(flushes int)
+}
+
+func (recorder *flushRecorder) Flush() {
+	recorder.ResponseRecorder.Flush()
+	recorder.flushes++
+	recorder.flushed(recorder.flushes)
+}
+
+func TestExpirationsHandle_ShouldStreamExpiryEvents(t *testing.T) {
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	req := httptest.NewRequest(http.MethodGet, "/expirations", nil).WithContext(ctx)
+	// The stream is closed once the headers and the event are sent
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder(), flushed: func(flushes int) {
+		if flushes == 2 {
+			cancel()
+		}
+	}}
+	event := datastore.ExpiryEvent{Key: "key1", Value: []byte("value1"), TTL: time.Minute}
+	removed := false
+
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("OnExpiry", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(datastore.ExpiryListener)(event)
+	}).Return(func() { removed = true })
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetDataStore").Return(storeMock)
+
+	context = kademliaMock
+	expirationsHandle(w, req)
+
+	res := w.Result()
+	body, _ := io.ReadAll(res.Body)
+	data, _ := json.Marshal(event)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	assert.Equal(t, "id: key1\ndata: "+string(data)+"\n\n", string(body))
+	assert.True(t, removed)
+}
+
+func TestLookupHandle_ShouldReturnTrace(t *testing.T) {
+	targetID := routing.NewKademliaID("000000000000000000000000000000000000001f")
+	req := httptest.NewRequest(http.MethodGet, "/lookup/"+targetID.String(), nil)
+	w := httptest.NewRecorder()
+	trace := rpc.LookupTrace{
+		Target:  targetID.String(),
+		Steps:   []rpc.TraceStep{{Parent: -1, Round: 1, Contact: rpc.TracedContact{ID: targetID.String(), Address: "nodeA"}, RTT: time.Millisecond, Returned: []rpc.TracedContact{}}},
+		Closest: []rpc.TracedContact{{ID: targetID.String(), Address: "nodeA"}},
+	}
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("TraceLookup", targetID).Return(trace)
+
+	context = kademliaMock
+	lookupHandle(w, req)
+
+	var actual rpc.LookupTrace
+	json.NewDecoder(w.Result().Body).Decode(&actual)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, trace, actual)
+}
+
+func TestLookupHandle_WithInvalidID_ShouldReturnBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/lookup/xyz", nil)
+	w := httptest.NewRecorder()
+
+	context = new(mocks.KademliaMockObject)
+	lookupHandle(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestStatusHandle_ShouldReturnStatus(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	w := httptest.NewRecorder()
+	status := network.NodeStatus{
+		ID:        "0000000000000000000000000000000000000001",
+		Address:   "10.0.0.1:4000",
+		Uptime:    time.Minute,
+		JoinState: "joined",
+		Buckets:   []network.BucketFill{{Index: 159, Contacts: 1}},
+		Network:   network.NetworkStats{Sent: map[string]uint64{"PING": 1}, Received: map[string]uint64{}},
+	}
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("Status").Return(status)
+
+	context = kademliaMock
+	statusHandle(w, req)
+
+	var actual network.NodeStatus
+	json.NewDecoder(w.Result().Body).Decode(&actual)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, status, actual)
+}
+
+func TestMetricsHandle_ShouldReturnPrometheusText(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	metricsHandle(w, req)
+
+	res := w.Result()
+	body, _ := io.ReadAll(res.Body)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, metrics.PROMETHEUS_CONTENT_TYPE, res.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), "# TYPE kademlia_rpc_sent_total counter\n")
+}
+
+func TestStoreHandle_ShouldReturnPageWithNext(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/store?after=key0&limit=2", nil)
+	w := httptest.NewRecorder()
+
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("Stats").Return(datastore.Stats{Entries: 3, Bytes: 18})
+	storeMock.On("Entries").Return([]datastore.Entry{{Key: "key1", Size: 6}, {Key: "key2", Size: 6}})
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetDataStore").Return(storeMock)
+
+	context = kademliaMock
+	storeHandle(w, req)
+
+	res := w.Result()
+	var listing StoreListing
+	json.NewDecoder(res.Body).Decode(&listing)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, 3, listing.Stats.Entries)
+	assert.Equal(t, 2, len(listing.Entries))
+	assert.Equal(t, "key2", listing.Next)
+}
+
+func TestStoreHandle_WithInvalidLimit_ShouldReturnBadRequest(t *testing.T) {
+	for _, limit := range []string{"none", "0", "100000"} {
+		req := httptest.NewRequest(http.MethodGet, "/store?limit="+limit, nil)
+		w := httptest.NewRecorder()
+
+		context = new(mocks.KademliaMockObject)
+		storeHandle(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, limit)
+	}
+}
+
+func TestSnapshotHandle_WithGet_ShouldReturnSnapshot(t *testing.T) {
+	expiration := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []datastore.SnapshotEntry{datastore.NewSnapshotEntry("key1", []byte("value1"), time.Minute, expiration, nil, false)}
+	req := httptest.NewRequest(http.MethodGet, "/snapshot", nil)
+	w := httptest.NewRecorder()
+
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("Snapshot").Return(entries)
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetDataStore").Return(storeMock)
+
+	context = kademliaMock
+	snapshotHandle(w, req)
+
+	res := w.Result()
+	actual, err := datastore.ReadSnapshot(res.Body)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Nil(t, err)
+	assert.Equal(t, entries, actual)
+}
+
+func TestSnapshotHandle_WithPost_ShouldReturnSummary(t *testing.T) {
+	expiration := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []datastore.SnapshotEntry{datastore.NewSnapshotEntry(util.Hash([]byte("value1")), []byte("value1"), time.Minute, expiration, nil, true)}
+	body := new(bytes.Buffer)
+	datastore.WriteSnapshot(body, entries, time.Now())
+	req := httptest.NewRequest(http.MethodPost, "/snapshot", body)
+	w := httptest.NewRecorder()
+
+	storeMock := new(mocks.DataStoreMockObject)
+	storeMock.On("Restore", entries).Return(entries, nil)
+	networkMock := new(mocks.NetworkMockObject)
+	networkMock.On("IsForgotten", entries[0].Key).Return(false)
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("GetDataStore").Return(storeMock)
+	kademliaMock.On("GetNetwork").Return(networkMock)
+
+	context = kademliaMock
+	snapshotHandle(w, req)
+
+	res := w.Result()
+	var summary commands.ImportSummary
+	json.NewDecoder(res.Body).Decode(&summary)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, commands.ImportSummary{Entries: 1, Restored: 1}, summary)
+}
+
+func TestSnapshotHandle_WithInvalidSnapshot_ShouldReturnBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/snapshot", strings.NewReader("not a snapshot"))
+	w := httptest.NewRecorder()
+
+	context = new(mocks.KademliaMockObject)
+	snapshotHandle(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
 func TestHomepage_ShouldReturnSuccess(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	w := httptest.NewRecorder()
@@ -121,3 +673,70 @@ func TestHomepage_ShouldReturnSuccess(t *testing.T) {
 	res := w.Result()
 	assert.Equal(t, http.StatusOK, res.StatusCode)
 }
+
+func TestRecordPutHandle_ShouldPublishRecord(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	rec := record.New(privateKey, 1, []byte("my config"))
+	req := httptest.NewRequest(http.MethodPost, "/records?ttl=30m", bytes.NewReader(record.Encode(rec)))
+	w := httptest.NewRecorder()
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("PublishRecord", rec, 30*time.Minute).Return(nil)
+
+	context = kademliaMock
+	recordPutHandle(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	resBody, _ := io.ReadAll(res.Body)
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Contains(t, string(resBody), "/records/"+rec.Key())
+	kademliaMock.AssertExpectations(t)
+}
+
+func TestRecordPutHandle_WithInvalidBody_ShouldReturnBadRequest(t *testing.T) {
+	var tests = []struct {
+		name string
+		url  string
+		body string
+	}{
+		{"invalid record", "/records", "not a record"},
+		{"invalid ttl", "/records?ttl=soon", "{}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.url, strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+
+			context = new(mocks.KademliaMockObject)
+			recordPutHandle(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestRecordGetHandle(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	rec := record.New(privateKey, 2, []byte("my config"))
+
+	kademliaMock := new(mocks.KademliaMockObject)
+	kademliaMock.On("LookupRecord", rec.Key()).Return(rec)
+	kademliaMock.On("LookupRecord", mock.Anything).Return(nil)
+	context = kademliaMock
+
+	w := httptest.NewRecorder()
+	recordGetHandle(w, httptest.NewRequest(http.MethodGet, "/records/"+rec.Key(), nil))
+	found := w.Result()
+	actual, _ := record.Decode(w.Body.Bytes())
+
+	w = httptest.NewRecorder()
+	recordGetHandle(w, httptest.NewRequest(http.MethodGet, "/records/other", nil))
+	missing := w.Result()
+
+	assert.Equal(t, http.StatusOK, found.StatusCode)
+	assert.Equal(t, rec, actual)
+	assert.Equal(t, http.StatusNotFound, missing.StatusCode)
+}
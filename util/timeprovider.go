@@ -1,6 +1,9 @@
 package util
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type ITimeProvider interface {
 	// Now returns the current local time.
@@ -25,3 +28,27 @@ type FakeTimeProvider struct {
 func (f *FakeTimeProvider) Now() time.Time {
 	return f.InternalTime
 }
+
+// SyncTimeProvider is a FakeTimeProvider that is safe for concurrent use, for
+// tests that change the time while a node may still read it.
+type SyncTimeProvider struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func NewSyncTimeProvider(now time.Time) *SyncTimeProvider {
+	return &SyncTimeProvider{now: now}
+}
+
+func (f *SyncTimeProvider) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+// Set the time returned by Now
+func (f *SyncTimeProvider) Set(now time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.now = now
+}